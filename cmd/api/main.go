@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,54 +11,122 @@ import (
 	"task_handler/internal/config"
 	"task_handler/internal/db"
 	"task_handler/internal/handler"
+	"task_handler/internal/logger"
+	"task_handler/internal/migrate"
+	"task_handler/internal/observability"
 	"task_handler/internal/queue"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// tracingFlushTimeout bounds exporting the last spans at shutdown
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
-	logrus.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+	logCfg := config.LoadLog()
+	logger.Setup(&logCfg)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate.Main(os.Args[2:]))
+	}
+
+	os.Exit(run())
+}
 
+// run serves HTTP until a shutdown signal arrives, drains in-flight
+// requests, then closes the backing services in reverse dependency order.
+// The returned value is the process exit code.
+func run() int {
 	config := config.Load()
+	shutdownTracing := observability.SetupTracing(&config.Tracing, "task-api")
+
+	// Each binary has its own default port, so they can share a host
+	stopMetrics, err := observability.StartMetricsServer(&config.Metrics, ":9092")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to start metrics server")
+		return 1
+	}
+
 	db := db.Init(&config.DB)
 
-	defer func() {
-		if err := db.Close(); err != nil {
-			logrus.WithError(err).Fatal("Failed to close database connection")
+	// Replicas starting together serialize on the migration advisory lock
+	if config.DB.AutoMigrate {
+		if err := migrate.Apply(context.Background(), db); err != nil {
+			logrus.WithError(err).Error("Failed to apply database migrations")
+			db.Close()
+			return 1
 		}
-	}()
+	}
 
 	rdb := cache.SetupRedis(&config.Redis)
-	defer func() {
-		if err := rdb.Close(); err != nil {
-			logrus.WithError(err).Fatal("Failed to close redis connection")
-		}
-	}()
-
-	conn := queue.SetupRabbitMQ(&config.RabbitMQ)
-	defer func() {
-		if err := conn.Close(); err != nil {
-			logrus.WithError(err).Fatal("Failed to close RabbitMQ connection")
-		}
-	}()
+	broker := queue.Setup(config, db)
 
-	r := handler.SetupHandler(db, conn, rdb, config)
+	r := handler.SetupHandler(db, broker, rdb, config)
 
 	srv := &http.Server{
-		Addr:    ":8087",
-		Handler: r,
+		Addr:         config.HTTP.Addr,
+		Handler:      r,
+		ReadTimeout:  config.HTTP.ReadTimeout,
+		WriteTimeout: config.HTTP.WriteTimeout,
+		IdleTimeout:  config.HTTP.IdleTimeout,
 	}
 
+	serverErr := make(chan error, 1)
 	go func() {
-		logrus.Info("Starting server on :8087")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logrus.WithError(err).Fatal("Failed to start server")
+		logrus.Infof("Starting server on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
+		close(serverErr)
 	}()
+
+	exitCode := 0
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	logrus.Info("Shutting down server...")
+	select {
+	case sig := <-quit:
+		logrus.Infof("Received %s, shutting down server (grace %s)", sig, config.HTTP.ShutdownTimeout)
+	case err := <-serverErr:
+		logrus.WithError(err).Error("Server stopped unexpectedly")
+		exitCode = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Error("Server did not drain in time, closing remaining connections")
+		if err := srv.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close server")
+		}
+		exitCode = 1
+	}
+	stopMetrics()
+
+	// Close dependencies only after no handler can still be using them
+	if err := broker.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close queue broker")
+		exitCode = 1
+	}
+
+	if err := rdb.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close redis connection")
+		exitCode = 1
+	}
+
+	if err := db.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close database connection")
+		exitCode = 1
+	}
+
+	// Spans still buffered are exported before exiting
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush traces")
+	}
+
+	logrus.Info("Server shut down")
+	return exitCode
 }
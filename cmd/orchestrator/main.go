@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/db"
+	"task_handler/internal/handler"
+	"task_handler/internal/logger"
+	"task_handler/internal/middleware"
+	"task_handler/internal/migrate"
+	"task_handler/internal/observability"
+	"task_handler/internal/queue"
+	"task_handler/internal/retention"
+	"task_handler/internal/sandbox"
+	"task_handler/internal/upload"
+	"task_handler/internal/worker"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// tracingFlushTimeout bounds exporting the last spans at shutdown
+const tracingFlushTimeout = 5 * time.Second
+
+func main() {
+	// Exec tasks start this binary again to apply their resource limits
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Subcommand {
+		os.Exit(sandbox.Main(os.Args[2:]))
+	}
+
+	logCfg := config.LoadLog()
+	logger.Setup(&logCfg)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate.Main(os.Args[2:]))
+	}
+
+	os.Exit(run())
+}
+
+// applyStandaloneDefaults switches every backend that was not configured
+// explicitly to its in-process implementation, so the binary only needs
+// Postgres
+func applyStandaloneDefaults(cfg *config.Config) {
+	if os.Getenv("QUEUE_BACKEND") == "" {
+		cfg.Queue.Backend = queue.BackendMemory
+	}
+	if os.Getenv("CACHE_BACKEND") == "" {
+		cfg.Cache.Backend = cache.BackendMemory
+	}
+	if os.Getenv("RATE_LIMIT_BACKEND") == "" {
+		cfg.RateLimit.Backend = middleware.LimiterBackendMemory
+	}
+}
+
+// run serves the HTTP API and an embedded worker pool from one process
+// until a shutdown signal arrives. The API stops first so no new tasks are
+// queued, then the workers drain. The returned value is the process exit code.
+func run() int {
+	cfg := config.Load()
+	shutdownTracing := observability.SetupTracing(&cfg.Tracing, "task-orchestrator")
+
+	// Each binary has its own default port, so they can share a host
+	stopMetrics, err := observability.StartMetricsServer(&cfg.Metrics, ":9093")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to start metrics server")
+		return 1
+	}
+
+	applyStandaloneDefaults(cfg)
+
+	logrus.Infof("Starting all-in-one mode (queue=%s, cache=%s, rate limit=%s)",
+		cfg.Queue.Backend, cfg.Cache.Backend, cfg.RateLimit.Backend)
+
+	db := db.Init(&cfg.DB)
+
+	if cfg.DB.AutoMigrate {
+		if err := migrate.Apply(context.Background(), db); err != nil {
+			logrus.WithError(err).Error("Failed to apply database migrations")
+			db.Close()
+			return 1
+		}
+	}
+
+	broker := queue.Setup(cfg, db)
+
+	var rdb *redis.Client
+	if cfg.Cache.Backend == cache.BackendRedis || cfg.RateLimit.Backend == middleware.LimiterBackendRedis {
+		rdb = cache.SetupRedis(&cfg.Redis)
+	}
+
+	// The pool shares the API's cache so status changes are visible at once
+	taskCache := cache.Setup(&cfg.Cache, rdb)
+
+	store := artifact.Setup(&cfg.Artifact)
+
+	r := handler.NewRouter(&handler.Services{
+		DB:        db,
+		Broker:    broker,
+		Redis:     rdb,
+		Cache:     taskCache,
+		Limiter:   middleware.SetupLimiter(&cfg.RateLimit, rdb),
+		Artifacts: store,
+	}, cfg)
+
+	pool, plugins := worker.Setup(cfg, broker, db, store, taskCache)
+	pool.Start()
+	stopPlugins := plugins.Start()
+
+	// Every worker sweeps; concurrent sweeps skip each other's rows
+	stopCollector := upload.NewCollector(upload.NewUploadRepository(), db, store, cfg.Upload).Start()
+	stopRetention := retention.Setup(&cfg.Retention, db, store, taskCache).Start()
+
+	srv := &http.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      r,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logrus.Infof("Starting server on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	exitCode := 0
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-quit:
+		logrus.Infof("Received %s, shutting down server (grace %s)", sig, cfg.HTTP.ShutdownTimeout)
+	case err := <-serverErr:
+		logrus.WithError(err).Error("Server stopped unexpectedly")
+		exitCode = 1
+	}
+
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		logrus.WithError(err).Error("Server did not drain in time, closing remaining connections")
+		if err := srv.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close server")
+		}
+		exitCode = 1
+	}
+
+	logrus.Infof("Draining workers (timeout %s)", cfg.Worker.DrainTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Worker.DrainTimeout)
+	defer cancelDrain()
+	if err := pool.Shutdown(drainCtx); err != nil {
+		logrus.WithError(err).Warn("Workers did not drain in time, unfinished tasks were requeued")
+		exitCode = 1
+	}
+
+	stopCollector()
+	stopRetention()
+	stopPlugins()
+	plugins.Close(context.Background())
+	stopMetrics()
+
+	// Close dependencies only after neither handlers nor workers can still use them
+	if err := broker.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close queue broker")
+		exitCode = 1
+	}
+
+	if rdb != nil {
+		if err := rdb.Close(); err != nil {
+			logrus.WithError(err).Error("Failed to close redis connection")
+			exitCode = 1
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close database connection")
+		exitCode = 1
+	}
+
+	// Spans still buffered are exported before exiting
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush traces")
+	}
+
+	logrus.Info("Orchestrator shut down")
+	return exitCode
+}
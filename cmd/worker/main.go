@@ -1,54 +1,139 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
 	"task_handler/internal/config"
 	"task_handler/internal/db"
+	"task_handler/internal/logger"
+	"task_handler/internal/migrate"
+	"task_handler/internal/observability"
 	"task_handler/internal/queue"
-	"task_handler/internal/task"
+	"task_handler/internal/retention"
+	"task_handler/internal/sandbox"
+	"task_handler/internal/upload"
 	"task_handler/internal/worker"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 )
 
+// tracingFlushTimeout bounds exporting the last spans at shutdown
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
-	logrus.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+	// Exec tasks start this binary again to apply their resource limits
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Subcommand {
+		os.Exit(sandbox.Main(os.Args[2:]))
+	}
+
+	logCfg := config.LoadLog()
+	logger.Setup(&logCfg)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate.Main(os.Args[2:]))
+	}
+
+	os.Exit(run())
+}
 
+// run starts the worker pool and blocks until a shutdown signal has been
+// handled. The returned value is the process exit code: 0 when every
+// in-flight task drained in time, 1 otherwise.
+func run() int {
 	cfg := config.Load()
+	shutdownTracing := observability.SetupTracing(&cfg.Tracing, "task-worker")
+
+	// Each binary has its own default port, so they can share a host
+	stopMetrics, err := observability.StartMetricsServer(&cfg.Metrics, ":9091")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to start metrics server")
+		return 1
+	}
 
 	db := db.Init(&cfg.DB)
-	defer func() {
-		if err := db.Close(); err != nil {
-			logrus.WithError(err).Fatal("Failed to close database connection")
-		}
-	}()
 
-	conn := queue.SetupRabbitMQ(&cfg.RabbitMQ)
-	defer func() {
-		if err := conn.Close(); err != nil {
-			logrus.Fatalf("Failed to close RabbitMQ connection")
+	// The API may start at the same time; whoever takes the advisory lock
+	// second finds nothing left to apply
+	if cfg.DB.AutoMigrate {
+		if err := migrate.Apply(context.Background(), db); err != nil {
+			logrus.WithError(err).Error("Failed to apply database migrations")
+			db.Close()
+			return 1
 		}
-	}()
+	}
 
-	repo := task.NewTaskRepository()
+	broker := queue.Setup(cfg, db)
 
-	consumerChannel, err := queue.CreateChannel(conn)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to create RabbitMQ channel")
+	// The API serves downloads from the same store, so both must be
+	// configured with the same ARTIFACT_* settings
+	store := artifact.Setup(&cfg.Artifact)
+
+	// The API caches task status; the pool must clear what it changes, so
+	// both must be configured with the same CACHE_BACKEND and REDIS_* settings
+	var rdb *redis.Client
+	if cfg.Cache.Backend != cache.BackendMemory {
+		rdb = cache.SetupRedis(&cfg.Redis)
+	}
+
+	taskCache := cache.Setup(&cfg.Cache, rdb)
+	pool, plugins := worker.Setup(cfg, broker, db, store, taskCache)
+	pool.Start()
+	stopPlugins := plugins.Start()
+
+	// Every worker sweeps; concurrent sweeps skip each other's rows
+	stopCollector := upload.NewCollector(upload.NewUploadRepository(), db, store, cfg.Upload).Start()
+	stopRetention := retention.Setup(&cfg.Retention, db, store, taskCache).Start()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logrus.Infof("Received %s, draining workers (timeout %s)", sig, cfg.Worker.DrainTimeout)
+
+	exitCode := 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Worker.DrainTimeout)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warn("Workers did not drain in time, unfinished tasks were requeued")
+		exitCode = 1
+	}
+
+	stopCollector()
+	stopRetention()
+	stopPlugins()
+	plugins.Close(context.Background())
+	stopMetrics()
+
+	if err := broker.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close queue broker")
+		exitCode = 1
 	}
 
-	if _, err := queue.DeclareQueue(consumerChannel, "task_queue"); err != nil {
-		logrus.WithError(err).Fatal("Failed to declare RabbitMQ queue")
+	if rdb != nil {
+		if err := rdb.Close(); err != nil {
+			logrus.WithError(err).Error("Failed to close redis connection")
+			exitCode = 1
+		}
 	}
 
-	if err := consumerChannel.Close(); err != nil {
-		logrus.WithError(err).Fatal("Failed to close RabbitMQ channel")
+	if err := db.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close database connection")
+		exitCode = 1
 	}
 
-	for i := 1; i <= 3; i++ {
-		go worker.StartWorker(conn, db, repo, i)
+	// Spans still buffered are exported before exiting
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush traces")
 	}
 
-	select {}
+	logrus.Info("Worker shut down")
+	return exitCode
 }
@@ -0,0 +1,107 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Artifact store backends selectable through ARTIFACT_BACKEND
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound    = errors.New("artifact: object not found")
+	ErrInvalidKey  = errors.New("artifact: invalid key")
+	ErrInvalidName = errors.New("artifact: invalid name")
+)
+
+// validName keeps artifact names usable as a single path segment and as a
+// download file name
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// Object is a stored artifact opened for reading. The caller must close it.
+type Object struct {
+	io.ReadCloser
+	Size int64
+}
+
+// ObjectInfo describes a stored object found by List
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store keeps task output files outside the database. Keys are slash
+// separated paths such as "tasks/42/report.csv"; metadata such as the
+// content type is kept by the caller.
+type Store interface {
+	// Put stores r under key, replacing any existing object. size is -1
+	// when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound when key does not exist
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete succeeds when key does not exist
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object below the directory-like prefix, such
+	// as "tasks/", in no particular order. An error from fn stops the
+	// listing and is returned.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// Setup builds the artifact store for the configured backend
+func Setup(cfg *config.ArtifactConfig) Store {
+	switch cfg.Backend {
+	case BackendLocal, "":
+		logrus.Infof("Using local artifact store at %s", cfg.Dir)
+		store, err := NewLocalStore(cfg.Dir)
+		if err != nil {
+			logrus.Fatalf("Failed to prepare artifact directory: %v", err)
+		}
+		return store
+	case BackendS3:
+		logrus.Infof("Using S3 artifact store (endpoint=%s, bucket=%s)", cfg.S3Endpoint, cfg.S3Bucket)
+		store, err := NewS3Store(context.Background(), cfg)
+		if err != nil {
+			logrus.Fatalf("Failed to connect to artifact bucket: %v", err)
+		}
+		return store
+	default:
+		logrus.Fatal(fmt.Sprintf("Unknown ARTIFACT_BACKEND %q", cfg.Backend))
+		return nil
+	}
+}
+
+// ValidName reports whether name may be used as an artifact name
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// TaskKey is the storage key of a task's artifact
+func TaskKey(taskID int, name string) string {
+	return fmt.Sprintf("tasks/%d/%s", taskID, name)
+}
+
+// checkKey rejects keys that could escape the store's root once mapped to
+// a file path: empty, absolute, or containing "." / ".." segments
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore runs the behaviour every Store implementation must share
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("tasks/%d", time.Now().UnixNano())
+
+	t.Run("PutGet", func(t *testing.T) {
+		key := prefix + "/report.csv"
+		require.NoError(t, store.Put(ctx, key, strings.NewReader("a,b\n1,2\n"), 8, "text/csv"))
+
+		obj, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		defer obj.Close()
+
+		body, err := io.ReadAll(obj)
+		require.NoError(t, err)
+		assert.Equal(t, "a,b\n1,2\n", string(body))
+		assert.Equal(t, int64(8), obj.Size)
+	})
+
+	t.Run("PutUnknownSize", func(t *testing.T) {
+		key := prefix + "/stream.txt"
+		require.NoError(t, store.Put(ctx, key, strings.NewReader("streamed"), -1, "text/plain"))
+
+		obj, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		defer obj.Close()
+		assert.Equal(t, int64(8), obj.Size)
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		key := prefix + "/result.json"
+		require.NoError(t, store.Put(ctx, key, strings.NewReader(`{"v":1}`), -1, "application/json"))
+		require.NoError(t, store.Put(ctx, key, strings.NewReader(`{"v":2}`), -1, "application/json"))
+
+		obj, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		defer obj.Close()
+
+		body, _ := io.ReadAll(obj)
+		assert.Equal(t, `{"v":2}`, string(body))
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		_, err := store.Get(ctx, prefix+"/missing.txt")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		key := prefix + "/gone.txt"
+		require.NoError(t, store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"))
+		require.NoError(t, store.Delete(ctx, key))
+
+		_, err := store.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Deleting twice is not an error
+		assert.NoError(t, store.Delete(ctx, key))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		dir := prefix + "/list/"
+		require.NoError(t, store.Put(ctx, dir+"a.txt", strings.NewReader("a"), 1, "text/plain"))
+		require.NoError(t, store.Put(ctx, dir+"sub/b.txt", strings.NewReader("bb"), 2, "text/plain"))
+
+		sizes := map[string]int64{}
+		require.NoError(t, store.List(ctx, dir, func(info ObjectInfo) error {
+			sizes[info.Key] = info.Size
+			assert.WithinDuration(t, time.Now(), info.ModTime, time.Minute)
+			return nil
+		}))
+		assert.Equal(t, map[string]int64{dir + "a.txt": 1, dir + "sub/b.txt": 2}, sizes)
+
+		stop := fmt.Errorf("stop")
+		calls := 0
+		err := store.List(ctx, dir, func(ObjectInfo) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+
+		assert.NoError(t, store.List(ctx, prefix+"/none/", func(ObjectInfo) error {
+			t.Error("nothing is stored under this prefix")
+			return nil
+		}))
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "tasks/../../secret", "tasks//x", "tasks/./x", `tasks\x`} {
+			assert.ErrorIs(t, store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"), ErrInvalidKey, key)
+			_, err := store.Get(ctx, key)
+			assert.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	testStore(t, store)
+}
+
+// TestS3Store runs against MinIO on localhost:9000 with its default
+// credentials, e.g. `docker compose --profile s3 up minio`
+func TestS3Store(t *testing.T) {
+	conn, err := net.DialTimeout("tcp", "localhost:9000", time.Second)
+	if err != nil {
+		t.Skip("MinIO not available, skipping test")
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store, err := NewS3Store(ctx, &config.ArtifactConfig{
+		S3Endpoint:  "localhost:9000",
+		S3Bucket:    "task-artifacts-test",
+		S3Region:    "us-east-1",
+		S3AccessKey: "minioadmin",
+		S3SecretKey: "minioadmin",
+	})
+	require.NoError(t, err)
+
+	testStore(t, store)
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"result.json", "report-2024_01.pdf", "a"} {
+		assert.True(t, ValidName(name), name)
+	}
+	for _, name := range []string{"", ".hidden", "../x", "a/b", "with space", strings.Repeat("a", 129)} {
+		assert.False(t, ValidName(name), name)
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_SetGetDelete(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	val, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, val, "miss should return nil")
+
+	require.NoError(t, store.Set(ctx, "k", []byte("v"), time.Minute))
+	val, err = store.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), val)
+
+	require.NoError(t, store.Delete(ctx, "k"))
+	val, err = store.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Nil(t, val)
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "k", []byte("v"), 20*time.Millisecond))
+	time.Sleep(40 * time.Millisecond)
+
+	val, err := store.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Nil(t, val, "expired entry should be a miss")
+}
+
+func TestTaskCache_Invalidate(t *testing.T) {
+	c := NewTaskCache(NewMemoryStore())
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, TaskKey(7), map[string]string{"status": "PENDING"}))
+	require.NoError(t, c.Set(ctx, UserTasksKey(3), []int{7}))
+
+	require.NoError(t, c.Invalidate(ctx, 7, 3))
+
+	val, _ := c.Get(ctx, TaskKey(7))
+	assert.Nil(t, val)
+	val, _ = c.Get(ctx, UserTasksKey(3))
+	assert.Nil(t, val)
+}
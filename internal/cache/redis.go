@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"task_handler/internal/config"
+	"task_handler/internal/observability"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
@@ -23,6 +24,8 @@ func SetupRedis(redisCfg *config.RedisConfig) *redis.Client {
 		Password: redisCfg.RedisPassword,
 		DB:       port,
 	})
+	// Commands sent for a traced request or task become spans
+	rdb.AddHook(observability.RedisHook{})
 
 	// Test connection
 	ctx := context.Background()
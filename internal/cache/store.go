@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// Cache backends selectable through CACHE_BACKEND
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+// Store is the key/value backend behind TaskCache
+type Store interface {
+	// Get returns nil, nil on a cache miss
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Setup builds the task cache for the configured backend. redisClient is
+// only used, and must only be non-nil, for the redis backend.
+func Setup(cfg *config.CacheConfig, redisClient *redis.Client) *TaskCache {
+	switch cfg.Backend {
+	case BackendMemory:
+		logrus.Info("Using in-memory task cache")
+		return NewTaskCache(NewMemoryStore())
+	case BackendRedis, "":
+		if redisClient == nil {
+			logrus.Fatal("Redis cache backend selected but no Redis client configured")
+		}
+		return NewTaskCache(NewRedisStore(redisClient))
+	default:
+		logrus.Fatal(fmt.Sprintf("Unknown CACHE_BACKEND %q", cfg.Backend))
+		return nil
+	}
+}
+
+// RedisStore keeps cache entries in Redis, shared by every API replica
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil // Cache miss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
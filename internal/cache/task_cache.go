@@ -5,30 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 const TaskCacheTTL = 1 * time.Hour
 
 type TaskCache struct {
-	client *redis.Client
+	store Store
 }
 
-func NewTaskCache(client *redis.Client) *TaskCache {
-	return &TaskCache{client: client}
+func NewTaskCache(store Store) *TaskCache {
+	return &TaskCache{store: store}
 }
 
 // Get task from cache
 func (c *TaskCache) Get(ctx context.Context, key string) ([]byte, error) {
-	val, err := c.client.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return nil, nil // Cache miss
-	}
-	if err != nil {
-		return nil, err
-	}
-	return []byte(val), nil
+	return c.store.Get(ctx, key)
 }
 
 // Set task to cache with TTL
@@ -37,7 +28,13 @@ func (c *TaskCache) Set(ctx context.Context, key string, data interface{}) error
 	if err != nil {
 		return err
 	}
-	return c.client.Set(ctx, key, jsonData, TaskCacheTTL).Err()
+	return c.store.Set(ctx, key, jsonData, TaskCacheTTL)
+}
+
+// Invalidate drops the cached copies of a task and of its owner's task
+// list, so the next read sees the current status
+func (c *TaskCache) Invalidate(ctx context.Context, taskID, userID int) error {
+	return c.store.Delete(ctx, TaskKey(taskID), UserTasksKey(userID))
 }
 
 // Build cache key for single task
@@ -2,6 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
@@ -9,10 +15,29 @@ type Config struct {
 	AppEnv  string
 	AppPort string
 
-	DB       DBConfig
-	Redis    RedisConfig
-	RabbitMQ RabbitMQConfig
-	JWT      JWTConfig
+	DB        DBConfig
+	Redis     RedisConfig
+	RabbitMQ  RabbitMQConfig
+	Queue     QueueConfig
+	Cache     CacheConfig
+	RateLimit RateLimitConfig
+	Artifact  ArtifactConfig
+	Share     ShareConfig
+	Upload    UploadConfig
+	Image     ImageConfig
+	Report    ReportConfig
+	Mail      MailConfig
+	Retention RetentionConfig
+	Exec      ExecConfig
+	Outbound  OutboundConfig
+	Plugin    PluginConfig
+	TaskLog   TaskLogConfig
+	Log       LogConfig
+	Metrics   MetricsConfig
+	Tracing   TracingConfig
+	JWT       JWTConfig
+	HTTP      HTTPConfig
+	Worker    WorkerConfig
 }
 
 type DBConfig struct {
@@ -22,6 +47,8 @@ type DBConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+
+	AutoMigrate bool // Apply embedded migrations on startup
 }
 
 type RedisConfig struct {
@@ -32,18 +59,175 @@ type RedisConfig struct {
 }
 
 type RabbitMQConfig struct {
-	URL string
+	URL               string
+	PublisherPoolSize int           // Number of confirm-mode channels kept for publishing
+	ConfirmTimeout    time.Duration // Max wait for the broker to confirm a publish
+}
+
+type QueueConfig struct {
+	Backend           string        // rabbitmq, postgres, redis or memory
+	PollInterval      time.Duration // Postgres/Redis backends: wait between polls of an empty queue
+	VisibilityTimeout time.Duration // Postgres/Redis backends: how long a claimed message stays invisible to other consumers
+	StreamGroup       string        // Redis backend: consumer group shared by all workers
+	StreamMaxLen      int64         // Redis backend: unfinished messages a stream may hold before publishing fails, 0 for no limit
+}
+
+type CacheConfig struct {
+	Backend string // redis or memory (single process only)
+}
+
+type RateLimitConfig struct {
+	Backend string // redis, memory (single process only) or none
+}
+
+type ArtifactConfig struct {
+	Backend     string // local or s3
+	Dir         string // Local backend: root directory, shared by API and workers
+	InlineLimit int    // Results whose JSON is larger than this many bytes are stored as an artifact
+
+	S3Endpoint  string // S3 backend: host[:port] of any S3-compatible service, e.g. MinIO
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+type ShareConfig struct {
+	Secret     string        // HMAC key for share links
+	DefaultTTL time.Duration // Lifetime of a link when the request does not set one
+	MaxTTL     time.Duration // Longest lifetime a link may be created with
+	BaseURL    string        // Prefix for share URLs, e.g. https://tasks.example.com; relative URLs when empty
+}
+
+type UploadConfig struct {
+	MaxBytes     int64         // Largest accepted file
+	AllowedTypes []string      // Sniffed media types accepted, e.g. image/png
+	GCGrace      time.Duration // Age after which an upload no unfinished task refers to is deleted
+	GCInterval   time.Duration // How often workers look for such uploads, 0 disables
+}
+
+// ImageConfig bounds the resize_image task
+type ImageConfig struct {
+	MaxPixels    int // Largest source image, in pixels, that is decoded
+	MaxDimension int // Largest output width or height
+	JPEGQuality  int // Default JPEG quality, 1-100
+}
+
+// ReportConfig bounds the generate_report task
+type ReportConfig struct {
+	MaxRange     time.Duration // Longest date range one report may cover
+	DefaultRange time.Duration // Range ending now when the task gives none
+}
+
+// MailConfig selects how send_email delivers mail
+type MailConfig struct {
+	Backend     string // smtp, or log to only log messages
+	From        string
+	TemplateDir string // Templates here add to or replace the built-in ones
+	SMTPHost    string
+	SMTPPort    int
+	SMTPUser    string
+	SMTPPass    string
+	SMTPTLS     string // starttls, tls (implicit) or none
+	SMTPTimeout time.Duration
+}
+
+// RetentionConfig is the policy cleanup_temp enforces on one user's data
+// and the worker's periodic sweep on everyone's. A zero duration keeps data
+// forever.
+type RetentionConfig struct {
+	Results       time.Duration            // Age after which a finished task's result and artifacts go
+	ResultsByType map[string]time.Duration // Overrides Results per task type
+	Uploads       time.Duration            // Age after which an upload no unfinished task refers to goes
+	ByUser        map[int]time.Duration    // Overrides both, and the type rules, per user
+	OrphanGrace   time.Duration            // Age after which a stored object without a database row goes
+	BatchSize     int                      // Items handled per query
+	MaxPerRun     int                      // Items of each kind one run removes at most
+	Interval      time.Duration            // How often the worker sweeps every user's data, 0 disables
+}
+
+// ExecConfig bounds the exec task, which only runs commands listed in
+// CommandsFile
+type ExecConfig struct {
+	CommandsFile string        // JSON allowlist; exec is disabled when empty
+	WorkDir      string        // Parent of the per-task working directories
+	Timeout      time.Duration // Default wall-clock limit of one command
+	MaxOutput    int64         // Bytes of stdout and of stderr kept each
+	EnvAllow     []string      // Worker environment variables passed on
+	CPUSeconds   int           // RLIMIT_CPU, 0 for none
+	MemoryBytes  int64         // RLIMIT_AS, 0 for none
+	OpenFiles    int           // RLIMIT_NOFILE, 0 for none
+}
+
+// OutboundConfig bounds the http_request task. Only hosts matching
+// AllowedHosts can be called.
+type OutboundConfig struct {
+	AllowedHosts []string      // "host", "host:port" or "*.domain"; http_request is disabled when empty
+	Timeout      time.Duration // Longest a request may take, also the default
+	MaxRequest   int64         // Largest request body
+	MaxResponse  int64         // Bytes of the response body kept in the result
+}
+
+// PluginConfig locates the WebAssembly task handlers and bounds each run
+type PluginConfig struct {
+	Dir            string        // <task_type>.wasm files; plugins are disabled when empty
+	ReloadInterval time.Duration // How often Dir is checked for changes, 0 to load once
+	Timeout        time.Duration // Wall-clock limit of one run
+	MaxMemory      int64         // Linear memory of one run
+	MaxOutput      int64         // Largest JSON output
+}
+
+// TaskLogConfig bounds the log lines stored for each task attempt
+type TaskLogConfig struct {
+	MaxLines      int           // Lines stored per attempt; later ones are counted and dropped
+	MaxLineBytes  int           // Longer messages are cut
+	FlushInterval time.Duration // How often a running task's lines are written
+	FollowTimeout time.Duration // Longest a follow request stays open
+}
+
+type LogConfig struct {
+	Format string // "text" or "json"
+	Level  string // Any logrus level name, e.g. "debug" or "warn"
+}
+
+type MetricsConfig struct {
+	Enabled bool   // Serve /metrics
+	Addr    string // Listen address of the metrics server, empty for the binary's default
+}
+
+type TracingConfig struct {
+	Exporter    string  // "none", "otlp" or "stdout"
+	File        string  // Where the stdout exporter writes, stdout when empty
+	ServiceName string  // Overrides the binary's default service name
+	SampleRatio float64 // Share of new traces recorded; requests carrying a trace follow their parent
 }
 
 type JWTConfig struct {
 	Secret string
 }
 
+type HTTPConfig struct {
+	Addr            string        // Listen address, e.g. ":8087"
+	ReadTimeout     time.Duration // Max time to read the full request including body
+	WriteTimeout    time.Duration // Max time to write the response
+	IdleTimeout     time.Duration // Max time to keep an idle keep-alive connection
+	RequestTimeout  time.Duration // Deadline for the work done by one request (DB, cache, broker)
+	ShutdownTimeout time.Duration // Grace period for in-flight requests on SIGTERM
+}
+
+type WorkerConfig struct {
+	Concurrency  int           // Number of consumers started by the worker binary
+	DrainTimeout time.Duration // How long in-flight tasks may run after SIGTERM
+}
+
 func Load() *Config {
+	appPort := getEnv("APP_PORT", "8087")
+
 	return &Config{
 		AppName: os.Getenv("APP_NAME"),
 		AppEnv:  os.Getenv("APP_ENV"),
-		AppPort: os.Getenv("APP_PORT"),
+		AppPort: appPort,
 
 		DB: DBConfig{
 			Host:     os.Getenv("DB_HOST"),
@@ -52,6 +236,8 @@ func Load() *Config {
 			Password: os.Getenv("DB_PASSWORD"),
 			Name:     os.Getenv("DB_NAME"),
 			SSLMode:  os.Getenv("DB_SSLMODE"),
+
+			AutoMigrate: getEnvBool("DB_AUTO_MIGRATE", true),
 		},
 
 		Redis: RedisConfig{
@@ -62,11 +248,279 @@ func Load() *Config {
 		},
 
 		RabbitMQ: RabbitMQConfig{
-			URL: os.Getenv("RABBITMQ_URL"),
+			URL:               os.Getenv("RABBITMQ_URL"),
+			PublisherPoolSize: getEnvInt("RABBITMQ_PUBLISHER_POOL_SIZE", 8),
+			ConfirmTimeout:    getEnvDuration("RABBITMQ_CONFIRM_TIMEOUT", 5*time.Second),
+		},
+
+		Queue: QueueConfig{
+			Backend:           getEnv("QUEUE_BACKEND", "rabbitmq"),
+			PollInterval:      getEnvDuration("QUEUE_POLL_INTERVAL", 500*time.Millisecond),
+			VisibilityTimeout: getEnvDuration("QUEUE_VISIBILITY_TIMEOUT", 5*time.Minute),
+			StreamGroup:       getEnv("REDIS_STREAM_GROUP", "workers"),
+			StreamMaxLen:      int64(getEnvInt("REDIS_STREAM_MAX_LEN", 100000)),
+		},
+
+		Cache: CacheConfig{
+			Backend: getEnv("CACHE_BACKEND", "redis"),
+		},
+
+		RateLimit: RateLimitConfig{
+			Backend: getEnv("RATE_LIMIT_BACKEND", "redis"),
+		},
+
+		Artifact: ArtifactConfig{
+			Backend:     getEnv("ARTIFACT_BACKEND", "local"),
+			Dir:         getEnv("ARTIFACT_DIR", "./data/artifacts"),
+			InlineLimit: getEnvInt("RESULT_INLINE_MAX_BYTES", 64*1024),
+			S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+			S3Bucket:    getEnv("S3_BUCKET", "task-artifacts"),
+			S3Region:    getEnv("S3_REGION", "us-east-1"),
+			S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+			S3UseSSL:    getEnvBool("S3_USE_SSL", false),
+		},
+
+		Share: ShareConfig{
+			Secret:     getEnv("SHARE_LINK_SECRET", os.Getenv("JWT_SECRET")),
+			DefaultTTL: getEnvDuration("SHARE_LINK_TTL", 24*time.Hour),
+			MaxTTL:     getEnvDuration("SHARE_LINK_MAX_TTL", 7*24*time.Hour),
+			BaseURL:    os.Getenv("PUBLIC_BASE_URL"),
+		},
+
+		Upload: UploadConfig{
+			MaxBytes:     int64(getEnvInt("UPLOAD_MAX_BYTES", 20<<20)),
+			AllowedTypes: getEnvList("UPLOAD_ALLOWED_TYPES", []string{"image/jpeg", "image/png", "image/gif", "application/pdf", "text/plain"}),
+			GCGrace:      getEnvDuration("UPLOAD_GC_GRACE", 24*time.Hour),
+			GCInterval:   getEnvDuration("UPLOAD_GC_INTERVAL", 10*time.Minute),
+		},
+
+		Image: ImageConfig{
+			MaxPixels:    getEnvInt("IMAGE_MAX_PIXELS", 50_000_000),
+			MaxDimension: getEnvInt("IMAGE_MAX_DIMENSION", 4096),
+			JPEGQuality:  getEnvInt("IMAGE_JPEG_QUALITY", 85),
+		},
+
+		Report: ReportConfig{
+			MaxRange:     getEnvDuration("REPORT_MAX_RANGE", 366*24*time.Hour),
+			DefaultRange: getEnvDuration("REPORT_DEFAULT_RANGE", 30*24*time.Hour),
+		},
+
+		Mail: MailConfig{
+			Backend:     getEnv("MAIL_BACKEND", "log"),
+			From:        getEnv("MAIL_FROM", "noreply@localhost"),
+			TemplateDir: getEnv("MAIL_TEMPLATE_DIR", ""),
+			SMTPHost:    getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:    getEnvInt("SMTP_PORT", 587),
+			SMTPUser:    getEnv("SMTP_USERNAME", ""),
+			SMTPPass:    getEnv("SMTP_PASSWORD", ""),
+			SMTPTLS:     getEnv("SMTP_TLS", "starttls"),
+			SMTPTimeout: getEnvDuration("SMTP_TIMEOUT", 30*time.Second),
+		},
+
+		Retention: RetentionConfig{
+			Results:       getEnvDuration("RETENTION_RESULTS", 30*24*time.Hour),
+			ResultsByType: getEnvDurations("RETENTION_RESULTS_BY_TYPE"),
+			Uploads:       getEnvDuration("RETENTION_UPLOADS", getEnvDuration("UPLOAD_GC_GRACE", 24*time.Hour)),
+			ByUser:        getEnvUserDurations("RETENTION_BY_USER"),
+			OrphanGrace:   getEnvDuration("RETENTION_ORPHAN_GRACE", time.Hour),
+			BatchSize:     getEnvInt("RETENTION_BATCH_SIZE", 100),
+			MaxPerRun:     getEnvInt("RETENTION_MAX_PER_RUN", 1000),
+			Interval:      getEnvDuration("RETENTION_INTERVAL", time.Hour),
+		},
+
+		Exec: ExecConfig{
+			CommandsFile: getEnv("EXEC_COMMANDS_FILE", ""),
+			WorkDir:      getEnv("EXEC_WORK_DIR", filepath.Join(os.TempDir(), "task-exec")),
+			Timeout:      getEnvDuration("EXEC_TIMEOUT", 5*time.Minute),
+			MaxOutput:    int64(getEnvInt("EXEC_MAX_OUTPUT_BYTES", 10<<20)),
+			EnvAllow:     getEnvList("EXEC_ENV_ALLOW", []string{"PATH", "LANG", "TZ"}),
+			CPUSeconds:   getEnvInt("EXEC_CPU_SECONDS", 60),
+			MemoryBytes:  int64(getEnvInt("EXEC_MEMORY_BYTES", 1<<30)),
+			OpenFiles:    getEnvInt("EXEC_OPEN_FILES", 256),
+		},
+
+		Outbound: OutboundConfig{
+			AllowedHosts: getEnvList("OUTBOUND_ALLOWED_HOSTS", nil),
+			Timeout:      getEnvDuration("OUTBOUND_TIMEOUT", 30*time.Second),
+			MaxRequest:   int64(getEnvInt("OUTBOUND_MAX_REQUEST_BYTES", 1<<20)),
+			MaxResponse:  int64(getEnvInt("OUTBOUND_MAX_RESPONSE_BYTES", 64<<10)),
+		},
+
+		Plugin: PluginConfig{
+			Dir:            getEnv("PLUGIN_DIR", ""),
+			ReloadInterval: getEnvDuration("PLUGIN_RELOAD_INTERVAL", 10*time.Second),
+			Timeout:        getEnvDuration("PLUGIN_TIMEOUT", 30*time.Second),
+			MaxMemory:      int64(getEnvInt("PLUGIN_MAX_MEMORY_BYTES", 64<<20)),
+			MaxOutput:      int64(getEnvInt("PLUGIN_MAX_OUTPUT_BYTES", 1<<20)),
+		},
+
+		TaskLog: TaskLogConfig{
+			MaxLines:      getEnvInt("TASK_LOG_MAX_LINES", 1000),
+			MaxLineBytes:  getEnvInt("TASK_LOG_MAX_LINE_BYTES", 2048),
+			FlushInterval: getEnvDuration("TASK_LOG_FLUSH_INTERVAL", time.Second),
+			FollowTimeout: getEnvDuration("TASK_LOG_FOLLOW_TIMEOUT", 10*time.Minute),
+		},
+
+		Log: LoadLog(),
+
+		Metrics: MetricsConfig{
+			Enabled: getEnvBool("METRICS_ENABLED", true),
+			Addr:    getEnv("METRICS_ADDR", ""),
+		},
+
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			File:        os.Getenv("TRACING_FILE"),
+			ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+			SampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
 		},
 
 		JWT: JWTConfig{
 			Secret: os.Getenv("JWT_SECRET"),
 		},
+
+		HTTP: HTTPConfig{
+			Addr:            getEnv("HTTP_ADDR", ":"+appPort),
+			ReadTimeout:     getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:    getEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:     getEnvDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			RequestTimeout:  getEnvDuration("HTTP_REQUEST_TIMEOUT", 10*time.Second),
+			ShutdownTimeout: getEnvDuration("HTTP_SHUTDOWN_TIMEOUT", 20*time.Second),
+		},
+
+		Worker: WorkerConfig{
+			Concurrency:  getEnvInt("WORKER_CONCURRENCY", 3),
+			DrainTimeout: getEnvDuration("WORKER_DRAIN_TIMEOUT", 30*time.Second),
+		},
+	}
+}
+
+// LoadLog reads only the log settings, so a binary can set up logging
+// before anything else logs
+func LoadLog() LogConfig {
+	return LogConfig{
+		Format: getEnv("LOG_FORMAT", "text"),
+		Level:  getEnv("LOG_LEVEL", "info"),
+	}
+}
+
+// getEnv reads a string variable, falling back when unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt reads an integer variable, falling back when unset or invalid
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Warnf("Invalid value for %s (%q), using default %d", key, value, fallback)
+		return fallback
+	}
+
+	return n
+}
+
+// getEnvFloat reads a decimal variable, falling back when unset or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logrus.Warnf("Invalid value for %s (%q), using default %g", key, value, fallback)
+		return fallback
+	}
+
+	return f
+}
+
+// getEnvBool reads a boolean variable such as "true" or "0", falling back
+// when unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Invalid value for %s (%q), using default %t", key, value, fallback)
+		return fallback
+	}
+
+	return b
+}
+
+// getEnvList reads a comma separated variable, falling back when unset
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvDuration reads a duration variable such as "30s" or "2m",
+// falling back when unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Warnf("Invalid value for %s (%q), using default %s", key, value, fallback)
+		return fallback
+	}
+
+	return d
+}
+
+// getEnvDurations reads a comma separated list of name=duration pairs such
+// as "generate_report=168h,resize_image=720h". Invalid pairs are skipped.
+func getEnvDurations(key string) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, item := range getEnvList(key, nil) {
+		name, value, ok := strings.Cut(item, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			logrus.Warnf("Invalid entry in %s (%q), ignoring it", key, item)
+			continue
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations
+}
+
+// getEnvUserDurations is getEnvDurations keyed by user ID
+func getEnvUserDurations(key string) map[int]time.Duration {
+	durations := make(map[int]time.Duration)
+	for name, d := range getEnvDurations(key) {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			logrus.Warnf("Invalid user ID in %s (%q), ignoring it", key, name)
+			continue
+		}
+		durations[id] = d
 	}
+	return durations
 }
@@ -5,25 +5,28 @@ import (
 	"fmt"
 	"log"
 	"task_handler/internal/config"
+	"task_handler/internal/observability"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 func Init(DBCfg *config.DBConfig) *sql.DB {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", DBCfg.Host, DBCfg.Port, DBCfg.User, DBCfg.Password, DBCfg.Name, DBCfg.SSLMode)
 
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	// Queries made for a traced request or task become spans
+	connConfig.Tracer = observability.QueryTracer{}
+
 	var db *sql.DB
-	var err error
 
 	maxRetries := 5
 	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("pgx", dsn)
-		if err != nil {
-			log.Printf("Failed to open database connection (attempt %d/%d): %v", i+1, maxRetries, err)
-			time.Sleep(time.Duration(i+1) * time.Second)
-			continue
-		}
+		db = stdlib.OpenDB(*connConfig)
 
 		if err = db.Ping(); err != nil {
 			log.Printf("Failed to ping database (attempt %d/%d): %v", i+1, maxRetries, err)
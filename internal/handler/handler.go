@@ -2,45 +2,101 @@ package handler
 
 import (
 	"database/sql"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
 	"task_handler/internal/config"
 	"task_handler/internal/middleware"
+	"task_handler/internal/queue"
+	"task_handler/internal/share"
 	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"task_handler/internal/upload"
 	"task_handler/internal/user"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
-	"github.com/rabbitmq/amqp091-go"
 )
 
-// SetupHandler initializes all dependencies and routes
-func SetupHandler(db *sql.DB, conn *amqp091.Connection, redisClient *redis.Client, cfg *config.Config) *gin.Engine {
+// Services bundles the backing services the HTTP layer depends on. Redis is
+// nil when neither the cache nor the rate limiter uses it, and a nil Limiter
+// disables rate limiting.
+type Services struct {
+	DB        *sql.DB
+	Broker    queue.Broker
+	Redis     *redis.Client
+	Cache     *cache.TaskCache
+	Limiter   middleware.Limiter
+	Artifacts artifact.Store
+}
+
+// SetupHandler initializes all dependencies and routes, picking the cache,
+// rate limiter and artifact store backends from cfg
+func SetupHandler(db *sql.DB, broker queue.Broker, redisClient *redis.Client, cfg *config.Config) *gin.Engine {
+	return NewRouter(&Services{
+		DB:        db,
+		Broker:    broker,
+		Redis:     redisClient,
+		Cache:     cache.Setup(&cfg.Cache, redisClient),
+		Limiter:   middleware.SetupLimiter(&cfg.RateLimit, redisClient),
+		Artifacts: artifact.Setup(&cfg.Artifact),
+	}, cfg)
+}
 
-	r := gin.Default()
+// NewRouter builds the routes on top of already constructed services, so a
+// caller can share them with other components such as an embedded worker pool
+func NewRouter(svc *Services, cfg *config.Config) *gin.Engine {
+
+	// Access lines go through logrus, so they follow LOG_FORMAT and carry
+	// the request ID
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Tracing(), middleware.AccessLog(), gin.Recovery())
+	if cfg.Metrics.Enabled {
+		r.Use(middleware.Metrics())
+	}
+	if cfg.HTTP.RequestTimeout > 0 {
+		r.Use(middleware.RequestTimeout(cfg.HTTP.RequestTimeout, followsTaskLog))
+	}
+
+	r.GET("/health", healthCheck(svc.DB, svc.Broker, svc.Redis))
 
 	// Initialize repositories
 	userRepo := user.NewUserRepository()
 	taskRepo := task.NewTaskRepository()
 
 	// Initialize services
-	userService := user.NewUserService(userRepo, db)
-	taskService := task.NewTaskService(taskRepo, db, conn, redisClient)
+	userService := user.NewUserService(userRepo, svc.DB)
+	taskService := task.NewTaskService(taskRepo, svc.DB, svc.Broker, svc.Cache, svc.Artifacts)
+	shareService := share.NewShareService(share.NewShareRepository(), svc.DB, taskService, cfg.Share)
+	uploadService := upload.NewUploadService(upload.NewUploadRepository(), svc.DB, svc.Artifacts, cfg.Upload)
+	taskLogService := tasklog.NewTaskLogService(tasklog.NewTaskLogRepository(), svc.DB, taskService)
 
 	// Initialize controllers
 	userController := user.NewUserController(userService, cfg.JWT.Secret)
 	taskController := task.NewTaskController(taskService)
+	shareController := share.NewShareController(shareService)
+	uploadController := upload.NewUploadController(uploadService, cfg.Upload.MaxBytes)
+	taskLogController := tasklog.NewTaskLogController(taskLogService, cfg.TaskLog)
 
 	// Setup routes
-	setupRoutes(r, userController, taskController, redisClient, cfg.JWT.Secret)
+	setupRoutes(r, userController, taskController, shareController, uploadController, taskLogController, svc.Limiter, cfg.JWT.Secret)
 
 	return r
 }
 
+// followsTaskLog matches log follow requests, which stream for longer than
+// the request timeout allows
+func followsTaskLog(c *gin.Context) bool {
+	return c.FullPath() == "/api/v1/tasks/:id/logs" && tasklog.Follows(c)
+}
+
 // setupRoutes configures all application routes
-func setupRoutes(r *gin.Engine, userCtrl *user.UserController, taskCtrl *task.TaskController, redisClient *redis.Client, jwtSecret string) {
+func setupRoutes(r *gin.Engine, userCtrl *user.UserController, taskCtrl *task.TaskController, shareCtrl *share.ShareController, uploadCtrl *upload.UploadController, taskLogCtrl *tasklog.TaskLogController, limiter middleware.Limiter, jwtSecret string) {
 
-	// Public routes - Authentication
+	// Public routes - Authentication, limited per client IP
 	authGroup := r.Group("/auth")
-	authGroup.Use(middleware.RateLimiterMiddleware(redisClient, middleware.StrictRateLimiter()))
+	if limiter != nil {
+		authGroup.Use(middleware.IPRateLimiterMiddleware(limiter, middleware.StrictRateLimiter()))
+	}
 
 	{
 		authGroup.POST("/register", userCtrl.Register)
@@ -48,14 +104,37 @@ func setupRoutes(r *gin.Engine, userCtrl *user.UserController, taskCtrl *task.Ta
 		authGroup.POST("/refresh", userCtrl.RefreshToken)
 	}
 
-	// Protected routes - API v1
+	// Public share links, authorized by their signature and limited per client IP
+	shared := r.Group("/shared")
+	if limiter != nil {
+		shared.Use(middleware.IPRateLimiterMiddleware(limiter, middleware.DefaultRateLimiterConfig()))
+	}
+	{
+		shared.GET("/:share_id", shareCtrl.Download)
+	}
+
+	// Protected routes - API v1, limited per user once authenticated
 	api := r.Group("/api/v1")
-	api.Use(middleware.RateLimiterMiddleware(redisClient, middleware.DefaultRateLimiterConfig()))
 	api.Use(middleware.AuthMiddleware(jwtSecret))
+	if limiter != nil {
+		api.Use(middleware.UserRateLimiterMiddleware(limiter, middleware.DefaultRateLimiterConfig()))
+	}
 	{
 		// Task endpoints
 		api.POST("/tasks", taskCtrl.CreateTask)
 		api.GET("/tasks/:id", taskCtrl.GetTask)
+		api.GET("/tasks/:id/result", taskCtrl.GetTaskResult)
+		api.GET("/tasks/:id/artifacts/:name", taskCtrl.DownloadArtifact)
+		api.GET("/tasks/:id/logs", taskLogCtrl.GetLogs)
+
+		// Share links
+		api.POST("/tasks/:id/artifacts/:name/share", shareCtrl.CreateShare)
+		api.GET("/tasks/:id/shares", shareCtrl.ListShares)
+		api.DELETE("/shares/:share_id", shareCtrl.RevokeShare)
+
+		// Task input files
+		api.POST("/uploads", uploadCtrl.CreateUpload)
+
 		api.GET("/users/tasks", taskCtrl.GetTasksByUser)
 	}
 }
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"task_handler/internal/queue"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// healthCheck reports the state of every backing service. It answers 503
+// while any of them is unavailable, e.g. while the queue broker reconnects.
+// Redis is only checked when the API uses it.
+func healthCheck(db *sql.DB, broker queue.Broker, redisClient *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		healthy := true
+		checks := gin.H{}
+
+		if err := db.PingContext(ctx); err != nil {
+			healthy = false
+			checks["postgres"] = err.Error()
+		} else {
+			checks["postgres"] = "ok"
+		}
+
+		if redisClient != nil {
+			if err := redisClient.Ping(ctx).Err(); err != nil {
+				healthy = false
+				checks["redis"] = err.Error()
+			} else {
+				checks["redis"] = "ok"
+			}
+		}
+
+		if err := broker.Ping(ctx); err != nil {
+			healthy = false
+			checks["queue"] = err.Error()
+		} else {
+			checks["queue"] = "ok"
+		}
+
+		status := http.StatusOK
+		if !healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		c.JSON(status, gin.H{
+			"healthy": healthy,
+			"checks":  checks,
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package logger configures the process-wide logrus logger and carries the
+// request ID that correlates an HTTP request with the tasks it created.
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup applies LOG_FORMAT and LOG_LEVEL to the standard logger
+func Setup(cfg *config.LogConfig) {
+	if err := Configure(logrus.StandardLogger(), *cfg); err != nil {
+		logrus.Fatalf("Invalid log configuration: %v", err)
+	}
+}
+
+// Configure sets the formatter and level of l
+func Configure(l *logrus.Logger, cfg config.LogConfig) error {
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+
+	switch strings.ToLower(cfg.Format) {
+	case FormatText, "":
+		l.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+	case FormatJSON:
+		l.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime: "time",
+				logrus.FieldKeyMsg:  "message",
+			},
+		})
+	default:
+		return fmt.Errorf("LOG_FORMAT must be %q or %q, got %q", FormatText, FormatJSON, cfg.Format)
+	}
+
+	l.SetLevel(level)
+	return nil
+}
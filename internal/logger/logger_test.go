@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"task_handler/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestConfigure_JSON(t *testing.T) {
+	l := logrus.New()
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	require.NoError(t, Configure(l, config.LogConfig{Format: "json", Level: "warn"}))
+
+	l.Info("dropped")
+	l.WithField("task_id", 3).Warn("kept")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "kept", line["message"])
+	assert.Equal(t, "warning", line["level"])
+	assert.Equal(t, float64(3), line["task_id"])
+	assert.Contains(t, line, "time")
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	l := logrus.New()
+	assert.Error(t, Configure(l, config.LogConfig{Format: "xml", Level: "info"}))
+	assert.Error(t, Configure(l, config.LogConfig{Format: "text", Level: "loud"}))
+	assert.NoError(t, Configure(l, config.LogConfig{Format: "TEXT", Level: "debug"}))
+	assert.Equal(t, logrus.DebugLevel, l.GetLevel())
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "abc-123")
+	assert.Equal(t, "abc-123", RequestID(ctx))
+	assert.Equal(t, "abc-123", FromContext(ctx).Data["request_id"])
+
+	assert.Empty(t, RequestID(context.Background()))
+	assert.NotContains(t, FromContext(context.Background()).Data, "request_id")
+}
+
+func TestFromContext_TraceID(t *testing.T) {
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", FromContext(ctx).Data["trace_id"])
+	assert.NotContains(t, FromContext(context.Background()).Data, "trace_id")
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID("7f3c2a9e-1b4d-4e6f-8a0b-2c4d6e8f0a1b"))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("with space"))
+	assert.False(t, ValidRequestID("line\nbreak"))
+	assert.False(t, ValidRequestID("naïve"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", 129)))
+}
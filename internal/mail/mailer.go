@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"task_handler/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mailer renders named templates and sends them from one address
+type Mailer struct {
+	sender    Sender
+	templates *Templates
+	from      string
+}
+
+func NewMailer(sender Sender, templates *Templates, from string) *Mailer {
+	return &Mailer{sender: sender, templates: templates, from: from}
+}
+
+// SetupMailer builds the mailer for the configured backend and templates
+func SetupMailer(cfg *config.MailConfig) *Mailer {
+	templates, err := LoadTemplates(cfg.TemplateDir)
+	if err != nil {
+		logrus.Fatalf("Failed to load mail templates: %v", err)
+	}
+	logrus.Infof("Loaded mail templates: %v", templates.Names())
+	return NewMailer(Setup(cfg), templates, cfg.From)
+}
+
+// NewLogMailer only logs mail rendered from the built-in templates
+func NewLogMailer() *Mailer {
+	templates, err := LoadTemplates("")
+	if err != nil {
+		logrus.Fatalf("Failed to load built-in mail templates: %v", err)
+	}
+	return NewMailer(NewLogSender(), templates, "noreply@localhost")
+}
+
+// Send renders the template with data and sends it to every address in
+// to. Render and validation errors are permanent.
+func (m *Mailer) Send(ctx context.Context, to []string, template string, data any) (*Message, error) {
+	msg, err := m.templates.Render(template, data)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	msg.From = m.from
+	msg.To = to
+	msg.ID = newMessageID(m.from)
+	if err := msg.Validate(); err != nil {
+		return nil, permanent(err)
+	}
+
+	if err := m.sender.Send(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,150 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var headerNewlines = strings.NewReplacer("\r", " ", "\n", " ")
+
+// Message is one mail with a plain text body, an HTML body or both
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+
+	// Set by WriteTo when empty
+	ID   string
+	Date time.Time
+}
+
+// Validate checks the addresses and that there is a body
+func (m *Message) Validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+	if len(m.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	for _, to := range m.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	if m.Text == "" && m.HTML == "" {
+		return fmt.Errorf("message has no body")
+	}
+	return nil
+}
+
+// WriteTo writes the message in RFC 5322 form. With both bodies it is a
+// multipart/alternative message, text first so clients prefer the HTML.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	if m.ID == "" {
+		m.ID = newMessageID(m.From)
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	// Header values are single lines
+	header := func(key, value string) {
+		value = headerNewlines.Replace(value)
+		fmt.Fprintf(bw, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", headerNewlines.Replace(m.Subject)))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", m.ID)
+	header("MIME-Version", "1.0")
+
+	var err error
+	switch {
+	case m.Text != "" && m.HTML != "":
+		mw := multipart.NewWriter(bw)
+		header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+		bw.WriteString("\r\n")
+		if err = writePart(mw, "text/plain", m.Text); err == nil {
+			if err = writePart(mw, "text/html", m.HTML); err == nil {
+				err = mw.Close()
+			}
+		}
+	case m.HTML != "":
+		err = writeBody(bw, header, "text/html", m.HTML)
+	default:
+		err = writeBody(bw, header, "text/plain", m.Text)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	return cw.n, err
+}
+
+func writePart(mw *multipart.Writer, contentType, body string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, body)
+}
+
+func writeBody(w io.Writer, header func(key, value string), contentType, body string) error {
+	header("Content-Type", contentType+"; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	return writeQuotedPrintable(w, body)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain
+func newMessageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return "<" + hex.EncodeToString(b[:]) + "@" + domain + ">"
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Validate(t *testing.T) {
+	assert.NoError(t, testMessage().Validate())
+
+	msg := testMessage()
+	msg.From = ""
+	assert.Error(t, msg.Validate())
+
+	msg = testMessage()
+	msg.To = nil
+	assert.Error(t, msg.Validate())
+
+	msg = testMessage()
+	msg.Text, msg.HTML = "", ""
+	assert.Error(t, msg.Validate())
+}
+
+func TestMessage_WriteToMultipart(t *testing.T) {
+	msg := testMessage()
+	msg.Subject = "Héllo\r\nBcc: evil@example.com"
+
+	var buf bytes.Buffer
+	n, err := msg.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	parsed, err := mail.ReadMessage(&buf)
+	require.NoError(t, err)
+	assert.Empty(t, parsed.Header.Get("Bcc"), "newlines in headers must not add headers")
+	assert.NotEmpty(t, parsed.Header.Get("Message-ID"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Héllo  Bcc: evil@example.com", subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part) // Decodes quoted-printable
+		require.NoError(t, err)
+		types = append(types, strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0])
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain", "text/html"}, types)
+	assert.Equal(t, []string{"plain body", "<p>html body</p>"}, bodies)
+}
+
+func TestMessage_WriteToSingleBody(t *testing.T) {
+	msg := testMessage()
+	msg.HTML = ""
+	msg.Text = strings.Repeat("long line ", 20)
+
+	var buf bytes.Buffer
+	_, err := msg.WriteTo(&buf)
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", parsed.Header.Get("Content-Type"))
+	assert.Equal(t, "quoted-printable", parsed.Header.Get("Content-Transfer-Encoding"))
+
+	raw, err := io.ReadAll(parsed.Body)
+	require.NoError(t, err)
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		assert.LessOrEqual(t, len(line), 76)
+	}
+}
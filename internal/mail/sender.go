@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"task_handler/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mail backends selectable through MAIL_BACKEND
+const (
+	BackendSMTP = "smtp"
+	BackendLog  = "log"
+)
+
+// Sender delivers a message to all of its recipients
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// SendError is a failed delivery. Permanent errors, such as a rejected
+// recipient, fail the same way on every attempt; the rest may succeed later.
+type SendError struct {
+	Err       error
+	Permanent bool
+}
+
+func (e *SendError) Error() string {
+	if e.Permanent {
+		return "mail: permanent failure: " + e.Err.Error()
+	}
+	return "mail: temporary failure: " + e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether retrying err is pointless
+func IsPermanent(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent
+}
+
+// permanent marks err as not worth retrying
+func permanent(err error) error {
+	return &SendError{Err: err, Permanent: true}
+}
+
+// classify turns an SMTP exchange error into a SendError. Replies with a
+// 5xx code are permanent and 4xx temporary; network errors are temporary.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return err
+	}
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return &SendError{Err: err, Permanent: reply.Code >= 500}
+	}
+	return &SendError{Err: err}
+}
+
+// Setup builds the sender for the configured backend
+func Setup(cfg *config.MailConfig) Sender {
+	switch cfg.Backend {
+	case BackendLog, "":
+		logrus.Info("Using log mail backend, messages are not delivered")
+		return NewLogSender()
+	case BackendSMTP:
+		logrus.Infof("Using SMTP mail backend at %s:%d", cfg.SMTPHost, cfg.SMTPPort)
+		sender, err := NewSMTPSender(cfg)
+		if err != nil {
+			logrus.Fatalf("Invalid SMTP configuration: %v", err)
+		}
+		return sender
+	default:
+		logrus.Fatalf("Unknown mail backend: %s", cfg.Backend)
+		return nil
+	}
+}
+
+// LogSender only logs messages, for development without a mail server
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	logrus.Infof("Mail to %v: %s", msg.To, msg.Subject)
+	return nil
+}
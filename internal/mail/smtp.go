@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"task_handler/internal/config"
+	"time"
+)
+
+// SMTP connection security selectable through SMTP_TLS
+const (
+	TLSStartTLS = "starttls" // Plain connection upgraded with STARTTLS, which is required
+	TLSImplicit = "tls"      // TLS from the first byte, usually port 465
+	TLSNone     = "none"     // Unencrypted, for a local relay only
+)
+
+// SMTPSender delivers mail through one SMTP server, opening a connection
+// per message
+type SMTPSender struct {
+	cfg       config.MailConfig
+	addr      string
+	tlsConfig *tls.Config
+}
+
+func NewSMTPSender(cfg *config.MailConfig) (*SMTPSender, error) {
+	switch cfg.SMTPTLS {
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("SMTP_TLS must be %s, %s or %s", TLSStartTLS, TLSImplicit, TLSNone)
+	}
+	if cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required")
+	}
+	// net/smtp refuses to send credentials in the clear, except to localhost
+	if cfg.SMTPTLS == TLSNone && cfg.SMTPUser != "" && !isLocalhost(cfg.SMTPHost) {
+		return nil, fmt.Errorf("SMTP_USERNAME needs SMTP_TLS=%s or %s", TLSStartTLS, TLSImplicit)
+	}
+
+	return &SMTPSender{
+		cfg:       *cfg,
+		addr:      net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		tlsConfig: &tls.Config{ServerName: cfg.SMTPHost, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	if err := msg.Validate(); err != nil {
+		return permanent(err)
+	}
+	return classify(s.send(ctx, msg))
+}
+
+func (s *SMTPSender) send(ctx context.Context, msg *Message) error {
+	if s.cfg.SMTPTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.SMTPTimeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.SMTPTLS == TLSImplicit {
+		conn, err = (&tls.Dialer{Config: s.tlsConfig}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// net/smtp has no context support; an expired deadline unblocks it
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.SMTPTLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return permanent(fmt.Errorf("server does not support STARTTLS"))
+		}
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.cfg.SMTPUser != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return permanent(fmt.Errorf("server does not support AUTH"))
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPass, s.cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(msg.From)); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := c.Rcpt(envelopeAddress(to)); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// The message is accepted once DATA succeeds; a failed QUIT must not
+	// make the task send it again
+	_ = c.Quit()
+	return nil
+}
+
+// envelopeAddress strips the display name, "Tasks <a@b>" becomes "a@b"
+func envelopeAddress(addr string) string {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return addr
+	}
+	return parsed.Address
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
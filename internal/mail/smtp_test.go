@@ -0,0 +1,285 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP is a minimal SMTP server for one test. Replies maps a command
+// (MAIL, RCPT, DATA) to the reply it gets instead of 250.
+type fakeSMTP struct {
+	ln       net.Listener
+	tls      *tls.Config
+	startTLS bool
+	replies  map[string]string
+
+	mu       sync.Mutex
+	auth     string
+	from     string
+	to       []string
+	data     string
+	usedTLS  bool
+	received chan struct{}
+}
+
+func newFakeSMTP(t *testing.T, startTLS bool, replies map[string]string) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTP{
+		ln:       ln,
+		tls:      &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}},
+		startTLS: startTLS,
+		replies:  replies,
+		received: make(chan struct{}, 1),
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer func() { conn.Close() }() // conn is replaced by STARTTLS
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		if custom, ok := s.replies[verb]; ok {
+			reply(custom)
+			continue
+		}
+
+		switch verb {
+		case "EHLO":
+			reply("250-fake")
+			if s.startTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 go ahead")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			s.mu.Lock()
+			s.usedTLS = true
+			s.mu.Unlock()
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = line
+			s.mu.Unlock()
+			reply("235 ok")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = line
+			s.mu.Unlock()
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = append(s.to, line)
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 go on")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+			s.received <- struct{}{}
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTP) sender(t *testing.T, mode, user string) *SMTPSender {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	sender, err := NewSMTPSender(&config.MailConfig{
+		SMTPHost:    host,
+		SMTPPort:    portNum,
+		SMTPUser:    user,
+		SMTPPass:    "secret",
+		SMTPTLS:     mode,
+		SMTPTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	// Trust the test certificate
+	roots := x509.NewCertPool()
+	roots.AddCert(s.tls.Certificates[0].Leaf)
+	sender.tlsConfig = &tls.Config{ServerName: host, RootCAs: roots}
+	return sender
+}
+
+// testCertificate is a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func testMessage() *Message {
+	return &Message{
+		From:    "Tasks <noreply@example.com>",
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "Hello",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+}
+
+func TestSMTPSender_StartTLSAndAuth(t *testing.T) {
+	server := newFakeSMTP(t, true, nil)
+	sender := server.sender(t, TLSStartTLS, "user")
+
+	require.NoError(t, sender.Send(context.Background(), testMessage()))
+	<-server.received
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.True(t, server.usedTLS)
+	creds, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(server.auth, "AUTH PLAIN "))
+	require.NoError(t, err)
+	assert.Equal(t, "\x00user\x00secret", string(creds))
+	assert.Equal(t, "MAIL FROM:<noreply@example.com>", server.from)
+	assert.Equal(t, []string{"RCPT TO:<alice@example.com>", "RCPT TO:<bob@example.com>"}, server.to)
+	assert.Contains(t, server.data, "Subject: Hello\r\n")
+	assert.Contains(t, server.data, "multipart/alternative")
+}
+
+func TestSMTPSender_StartTLSRequired(t *testing.T) {
+	server := newFakeSMTP(t, false, nil)
+	sender := server.sender(t, TLSStartTLS, "")
+
+	err := sender.Send(context.Background(), testMessage())
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestSMTPSender_ReplyClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		verb      string
+		reply     string
+		permanent bool
+	}{
+		{"MailboxUnavailable", "RCPT", "550 no such user", true},
+		{"MailboxBusy", "RCPT", "450 mailbox busy", false},
+		{"TooManyConnections", "MAIL", "421 try again later", false},
+		{"Rejected", "DATA", "554 transaction failed", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeSMTP(t, false, map[string]string{tc.verb: tc.reply})
+			sender := server.sender(t, TLSNone, "")
+
+			err := sender.Send(context.Background(), testMessage())
+			require.Error(t, err)
+			assert.Equal(t, tc.permanent, IsPermanent(err))
+		})
+	}
+}
+
+func TestSMTPSender_ConnectionRefusedIsTemporary(t *testing.T) {
+	server := newFakeSMTP(t, false, nil)
+	sender := server.sender(t, TLSNone, "")
+	server.ln.Close()
+
+	err := sender.Send(context.Background(), testMessage())
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err))
+}
+
+func TestSMTPSender_InvalidMessageIsPermanent(t *testing.T) {
+	server := newFakeSMTP(t, false, nil)
+	sender := server.sender(t, TLSNone, "")
+
+	msg := testMessage()
+	msg.To = []string{"not an address"}
+	err := sender.Send(context.Background(), msg)
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestNewSMTPSender_Validation(t *testing.T) {
+	_, err := NewSMTPSender(&config.MailConfig{SMTPHost: "mail.example.com", SMTPTLS: "ssl"})
+	assert.Error(t, err)
+
+	_, err = NewSMTPSender(&config.MailConfig{SMTPHost: "mail.example.com", SMTPTLS: TLSNone, SMTPUser: "user"})
+	assert.Error(t, err, "credentials must not be sent in the clear")
+
+	_, err = NewSMTPSender(&config.MailConfig{SMTPHost: "localhost", SMTPTLS: TLSNone, SMTPUser: "user"})
+	assert.NoError(t, err)
+}
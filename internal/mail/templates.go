@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+var ErrUnknownTemplate = errors.New("unknown mail template")
+
+// templateFile is <name>.<part>.tmpl, part being subject, txt or html
+var templateFile = regexp.MustCompile(`^([a-z0-9][a-z0-9_-]*)\.(subject|txt|html)\.tmpl$`)
+
+// Templates renders messages by name. A template "welcome" is made of
+// welcome.subject.tmpl and at least one of welcome.txt.tmpl (text/template)
+// and welcome.html.tmpl (html/template, which escapes the data). Referring
+// to a missing data field is an error; optional fields are read with
+// {{index . "field"}}.
+type Templates struct {
+	set map[string]*templateSet
+}
+
+type templateSet struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// LoadTemplates parses the built-in templates and then those in dir, if
+// set. A name found in dir replaces the built-in template of that name.
+func LoadTemplates(dir string) (*Templates, error) {
+	sub, _ := fs.Sub(builtinTemplates, "templates")
+	builtin, err := parseTemplates(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{set: builtin}
+	if dir == "" {
+		return t, nil
+	}
+
+	custom, err := parseTemplates(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("templates in %s: %w", dir, err)
+	}
+	for name, set := range custom {
+		t.set[name] = set
+	}
+	return t, nil
+}
+
+func parseTemplates(fsys fs.FS) (map[string]*templateSet, error) {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]*templateSet)
+	for _, file := range files {
+		m := templateFile.FindStringSubmatch(path.Base(file))
+		if m == nil {
+			return nil, fmt.Errorf("unexpected template file %s", file)
+		}
+		name, part := m[1], m[2]
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		set := sets[name]
+		if set == nil {
+			set = &templateSet{}
+			sets[name] = set
+		}
+
+		switch part {
+		case "subject":
+			set.subject, err = texttemplate.New(file).Option("missingkey=error").Parse(strings.TrimSpace(string(body)))
+		case "txt":
+			set.text, err = texttemplate.New(file).Option("missingkey=error").Parse(string(body))
+		case "html":
+			set.html, err = htmltemplate.New(file).Option("missingkey=error").Parse(string(body))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, set := range sets {
+		if set.subject == nil || (set.text == nil && set.html == nil) {
+			return nil, fmt.Errorf("template %s needs a subject and a txt or html body", name)
+		}
+	}
+	return sets, nil
+}
+
+// Names lists the available templates
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.set))
+	for name := range t.set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named template with data into a message without
+// addresses
+func (t *Templates) Render(name string, data any) (*Message, error) {
+	set, ok := t.set[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := set.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if set.text != nil {
+		if err := set.text.Execute(&text, data); err != nil {
+			return nil, err
+		}
+	}
+	if set.html != nil {
+		if err := set.html.Execute(&html, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
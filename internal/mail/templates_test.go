@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates_Builtin(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"notification", "welcome"}, templates.Names())
+
+	msg, err := templates.Render("welcome", map[string]any{"name": "<Alice>"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome, <Alice>", msg.Subject)
+	assert.Contains(t, msg.Text, "<Alice>")
+	assert.Contains(t, msg.HTML, "&lt;Alice&gt;", "the HTML body escapes data")
+}
+
+func TestTemplates_OptionalField(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	msg, err := templates.Render("notification", map[string]any{"title": "Done", "message": "Your task finished"})
+	require.NoError(t, err)
+	assert.NotContains(t, msg.HTML, "href")
+
+	msg, err = templates.Render("notification", map[string]any{
+		"title": "Done", "message": "Your task finished", "action_url": "https://example.com/tasks/1",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, msg.HTML, `href="https://example.com/tasks/1"`)
+}
+
+func TestTemplates_Errors(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+
+	_, err = templates.Render("missing", nil)
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+
+	_, err = templates.Render("welcome", map[string]any{})
+	assert.Error(t, err, "a missing field is an error")
+}
+
+func TestTemplates_Directory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644))
+	}
+	write("welcome.subject.tmpl", "Hi {{.name}}")
+	write("welcome.txt.tmpl", "Custom welcome")
+	write("invoice.subject.tmpl", "Invoice {{.number}}")
+	write("invoice.html.tmpl", "<b>{{.number}}</b>")
+
+	templates, err := LoadTemplates(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invoice", "notification", "welcome"}, templates.Names())
+
+	msg, err := templates.Render("welcome", map[string]any{"name": "Bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Bob", msg.Subject)
+	assert.Equal(t, "Custom welcome", msg.Text)
+	assert.Empty(t, msg.HTML, "a custom template replaces every part of the built-in one")
+
+	write("broken.subject.tmpl", "No body")
+	_, err = LoadTemplates(dir)
+	assert.Error(t, err)
+}
+
+// recordingSender keeps the last message instead of sending it
+type recordingSender struct {
+	msg *Message
+	err error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg *Message) error {
+	s.msg = msg
+	return s.err
+}
+
+func TestMailer_Send(t *testing.T) {
+	templates, err := LoadTemplates("")
+	require.NoError(t, err)
+	sender := &recordingSender{}
+	mailer := NewMailer(sender, templates, "Tasks <noreply@example.com>")
+
+	msg, err := mailer.Send(context.Background(), []string{"alice@example.com"}, "welcome", map[string]any{"name": "Alice"})
+	require.NoError(t, err)
+	assert.Same(t, sender.msg, msg)
+	assert.Equal(t, "Tasks <noreply@example.com>", msg.From)
+	assert.Contains(t, msg.ID, "@example.com>")
+
+	_, err = mailer.Send(context.Background(), []string{"alice@example.com"}, "welcome", map[string]any{})
+	assert.True(t, IsPermanent(err), "render errors are permanent")
+
+	_, err = mailer.Send(context.Background(), []string{"nobody"}, "welcome", map[string]any{"name": "Alice"})
+	assert.True(t, IsPermanent(err), "invalid addresses are permanent")
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often idle buckets are dropped
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens     float64
+	lastRefill time.Time
+	// refillTime is how long the bucket takes to fill up from empty
+	refillTime time.Duration
+}
+
+// MemoryLimiter is a process-local token bucket with the same refill rules
+// as rate_limiter.lua. Buckets are not shared between processes, so limits
+// apply per instance.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, config *RateLimiterConfig) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(config.Capacity)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, lastRefill: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refillTime = time.Duration(capacity / config.RefillRate * float64(time.Second))
+
+	if delta := now.Sub(bucket.lastRefill).Seconds(); delta > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+delta*config.RefillRate)
+		bucket.lastRefill = now
+	}
+
+	allowed := false
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		allowed = true
+	}
+
+	if now.Sub(l.lastSweep) >= memorySweepInterval {
+		l.sweep(now)
+	}
+
+	return allowed, nil
+}
+
+// sweep drops buckets idle long enough to have refilled completely, the
+// in-memory equivalent of the EXPIRE in the Lua script. The caller holds l.mu.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastRefill) > bucket.refillTime {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
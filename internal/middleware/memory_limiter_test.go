@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter_BurstAndRefill(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	config := &RateLimiterConfig{Capacity: 3, RefillRate: 2.0}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx, "k", config)
+		require.NoError(t, err)
+		assert.True(t, allowed, "Request %d should succeed", i+1)
+	}
+
+	allowed, _ := limiter.Allow(ctx, "k", config)
+	assert.False(t, allowed, "Request beyond burst should fail")
+
+	// 2 tokens per second: half a second buys exactly one request
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = limiter.Allow(ctx, "k", config)
+	assert.True(t, allowed, "Request should succeed after refill")
+	allowed, _ = limiter.Allow(ctx, "k", config)
+	assert.False(t, allowed)
+}
+
+func TestMemoryLimiter_SeparateKeys(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	config := &RateLimiterConfig{Capacity: 1, RefillRate: 0.1}
+	ctx := context.Background()
+
+	allowed, _ := limiter.Allow(ctx, UserRateLimiterKey(1), config)
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(ctx, UserRateLimiterKey(1), config)
+	assert.False(t, allowed)
+
+	allowed, _ = limiter.Allow(ctx, UserRateLimiterKey(2), config)
+	assert.True(t, allowed, "User 2 should not be affected by User 1's rate limit")
+}
+
+func TestMemoryLimiter_SweepIdleBuckets(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	config := &RateLimiterConfig{Capacity: 2, RefillRate: 1.0}
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "idle", config)
+	now = now.Add(2 * memorySweepInterval)
+	limiter.Allow(ctx, "active", config)
+
+	assert.NotContains(t, limiter.buckets, "idle")
+	assert.Contains(t, limiter.buckets, "active")
+}
+
+func TestIPRateLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(IPRateLimiterMiddleware(NewMemoryLimiter(), &RateLimiterConfig{Capacity: 1, RefillRate: 0.1}))
+	router.POST("/login", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	send := func(ip string) int {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1"))
+	assert.Equal(t, http.StatusOK, send("10.0.0.2"), "Other clients keep their own bucket")
+}
+
+func TestIPRateLimiterKey(t *testing.T) {
+	assert.Equal(t, "rate_limiter:ip:10.0.0.1", IPRateLimiterKey("10.0.0.1"))
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task_handler/internal/observability"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_LabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/metrics-test/:id", func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+
+	matched := observability.HTTPRequests.WithLabelValues("GET", "/metrics-test/:id", "202")
+	unmatched := observability.HTTPRequests.WithLabelValues("GET", unmatchedRoute, "404")
+	beforeMatched, beforeUnmatched := testutil.ToFloat64(matched), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/nope/3"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, beforeMatched+2, testutil.ToFloat64(matched))
+	assert.Equal(t, beforeUnmatched+1, testutil.ToFloat64(unmatched))
+}
+
+func TestRateLimit_CountsDecisions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(IPRateLimiterMiddleware(NewMemoryLimiter(), &RateLimiterConfig{Capacity: 1, RefillRate: 0.1}))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	allowed := observability.RateLimitDecisions.WithLabelValues("ip", "allowed")
+	denied := observability.RateLimitDecisions.WithLabelValues("ip", "denied")
+	beforeAllowed, beforeDenied := testutil.ToFloat64(allowed), testutil.ToFloat64(denied)
+
+	for range 3 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	}
+
+	assert.Equal(t, beforeAllowed+1, testutil.ToFloat64(allowed))
+	assert.Equal(t, beforeDenied+2, testutil.ToFloat64(denied))
+}
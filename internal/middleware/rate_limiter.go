@@ -5,6 +5,8 @@ import (
 	_ "embed"
 	"fmt"
 	"net/http"
+	"task_handler/internal/config"
+	"task_handler/internal/observability"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +17,13 @@ import (
 //go:embed rate_limiter.lua
 var luaScript string
 
+// Rate limiter backends selectable through RATE_LIMIT_BACKEND
+const (
+	LimiterBackendRedis  = "redis"
+	LimiterBackendMemory = "memory"
+	LimiterBackendNone   = "none"
+)
+
 // RateLimiterConfig holds rate limiter configuration
 type RateLimiterConfig struct {
 	Capacity   int     // Maximum number of tokens (max requests)
@@ -30,45 +39,114 @@ func DefaultRateLimiterConfig() *RateLimiterConfig {
 	}
 }
 
-// RateLimiterMiddleware implements Token Bucket algorithm using Redis + Lua script
-func RateLimiterMiddleware(redisClient *redis.Client, config *RateLimiterConfig) gin.HandlerFunc {
-	// Load Lua script into Redis (SHA hash will be cached)
-	ctx := context.Background()
-	scriptSHA, err := redisClient.ScriptLoad(ctx, luaScript).Result()
+// Limiter takes one token from the bucket identified by key
+type Limiter interface {
+	Allow(ctx context.Context, key string, config *RateLimiterConfig) (bool, error)
+}
+
+// SetupLimiter builds the limiter for the configured backend. It returns nil
+// for the "none" backend, which disables rate limiting.
+func SetupLimiter(cfg *config.RateLimitConfig, redisClient *redis.Client) Limiter {
+	switch cfg.Backend {
+	case LimiterBackendNone:
+		logrus.Warn("Rate limiting disabled")
+		return nil
+	case LimiterBackendMemory:
+		logrus.Info("Using in-memory rate limiter")
+		return NewMemoryLimiter()
+	case LimiterBackendRedis, "":
+		if redisClient == nil {
+			logrus.Fatal("Redis rate limiter selected but no Redis client configured")
+		}
+		return NewRedisLimiter(redisClient)
+	default:
+		logrus.Fatal(fmt.Sprintf("Unknown RATE_LIMIT_BACKEND %q", cfg.Backend))
+		return nil
+	}
+}
+
+// RedisLimiter implements the token bucket with a Lua script so every API
+// replica shares the same buckets
+type RedisLimiter struct {
+	client    *redis.Client
+	scriptSHA string
+}
+
+// NewRedisLimiter loads the Lua script into Redis (SHA hash will be cached)
+func NewRedisLimiter(redisClient *redis.Client) *RedisLimiter {
+	scriptSHA, err := redisClient.ScriptLoad(context.Background(), luaScript).Result()
 	if err != nil {
 		logrus.WithError(err).Fatal("Failed to load Lua script for rate limiter")
 	}
 
-	return func(c *gin.Context) {
+	return &RedisLimiter{client: redisClient, scriptSHA: scriptSHA}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, config *RateLimiterConfig) (bool, error) {
+	now := time.Now().Unix()
+	result, err := l.client.EvalSha(ctx, l.scriptSHA, []string{key},
+		config.Capacity,
+		config.RefillRate,
+		now,
+	).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return result.(int64) == 1, nil
+}
+
+// RateLimiterMiddleware implements Token Bucket algorithm using Redis + Lua script
+func RateLimiterMiddleware(redisClient *redis.Client, config *RateLimiterConfig) gin.HandlerFunc {
+	return UserRateLimiterMiddleware(NewRedisLimiter(redisClient), config)
+}
+
+// UserRateLimiterMiddleware limits each authenticated user separately. It
+// must run after AuthMiddleware, which puts userID into the context.
+func UserRateLimiterMiddleware(limiter Limiter, config *RateLimiterConfig) gin.HandlerFunc {
+	return rateLimit(limiter, config, "user", func(c *gin.Context) (string, bool) {
 		// Get user ID from JWT context
 		userID, exists := c.Get("userID")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Unauthorized - user_id not found in context",
 			})
+			return "", false
+		}
+		return UserRateLimiterKey(userID.(int)), true
+	})
+}
+
+// IPRateLimiterMiddleware limits each client IP separately, for public
+// endpoints such as login where no user is known yet
+func IPRateLimiterMiddleware(limiter Limiter, config *RateLimiterConfig) gin.HandlerFunc {
+	return rateLimit(limiter, config, "ip", func(c *gin.Context) (string, bool) {
+		return IPRateLimiterKey(c.ClientIP()), true
+	})
+}
+
+// rateLimit applies the bucket chosen by keyFn. keyFn writes its own error
+// response when it returns false. scope labels the decisions in metrics.
+func rateLimit(limiter Limiter, config *RateLimiterConfig, scope string, keyFn func(c *gin.Context) (string, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := keyFn(c)
+		if !ok {
 			c.Abort()
 			return
 		}
 
-		key := UserRateLimiterKey(userID.(int))
-		now := time.Now().Unix()
-		result, err := redisClient.EvalSha(ctx, scriptSHA, []string{key},
-			config.Capacity,
-			config.RefillRate,
-			now,
-		).Result()
-
+		allowed, err := limiter.Allow(c.Request.Context(), key, config)
 		if err != nil {
-			logrus.WithError(err).Error("Failed to execute rate limiter Lua script")
-			// Fail open: allow request if Redis fails
+			logrus.WithError(err).Error("Failed to execute rate limiter")
+			observability.RateLimitDecisions.WithLabelValues(scope, "error").Inc()
+			// Fail open: allow request if the limiter backend fails
 			c.Next()
 			return
 		}
 
-		// Check if request is allowed
-		allowed := result.(int64)
-		if allowed == 0 {
+		if !allowed {
 			// Rate limit exceeded
+			observability.RateLimitDecisions.WithLabelValues(scope, "denied").Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
 				"message":     fmt.Sprintf("Maximum %d requests per second allowed", int(config.RefillRate)),
@@ -79,6 +157,7 @@ func RateLimiterMiddleware(redisClient *redis.Client, config *RateLimiterConfig)
 		}
 
 		// Request allowed, continue
+		observability.RateLimitDecisions.WithLabelValues(scope, "allowed").Inc()
 		c.Next()
 	}
 }
@@ -87,3 +166,8 @@ func RateLimiterMiddleware(redisClient *redis.Client, config *RateLimiterConfig)
 func UserRateLimiterKey(userID int) string {
 	return fmt.Sprintf("rate_limiter:user:%d", userID)
 }
+
+// Build cache key for IP rate limiting
+func IPRateLimiterKey(ip string) string {
+	return fmt.Sprintf("rate_limiter:ip:%s", ip)
+}
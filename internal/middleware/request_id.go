@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"task_handler/internal/auth"
+	"task_handler/internal/logger"
+	"task_handler/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestID takes the request's X-Request-ID, or makes one up when it is
+// missing or unusable, puts it on the request context and echoes it in the
+// response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logger.RequestIDHeader)
+		if !logger.ValidRequestID(id) {
+			id = utils.RandomID()
+		}
+
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Header(logger.RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog logs one line per request through logrus, carrying the
+// request ID set by RequestID
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		})
+		if userID, ok := c.Get(auth.UserIDKey); ok {
+			entry = entry.WithField("user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("errors", c.Errors.String())
+		}
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			entry.Error("Request failed")
+		} else {
+			entry.Info("Request handled")
+		}
+	}
+}
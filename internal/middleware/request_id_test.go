@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task_handler/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRequestIDRouter() (*gin.Engine, *string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+
+	var seen string
+	router.GET("/test", func(c *gin.Context) {
+		seen = logger.RequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	return router, &seen
+}
+
+func TestRequestID_EchoesClientID(t *testing.T) {
+	router, seen := setupRequestIDRouter()
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Request-ID", "client-id-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "client-id-1", *seen)
+	assert.Equal(t, "client-id-1", w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestID_GeneratesMissingOrInvalid(t *testing.T) {
+	router, seen := setupRequestIDRouter()
+
+	for _, header := range []string{"", "has spaces in it"} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		if header != "" {
+			req.Header.Set("X-Request-ID", header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Len(t, *seen, 32)
+		assert.Equal(t, *seen, w.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestAccessLog_CarriesRequestID(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), AccessLog())
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/5", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Equal(t, "req-7", entry.Data["request_id"])
+	assert.Equal(t, "/tasks/:id", entry.Data["path"])
+	assert.Equal(t, http.StatusInternalServerError, entry.Data["status"])
+}
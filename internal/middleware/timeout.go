@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout bounds the request context, and with it every DB, cache
+// and broker call made on behalf of the request. The context is also
+// cancelled when the client disconnects. If the deadline passes before the
+// handler wrote a response, the client gets 504. Requests matched by skip,
+// such as streams, keep only the client's context.
+func RequestTimeout(timeout time.Duration, skip ...func(*gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, s := range skip {
+			if s(c) {
+				c.Next()
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error": "Request timed out",
+			})
+		}
+	}
+}
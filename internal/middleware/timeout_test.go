@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout_SetsDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestTimeout(time.Second))
+
+	var deadline time.Time
+	var hasDeadline bool
+	router.GET("/test", func(c *gin.Context) {
+		deadline, hasDeadline = c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, hasDeadline)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+}
+
+func TestRequestTimeout_RespondsWhenHandlerGivesUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestTimeout(20 * time.Millisecond))
+
+	router.GET("/slow", func(c *gin.Context) {
+		// Simulates a DB call that returns once the context is done
+		<-c.Request.Context().Done()
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "Request timed out")
+}
+
+func TestRequestTimeout_ClientCancelPropagates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestTimeout(time.Minute))
+
+	var handlerErr error
+	router.GET("/test", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		handlerErr = c.Request.Context().Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/test", nil).WithContext(ctx)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.ErrorIs(t, handlerErr, context.Canceled)
+}
+
+func TestRequestTimeout_Skip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestTimeout(time.Second, func(c *gin.Context) bool {
+		return c.FullPath() == "/stream"
+	}))
+
+	hasDeadline := map[string]bool{}
+	handler := func(c *gin.Context) {
+		_, hasDeadline[c.FullPath()] = c.Request.Context().Deadline()
+		c.Status(http.StatusOK)
+	}
+	router.GET("/stream", handler)
+	router.GET("/test", handler)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stream", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	assert.False(t, hasDeadline["/stream"])
+	assert.True(t, hasDeadline["/test"])
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task_handler/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_ServerSpan(t *testing.T) {
+	savedProvider, savedPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(savedProvider)
+		otel.SetTextMapPropagator(savedPropagator)
+	}()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Tracing())
+	var handlerTrace trace.SpanContext
+	var loggedTraceID any
+	router.GET("/things/:id", func(c *gin.Context) {
+		handlerTrace = trace.SpanContextFromContext(c.Request.Context())
+		loggedTraceID = logger.FromContext(c.Request.Context()).Data["trace_id"]
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/things/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(logger.RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope/3", nil))
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 2)
+
+	span := ended[0]
+	assert.Equal(t, "GET /things/:id", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, span.SpanContext().SpanID(), handlerTrace.SpanID())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", loggedTraceID)
+
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, "/things/:id", attrs["http.route"])
+	assert.Equal(t, "/things/7", attrs["url.path"])
+	assert.Equal(t, "500", attrs["http.response.status_code"])
+	assert.Equal(t, "req-1", attrs["request_id"])
+
+	// Unmatched paths do not name spans
+	assert.Equal(t, "GET", ended[1].Name())
+	assert.False(t, ended[1].Parent().IsValid())
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"task_handler/internal/config"
+	"task_handler/internal/db"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = "usage: migrate up | down [N] | status | version"
+
+// Main implements the `migrate` subcommand shared by every binary and
+// returns the process exit code
+func Main(args []string) int {
+	cfg := config.Load()
+	database := db.Init(&cfg.DB)
+	defer database.Close()
+
+	if err := Command(context.Background(), database, args, os.Stdout); err != nil {
+		logrus.WithError(err).Error("Migration command failed")
+		return 1
+	}
+	return 0
+}
+
+// Command runs one migrate subcommand against db, writing results to out.
+// down rolls back a single migration unless a step count is given.
+func Command(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	runner, err := New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := runner.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		n, err := runner.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", n)
+
+	case "status":
+		list, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range list {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			if s.Unknown {
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	case "version":
+		v, err := runner.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, v)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], usage)
+	}
+
+	return nil
+}
@@ -0,0 +1,470 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"task_handler/migrations"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// historyTable records every applied migration with the checksum of the
+// file it was applied from
+const historyTable = "schema_migration_history"
+
+// legacyTable is where golang-migrate, run by the former migrate/migrate
+// container, kept its single current version
+const legacyTable = "schema_migrations"
+
+// legacyVersions lists, for every version golang-migrate could have
+// reached, the migrations of this runner already applied at that version.
+// Old version 3 had two files, 003_change_id_type and 003_create_users;
+// the latter is now 004 and is looked up separately.
+var legacyVersions = map[int][]int{
+	1: {1},
+	2: {1, 2},
+	3: {1, 2, 3},
+}
+
+// lockKey identifies the session-level advisory lock held while migrating,
+// so replicas starting at the same time apply each migration only once
+const lockKey int64 = 0x7461736b6d6967 // "taskmig"
+
+var fileName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+var (
+	// ErrChecksumMismatch means an applied migration file was edited
+	// afterwards. Add a new migration instead of changing an applied one.
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	// ErrNoDownMigration means a rollback reached a version without a .down.sql file
+	ErrNoDownMigration = errors.New("no down migration")
+	// ErrLegacyState means a golang-migrate schema_migrations table cannot
+	// be adopted: it is dirty or at a version this runner does not know
+	ErrLegacyState = errors.New("cannot adopt golang-migrate state")
+)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up, hex encoded
+}
+
+// MigrationStatus describes one known or applied version
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // applied checksum differs from the file
+	Unknown   bool // applied but no longer (or not yet) in this binary
+}
+
+type appliedRow struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Load parses NNN_name.up.sql / NNN_name.down.sql files from fsys, sorted
+// by version. Two files sharing a version with different names are rejected.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(parts[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		} else if m.Name != parts[2] {
+			return nil, fmt.Errorf("duplicate migration version %d (%s and %s)", version, m.Name, parts[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+
+		if parts[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// Runner applies migrations to one database
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewRunner(db *sql.DB, migrations []Migration) *Runner {
+	return &Runner{db: db, migrations: migrations}
+}
+
+// New returns a runner for the migrations embedded into the binary
+func New(db *sql.DB) (*Runner, error) {
+	list, err := Load(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	return NewRunner(db, list), nil
+}
+
+// Apply brings db up to the latest embedded migration
+func Apply(ctx context.Context, db *sql.DB) error {
+	runner, err := New(db)
+	if err != nil {
+		return err
+	}
+	_, err = runner.Up(ctx)
+	return err
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns how many were applied. It refuses to run when
+// an applied migration no longer matches its file.
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := r.verify(applied); err != nil {
+			return err
+		}
+
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			logrus.Infof("Applying migration %03d_%s", m.Version, m.Name)
+			if err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO `+historyTable+` (version, name, checksum) VALUES ($1, $2, $3)`,
+					m.Version, m.Name, m.Checksum,
+				)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns how many were rolled back
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		known := r.byVersion()
+		for _, v := range versions {
+			if count >= steps {
+				break
+			}
+
+			m, ok := known[v]
+			if !ok || m.Down == "" {
+				return fmt.Errorf("migration %03d_%s: %w", v, applied[v].name, ErrNoDownMigration)
+			}
+
+			logrus.Infof("Rolling back migration %03d_%s", m.Version, m.Name)
+			if err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM `+historyTable+` WHERE version = $1`, m.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("rollback %03d_%s: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// Version returns the highest applied version, 0 for an empty database
+func (r *Runner) Version(ctx context.Context) (int, error) {
+	var version int
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for v := range applied {
+			if v > version {
+				version = v
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// Status lists every embedded migration plus any applied version this
+// binary does not know about, sorted by version
+func (r *Runner) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var list []MigrationStatus
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := r.byVersion()
+		for _, m := range r.migrations {
+			s := MigrationStatus{Version: m.Version, Name: m.Name}
+			if row, ok := applied[m.Version]; ok {
+				s.Applied = true
+				s.AppliedAt = row.appliedAt
+				s.Modified = row.checksum != m.Checksum
+			}
+			list = append(list, s)
+		}
+		for v, row := range applied {
+			if _, ok := known[v]; !ok {
+				list = append(list, MigrationStatus{
+					Version: v, Name: row.name, Applied: true, AppliedAt: row.appliedAt, Unknown: true,
+				})
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, err
+}
+
+// verify checks that no applied migration was edited afterwards. Applied
+// versions missing from this binary are tolerated so an older replica can
+// still start during a rolling deploy.
+func (r *Runner) verify(applied map[int]appliedRow) error {
+	for _, m := range r.migrations {
+		row, ok := applied[m.Version]
+		if ok && row.checksum != m.Checksum {
+			return fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) byVersion() map[int]Migration {
+	known := make(map[int]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = m
+	}
+	return known
+}
+
+// withLock runs fn on a dedicated connection holding the migration
+// advisory lock. The history table is created first if needed.
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock even when ctx is already cancelled; the lock would otherwise
+		// live as long as the pooled connection
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			logrus.WithError(err).Warn("Failed to release migration lock")
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+historyTable+` (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("create %s: %w", historyTable, err)
+	}
+	if err := r.adoptLegacy(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// adoptLegacy records the migrations a golang-migrate database already
+// has as applied, so upgrading from the migrate/migrate container only
+// applies what is new. It does nothing once the history has rows.
+func (r *Runner) adoptLegacy(ctx context.Context, conn *sql.Conn) error {
+	var hasHistory, hasLegacy bool
+	if err := conn.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM `+historyTable+`),
+		       to_regclass('`+legacyTable+`') IS NOT NULL
+	`).Scan(&hasHistory, &hasLegacy); err != nil {
+		return err
+	}
+	if hasHistory || !hasLegacy {
+		return nil
+	}
+
+	var version int
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM `+legacyTable+` LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", legacyTable, err)
+	}
+
+	var hasUsers bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('users') IS NOT NULL`).Scan(&hasUsers); err != nil {
+		return err
+	}
+
+	versions, err := legacyBaseline(version, dirty, hasUsers)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+
+	known := r.byVersion()
+	err = inTx(ctx, conn, func(tx *sql.Tx) error {
+		for _, v := range versions {
+			m, ok := known[v]
+			if !ok {
+				return fmt.Errorf("%w: migration %03d is not embedded", ErrLegacyState, v)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO `+historyTable+` (version, name, checksum) VALUES ($1, $2, $3)`,
+				m.Version, m.Name, m.Checksum,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("adopt %s: %w", legacyTable, err)
+	}
+
+	logrus.Infof("Adopted %s at version %d, recorded migrations %v as applied", legacyTable, version, versions)
+	return nil
+}
+
+// legacyBaseline returns the migrations to record as applied for a
+// golang-migrate database at version
+func legacyBaseline(version int, dirty, hasUsers bool) ([]int, error) {
+	if dirty {
+		return nil, fmt.Errorf("%w: %s is dirty at version %d; repair the schema and clear the flag with `migrate force %d` first",
+			ErrLegacyState, legacyTable, version, version)
+	}
+	// golang-migrate leaves -1 behind after rolling everything back
+	if version <= 0 {
+		return nil, nil
+	}
+	versions, ok := legacyVersions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown %s version %d", ErrLegacyState, legacyTable, version)
+	}
+	versions = append([]int(nil), versions...)
+	if version == 3 && hasUsers {
+		versions = append(versions, 4)
+	}
+	return versions, nil
+}
+
+func (r *Runner) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedRow, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM `+historyTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedRow{}
+	for rows.Next() {
+		var version int
+		var row appliedRow
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
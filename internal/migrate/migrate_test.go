@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"task_handler/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_SortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"002_add_column.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+
+	list, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	assert.Equal(t, 1, list[0].Version)
+	assert.Equal(t, "create_table", list[0].Name)
+	assert.Equal(t, "DROP TABLE t;", list[0].Down)
+	assert.Equal(t, 2, list[1].Version)
+	assert.Len(t, list[1].Checksum, 64)
+}
+
+func TestLoad_RejectsDuplicateVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"003_change_id_type.up.sql": {Data: []byte("SELECT 1;")},
+		"003_create_users.up.sql":   {Data: []byte("SELECT 2;")},
+	}
+
+	_, err := Load(fsys)
+	assert.ErrorContains(t, err, "duplicate migration version 3")
+}
+
+func TestLoad_RequiresUpFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"004_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}
+
+	_, err := Load(fsys)
+	assert.ErrorContains(t, err, "has no up file")
+}
+
+func TestLoad_ChecksumTracksUpFile(t *testing.T) {
+	a, err := Load(fstest.MapFS{"001_x.up.sql": {Data: []byte("SELECT 1;")}})
+	require.NoError(t, err)
+	b, err := Load(fstest.MapFS{"001_x.up.sql": {Data: []byte("SELECT 2;")}})
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a[0].Checksum, b[0].Checksum)
+}
+
+// TestEmbeddedMigrations guards the files shipped in the binaries
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, m := range list {
+		assert.Equal(t, i+1, m.Version, "versions should be contiguous")
+		assert.NotEmpty(t, m.Down, "migration %03d_%s should have a down file", m.Version, m.Name)
+		assert.NotRegexp(t, `(?im)^\s*(BEGIN|COMMIT)\s*;`, m.Up, "runner wraps migrations in a transaction")
+		assert.NotRegexp(t, `(?im)^\s*(BEGIN|COMMIT)\s*;`, m.Down, "runner wraps migrations in a transaction")
+	}
+}
+
+func TestLegacyBaseline(t *testing.T) {
+	versions, err := legacyBaseline(2, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions)
+
+	// Old version 3 may or may not include 003_create_users, now 004
+	versions, err = legacyBaseline(3, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions)
+	versions, err = legacyBaseline(3, false, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, versions)
+	assert.Equal(t, []int{1, 2, 3}, legacyVersions[3], "the shared list must not change")
+
+	versions, err = legacyBaseline(-1, false, false)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, err = legacyBaseline(3, true, true)
+	assert.ErrorIs(t, err, ErrLegacyState)
+	_, err = legacyBaseline(9, false, true)
+	assert.ErrorIs(t, err, ErrLegacyState)
+
+	// Every adopted version must be an embedded migration
+	list, err := Load(migrations.FS)
+	require.NoError(t, err)
+	known := NewRunner(nil, list).byVersion()
+	for _, v := range append(legacyVersions[3], 4) {
+		assert.Contains(t, known, v)
+	}
+}
@@ -0,0 +1,163 @@
+// Package observability holds the Prometheus metrics and OpenTelemetry
+// tracing shared by the API and the workers.
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const namespace = "task_handler"
+
+// durationBuckets span quick API calls to long running tasks, in seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve an HTTP request, by method and route.",
+		Buckets:   durationBuckets,
+	}, []string{"method", "route"})
+
+	RateLimitDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_decisions_total",
+		Help:      "Rate limiter decisions by scope (ip, user) and result (allowed, denied, error).",
+	}, []string{"scope", "result"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Task cache lookups by entry kind (task, user_tasks) and result (hit, miss).",
+	}, []string{"kind", "result"})
+
+	TasksCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_created_total",
+		Help:      "Tasks accepted by the API, by type.",
+	}, []string{"task_type"})
+
+	TasksFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_finished_total",
+		Help:      "Tasks that reached a final status (success, failed), by type.",
+	}, []string{"task_type", "status"})
+
+	TaskRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_retries_total",
+		Help:      "Attempts that failed and were queued again, by type.",
+	}, []string{"task_type"})
+
+	TaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_duration_seconds",
+		Help:      "Time to run a handler and store its result, per attempt, by type and outcome (success, failed, retry, aborted).",
+		Buckets:   durationBuckets,
+	}, []string{"task_type", "outcome"})
+
+	TaskQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_queue_wait_seconds",
+		Help:      "Time from task creation to the start of its first attempt, by type.",
+		Buckets:   durationBuckets,
+	}, []string{"task_type"})
+)
+
+// maxTaskTypes bounds the task_type label values. Clients choose the task
+// type of what they submit, so past this many distinct values the rest
+// share the otherTaskType series.
+const maxTaskTypes = 64
+
+const otherTaskType = "other"
+
+var (
+	taskTypesMu sync.Mutex
+	taskTypes   = map[string]bool{}
+)
+
+// TaskType returns the task_type label value for t
+func TaskType(t string) string {
+	taskTypesMu.Lock()
+	defer taskTypesMu.Unlock()
+
+	if taskTypes[t] {
+		return t
+	}
+	if len(taskTypes) >= maxTaskTypes {
+		return otherTaskType
+	}
+	taskTypes[t] = true
+	return t
+}
+
+// Cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// MetricsHandler serves every registered metric in the Prometheus format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// StartMetricsServer serves /metrics on METRICS_ADDR, a listener of its own
+// so the endpoint stays off the public API port. Binaries that may share a
+// host pass different defaultAddr values. The address is bound before
+// returning, so a taken port fails startup instead of silently losing the
+// metrics. The returned function stops the server.
+func StartMetricsServer(cfg *config.MetricsConfig, defaultAddr string) (stop func(), err error) {
+	if !cfg.Enabled {
+		return func() {}, nil
+	}
+	addr := cfg.Addr
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listener on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logrus.Infof("Serving metrics on %s/metrics", l.Addr())
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("Metrics server stopped")
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("Failed to stop metrics server")
+		}
+	}, nil
+}
@@ -0,0 +1,76 @@
+package observability
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskType_BoundsLabelValues(t *testing.T) {
+	taskTypesMu.Lock()
+	saved := taskTypes
+	taskTypes = map[string]bool{}
+	taskTypesMu.Unlock()
+	defer func() {
+		taskTypesMu.Lock()
+		taskTypes = saved
+		taskTypesMu.Unlock()
+	}()
+
+	for i := range maxTaskTypes {
+		assert.Equal(t, fmt.Sprintf("type_%d", i), TaskType(fmt.Sprintf("type_%d", i)))
+	}
+	assert.Equal(t, otherTaskType, TaskType("one_too_many"))
+	assert.Equal(t, "type_0", TaskType("type_0"))
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	// Find a free port, then let the server listen on it by default
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	TasksCreated.WithLabelValues("metrics_probe").Inc()
+	TaskDuration.WithLabelValues("metrics_probe", "success").Observe(1)
+	stop, err := StartMetricsServer(&config.MetricsConfig{Enabled: true}, addr)
+	require.NoError(t, err)
+	defer stop()
+
+	var body []byte
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond)
+
+	assert.Contains(t, string(body), `task_handler_tasks_created_total{task_type="metrics_probe"} 1`)
+	assert.Contains(t, string(body), `task_handler_task_duration_seconds_count{outcome="success",task_type="metrics_probe"} 1`)
+}
+
+func TestStartMetricsServer_Disabled(t *testing.T) {
+	stop, err := StartMetricsServer(&config.MetricsConfig{Enabled: false, Addr: "127.0.0.1:0"}, "")
+	require.NoError(t, err)
+	stop()
+}
+
+func TestStartMetricsServer_AddressInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = StartMetricsServer(&config.MetricsConfig{Enabled: true, Addr: l.Addr().String()}, ":0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"task_handler/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace exporters selectable through TRACING_EXPORTER
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// instrumentationName names the tracer of every span this service creates
+const instrumentationName = "task_handler"
+
+// Attributes set on the service's own spans
+const (
+	RequestIDKey = attribute.Key("request_id")
+	TaskIDKey    = attribute.Key("task.id")
+	TaskTypeKey  = attribute.Key("task.type")
+	AttemptKey   = attribute.Key("task.attempt")
+)
+
+// Tracer returns the tracer for the service's own spans. It follows the
+// provider installed by SetupTracing, even when obtained before.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// SetupTracing installs the W3C trace context propagator and, unless
+// TRACING_EXPORTER is none, a tracer provider exporting to OTLP or to
+// stdout/TRACING_FILE. The returned function flushes and stops it.
+func SetupTracing(cfg *config.TracingConfig, defaultService string) (shutdown func(context.Context) error) {
+	shutdown, err := NewTracing(context.Background(), *cfg, defaultService)
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if cfg.Exporter != "" && cfg.Exporter != ExporterNone {
+		logrus.Infof("Exporting traces with the %s exporter", cfg.Exporter)
+	}
+	return shutdown
+}
+
+func NewTracing(ctx context.Context, cfg config.TracingConfig, defaultService string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	closeOutput := func() error { return nil }
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		// Endpoint, headers and TLS come from the standard
+		// OTEL_EXPORTER_OTLP_* variables
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	case ExporterStdout:
+		var out io.Writer = os.Stdout
+		if cfg.File != "" {
+			f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			out, closeOutput = f, f.Close
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			closeOutput()
+			return nil, err
+		}
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER must be %s, %s or %s, got %q", ExporterNone, ExporterOTLP, ExporterStdout, cfg.Exporter)
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		closeOutput()
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", cfg.SampleRatio)
+	}
+
+	service := cfg.ServiceName
+	if service == "" {
+		service = defaultService
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		closeOutput()
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if cerr := closeOutput(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// RecordError marks span as failed with err
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// InjectHeaders writes the trace context of ctx into message headers
+func InjectHeaders(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// ExtractHeaders returns ctx continuing the trace carried by headers
+func ExtractHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
@@ -0,0 +1,190 @@
+package observability
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"task_handler/internal/config"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider keeping every span in memory
+// until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	savedProvider, savedPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(savedProvider)
+		otel.SetTextMapPropagator(savedPropagator)
+	})
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestHeaders_RoundTrip(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := Tracer().Start(context.Background(), "producer")
+	defer span.End()
+
+	headers := map[string]string{}
+	InjectHeaders(ctx, headers)
+	require.Contains(t, headers, "traceparent")
+
+	extracted := trace.SpanContextFromContext(ExtractHeaders(context.Background(), headers))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+
+	// Untraced messages start no trace
+	assert.False(t, trace.SpanContextFromContext(ExtractHeaders(context.Background(), nil)).IsValid())
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := recordSpans(t)
+	tracer := QueryTracer{}
+
+	// Queries outside a trace are left alone
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	assert.Empty(t, recorder.Ended())
+
+	parent, span := Tracer().Start(context.Background(), "request")
+	ctx = tracer.TraceQueryStart(parent, nil, pgx.TraceQueryStartData{
+		SQL:  "update tasks\n\t\tSET status = $1\n\t\tWHERE id = $2",
+		Args: []any{"SUCCESS", 7},
+	})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 1")})
+
+	ctx = tracer.TraceQueryStart(parent, nil, pgx.TraceQueryStartData{SQL: "SELECT nope"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: assert.AnError})
+	span.End()
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 3)
+
+	update := ended[0]
+	assert.Equal(t, "postgres UPDATE", update.Name())
+	assert.Equal(t, span.SpanContext().SpanID(), update.Parent().SpanID())
+	attrs := attributes(update)
+	assert.Equal(t, "update tasks SET status = $1 WHERE id = $2", attrs["db.query.text"].AsString())
+	assert.Equal(t, int64(1), attrs["db.rows_affected"].AsInt64())
+	for _, v := range attrs {
+		assert.NotEqual(t, "SUCCESS", v.Emit(), "arguments must not be recorded")
+	}
+
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+}
+
+func TestQueryTracer_TrimsLongQueries(t *testing.T) {
+	recorder := recordSpans(t)
+
+	parent, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+	query := "SELECT '" + strings.Repeat("é", maxQueryText) + "'"
+	ctx := QueryTracer{}.TraceQueryStart(parent, nil, pgx.TraceQueryStartData{SQL: query})
+	QueryTracer{}.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.Len(t, recorder.Ended(), 1)
+	text := attributes(recorder.Ended()[0])["db.query.text"].AsString()
+	assert.LessOrEqual(t, len(text), maxQueryText)
+	assert.True(t, strings.HasPrefix(query, text))
+}
+
+func TestRedisHook(t *testing.T) {
+	recorder := recordSpans(t)
+	hook := RedisHook{}
+
+	ctx, err := hook.BeforeProcess(context.Background(), redis.NewStringCmd(context.Background(), "get", "k"))
+	require.NoError(t, err)
+	require.NoError(t, hook.AfterProcess(ctx, redis.NewStringCmd(ctx, "get", "k")))
+	assert.Empty(t, recorder.Ended())
+
+	parent, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+
+	// A missing key is an answer, not a failure
+	get := redis.NewStringCmd(parent, "get", "k")
+	ctx, err = hook.BeforeProcess(parent, get)
+	require.NoError(t, err)
+	get.SetErr(redis.Nil)
+	require.NoError(t, hook.AfterProcess(ctx, get))
+
+	cmds := []redis.Cmder{redis.NewStatusCmd(parent, "set", "k", "v"), redis.NewIntCmd(parent, "expire", "k", 10)}
+	ctx, err = hook.BeforeProcessPipeline(parent, cmds)
+	require.NoError(t, err)
+	cmds[1].SetErr(assert.AnError)
+	require.NoError(t, hook.AfterProcessPipeline(ctx, cmds))
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 2)
+	assert.Equal(t, "redis get", ended[0].Name())
+	assert.Equal(t, codes.Unset, ended[0].Status().Code)
+	assert.Equal(t, "redis pipeline", ended[1].Name())
+	assert.Equal(t, int64(2), attributes(ended[1])["db.redis.commands"].AsInt64())
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+}
+
+func TestNewTracing_StdoutToFile(t *testing.T) {
+	savedProvider, savedPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(savedProvider)
+		otel.SetTextMapPropagator(savedPropagator)
+	}()
+
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := NewTracing(context.Background(), config.TracingConfig{
+		Exporter:    ExporterStdout,
+		File:        file,
+		SampleRatio: 1,
+	}, "test-service")
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "exported span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	out, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"Name":"exported span"`)
+	assert.Contains(t, string(out), "test-service")
+}
+
+func TestNewTracing_InvalidConfig(t *testing.T) {
+	savedPropagator := otel.GetTextMapPropagator()
+	defer otel.SetTextMapPropagator(savedPropagator)
+
+	_, err := NewTracing(context.Background(), config.TracingConfig{Exporter: "zipkin", SampleRatio: 1}, "test")
+	assert.Error(t, err)
+
+	_, err = NewTracing(context.Background(), config.TracingConfig{Exporter: ExporterStdout, SampleRatio: 2}, "test")
+	assert.Error(t, err)
+
+	shutdown, err := NewTracing(context.Background(), config.TracingConfig{Exporter: ExporterNone}, "test")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
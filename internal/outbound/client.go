@@ -0,0 +1,258 @@
+// Package outbound makes the HTTP requests of http_request tasks. Only
+// allowlisted hosts can be reached, and never at link-local or metadata
+// addresses, whatever their names resolve to.
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"syscall"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxRedirects bounds the redirects one request follows
+const maxRedirects = 5
+
+var (
+	ErrHostNotAllowed = errors.New("host is not in the outbound allowlist")
+	ErrAddressBlocked = errors.New("address may not be called")
+)
+
+// blockedNets are never dialled: instance metadata services live there
+var blockedNets = []*net.IPNet{
+	mustCIDR("169.254.0.0/16"),
+	mustCIDR("fe80::/10"),
+	mustCIDR("fd00:ec2::254/128"),
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// RequestError is a failed request. Permanent errors, such as a 404 or a
+// host that is not allowed, fail the same way on every attempt; network
+// errors and 5xx responses may succeed later.
+type RequestError struct {
+	Err       error
+	Permanent bool
+}
+
+func (e *RequestError) Error() string {
+	if e.Permanent {
+		return "http: permanent failure: " + e.Err.Error()
+	}
+	return "http: temporary failure: " + e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether retrying err is pointless
+func IsPermanent(err error) bool {
+	var reqErr *RequestError
+	return errors.As(err, &reqErr) && reqErr.Permanent
+}
+
+func permanent(err error) error {
+	return &RequestError{Err: err, Permanent: true}
+}
+
+// Request is one call. Without Expect any 2xx status is a success.
+type Request struct {
+	Method  string
+	URL     string
+	Header  http.Header
+	Body    []byte
+	Timeout time.Duration // 0 or more than OUTBOUND_TIMEOUT means OUTBOUND_TIMEOUT
+	Expect  []int
+}
+
+// Response is a call's outcome with at most OUTBOUND_MAX_RESPONSE_BYTES
+// of its body
+type Response struct {
+	Status    int
+	URL       string // After redirects
+	Header    http.Header
+	Body      []byte
+	Truncated bool
+	Duration  time.Duration
+}
+
+type Client struct {
+	hosts *Hosts
+	cfg   config.OutboundConfig
+	http  *http.Client
+}
+
+// Setup builds the client http_request uses
+func Setup(cfg *config.OutboundConfig) *Client {
+	client, err := NewClient(*cfg)
+	if err != nil {
+		logrus.Fatalf("Invalid OUTBOUND_ALLOWED_HOSTS: %v", err)
+	}
+	if client.hosts.Empty() {
+		logrus.Info("OUTBOUND_ALLOWED_HOSTS is not set, http_request tasks are disabled")
+	}
+	return client
+}
+
+// NewDisabledClient refuses every request
+func NewDisabledClient() *Client {
+	c, _ := NewClient(config.OutboundConfig{})
+	return c
+}
+
+func NewClient(cfg config.OutboundConfig) (*Client, error) {
+	hosts, err := ParseHosts(cfg.AllowedHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blocked(ip) {
+				return fmt.Errorf("%w: %s", ErrAddressBlocked, host)
+			}
+			return nil
+		},
+	}
+
+	c := &Client{hosts: hosts, cfg: cfg}
+	c.http = &http.Client{
+		Transport: &http.Transport{
+			// No proxy: it would be dialled instead of the checked address
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			MaxIdleConnsPerHost:   4,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !c.hosts.Allows(req.URL) {
+				return fmt.Errorf("%w: redirect to %s", ErrHostNotAllowed, req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return c, nil
+}
+
+func blocked(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Do sends req. When the status is not expected the response is returned
+// together with the error. A cancelled ctx is returned as is.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, permanent(fmt.Errorf("unsupported scheme %q", u.Scheme))
+	}
+	if u.User != nil {
+		return nil, permanent(errors.New("credentials in the URL are not allowed, use a header"))
+	}
+	if !c.hosts.Allows(u) {
+		return nil, permanent(fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Host))
+	}
+	if int64(len(req.Body)) > c.cfg.MaxRequest {
+		return nil, permanent(fmt.Errorf("body exceeds %d bytes", c.cfg.MaxRequest))
+	}
+
+	timeout := c.cfg.Timeout
+	if req.Timeout > 0 && req.Timeout < timeout {
+		timeout = req.Timeout
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(reqCtx, req.Method, u.String(), bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, permanent(err)
+	}
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+
+	start := time.Now()
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, c.classify(ctx, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, c.cfg.MaxResponse+1))
+	if err != nil {
+		return nil, c.classify(ctx, err)
+	}
+
+	resp := &Response{
+		Status:   httpResp.StatusCode,
+		URL:      httpResp.Request.URL.String(),
+		Header:   httpResp.Header,
+		Body:     body,
+		Duration: time.Since(start),
+	}
+	if int64(len(body)) > c.cfg.MaxResponse {
+		resp.Body, resp.Truncated = body[:c.cfg.MaxResponse], true
+	}
+
+	if !expected(resp.Status, req.Expect) {
+		err := fmt.Errorf("unexpected status %s", httpResp.Status)
+		return resp, &RequestError{Err: err, Permanent: resp.Status < 500}
+	}
+	return resp, nil
+}
+
+// classify marks refused hosts and addresses permanent and every other
+// transport error temporary, including timeouts
+func (c *Client) classify(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, ErrHostNotAllowed) || errors.Is(err, ErrAddressBlocked) {
+		return permanent(err)
+	}
+	return &RequestError{Err: err}
+}
+
+func expected(status int, expect []int) bool {
+	if len(expect) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(expect, status)
+}
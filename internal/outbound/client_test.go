@@ -0,0 +1,151 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHosts(t *testing.T) {
+	hosts, err := ParseHosts([]string{"api.internal", "*.svc.local", "billing.internal:8443", "10.0.0.5:8080", "[fd00::1]:80"})
+	require.NoError(t, err)
+
+	for raw, want := range map[string]bool{
+		"https://api.internal/v1":        true,
+		"http://API.internal:9000/":      true,
+		"https://api.internal./":         true,
+		"https://evil-api.internal/":     false,
+		"https://api.internal.evil.com/": false,
+		"http://a.svc.local/":            true,
+		"http://a.b.svc.local/":          true,
+		"http://svc.local/":              false,
+		"https://billing.internal:8443/": true,
+		"https://billing.internal/":      false,
+		"http://10.0.0.5:8080/":          true,
+		"http://10.0.0.5/":               false,
+		"http://[fd00::1]/":              true,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, hosts.Allows(u), raw)
+	}
+
+	for _, entry := range []string{"", "https://api.internal", "api.*.internal", "*", "api.internal:0", "a@b"} {
+		_, err := ParseHosts([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func newTestClient(t *testing.T, hosts ...string) *Client {
+	c, err := NewClient(config.OutboundConfig{
+		AllowedHosts: hosts,
+		Timeout:      5 * time.Second,
+		MaxRequest:   1024,
+		MaxResponse:  16,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient_Do(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			buf := new(strings.Builder)
+			fmt.Fprintf(buf, "%s %s %s ", r.Method, r.Header.Get("X-Token"), r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			buf.Write(body)
+			w.Header().Set("X-Reply", "yes")
+			_, _ = w.Write([]byte(buf.String()))
+		case "/missing":
+			http.NotFound(w, r)
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/redirect":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+		case "/slow":
+			time.Sleep(time.Second)
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, strings.TrimPrefix(srv.URL, "http://"))
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := c.Do(context.Background(), &Request{
+			Method: "POST",
+			URL:    srv.URL + "/echo",
+			Header: http.Header{"X-Token": {"t"}, "Content-Type": {"text/plain"}},
+			Body:   []byte("hello"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.Status)
+		assert.Equal(t, "yes", resp.Header.Get("X-Reply"))
+		assert.Equal(t, "POST t text/plai", string(resp.Body))
+		assert.True(t, resp.Truncated)
+	})
+
+	t.Run("UnexpectedStatus", func(t *testing.T) {
+		resp, err := c.Do(context.Background(), &Request{Method: "GET", URL: srv.URL + "/missing"})
+		require.Error(t, err)
+		assert.True(t, IsPermanent(err))
+		assert.Equal(t, 404, resp.Status)
+
+		_, err = c.Do(context.Background(), &Request{Method: "GET", URL: srv.URL + "/missing", Expect: []int{404}})
+		assert.NoError(t, err)
+
+		_, err = c.Do(context.Background(), &Request{Method: "GET", URL: srv.URL + "/down"})
+		require.Error(t, err)
+		assert.False(t, IsPermanent(err))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := c.Do(context.Background(), &Request{Method: "GET", URL: srv.URL + "/slow", Timeout: 50 * time.Millisecond})
+		require.Error(t, err)
+		assert.False(t, IsPermanent(err))
+
+		// The task's own context is not a request failure
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = c.Do(ctx, &Request{Method: "GET", URL: srv.URL + "/slow"})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NotErrorAs(t, err, new(*RequestError))
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		for _, raw := range []string{
+			"http://other.example/",
+			"ftp://" + strings.TrimPrefix(srv.URL, "http://") + "/",
+			"http://user:pass@" + strings.TrimPrefix(srv.URL, "http://") + "/",
+			srv.URL + "/redirect?to=" + url.QueryEscape("http://other.example/"),
+		} {
+			_, err := c.Do(context.Background(), &Request{Method: "GET", URL: raw})
+			require.Error(t, err, raw)
+			assert.True(t, IsPermanent(err), raw)
+		}
+
+		_, err := c.Do(context.Background(), &Request{Method: "POST", URL: srv.URL + "/echo", Body: make([]byte, 2048)})
+		assert.True(t, IsPermanent(err))
+	})
+}
+
+func TestClient_BlocksMetadataAddresses(t *testing.T) {
+	c := newTestClient(t, "169.254.169.254", "0.0.0.0")
+
+	for _, raw := range []string{"http://169.254.169.254/latest/meta-data/", "http://0.0.0.0/"} {
+		_, err := c.Do(context.Background(), &Request{Method: "GET", URL: raw})
+		assert.ErrorIs(t, err, ErrAddressBlocked, raw)
+		assert.True(t, IsPermanent(err), raw)
+	}
+}
@@ -0,0 +1,92 @@
+package outbound
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// hostPattern is one OUTBOUND_ALLOWED_HOSTS entry. A wildcard matches
+// subdomains of host, not host itself; an empty port matches any port.
+type hostPattern struct {
+	host     string
+	wildcard bool
+	port     string
+}
+
+// Hosts is the set of hosts http_request may call
+type Hosts struct {
+	patterns []hostPattern
+}
+
+// ParseHosts accepts "api.internal", "api.internal:8443", "*.svc.local",
+// "10.0.0.5:8080" and "[fd00::1]:80"
+func ParseHosts(entries []string) (*Hosts, error) {
+	h := &Hosts{}
+	for _, entry := range entries {
+		p, err := parseHostPattern(strings.ToLower(strings.TrimSpace(entry)))
+		if err != nil {
+			return nil, fmt.Errorf("allowed host %q: %w", entry, err)
+		}
+		h.patterns = append(h.patterns, p)
+	}
+	return h, nil
+}
+
+func parseHostPattern(entry string) (hostPattern, error) {
+	if entry == "" || strings.ContainsAny(entry, "/@?#") {
+		return hostPattern{}, fmt.Errorf("not a host")
+	}
+
+	var p hostPattern
+	p.host = entry
+	if strings.HasPrefix(entry, "[") || strings.Count(entry, ":") == 1 {
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			return hostPattern{}, err
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return hostPattern{}, fmt.Errorf("invalid port")
+		}
+		p.host, p.port = host, port
+	}
+
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.wildcard = rest, true
+	}
+	if p.host == "" || strings.Contains(p.host, "*") {
+		return hostPattern{}, fmt.Errorf("wildcards are only allowed as a leading \"*.\"")
+	}
+	return p, nil
+}
+
+// Empty reports whether no host at all may be called
+func (h *Hosts) Empty() bool {
+	return len(h.patterns) == 0
+}
+
+// Allows reports whether u's host and port match an entry
+func (h *Hosts) Allows(u *url.URL) bool {
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	for _, p := range h.patterns {
+		if p.port != "" && p.port != port {
+			continue
+		}
+		if p.wildcard && strings.HasSuffix(host, "."+p.host) || !p.wildcard && host == p.host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+// Package plugin runs task types implemented as WebAssembly modules. Each
+// <task_type>.wasm file in PLUGIN_DIR is a WASI command: it reads the
+// task's params as JSON on stdin and writes its JSON result to stdout.
+// Changed, added and removed files are picked up while the worker runs.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 << 10
+
+// taskType is what a file name must look like to become a task type
+var taskType = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// plugin is one compiled module
+type plugin struct {
+	name     string
+	compiled wazero.CompiledModule
+}
+
+// stamp identifies a version of a module file
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Registry holds the loaded plugins by task type
+type Registry struct {
+	cfg      config.PluginConfig
+	reserved map[string]bool
+	runtime  wazero.Runtime
+
+	mu      sync.RWMutex
+	plugins map[string]*plugin
+
+	// stamps holds the last version of every file tried, loaded or not, so
+	// a broken module is not compiled again until it changes
+	stamps map[string]stamp
+}
+
+// Setup loads the plugins in PLUGIN_DIR. Built-in task types are reserved
+// and cannot be replaced by a plugin.
+func Setup(cfg *config.PluginConfig, reserved []string) *Registry {
+	r, err := NewRegistry(context.Background(), *cfg, reserved)
+	if err != nil {
+		logrus.Fatalf("Failed to load plugins: %v", err)
+	}
+	if cfg.Dir == "" {
+		logrus.Info("PLUGIN_DIR is not set, no plugin task types are available")
+	} else {
+		logrus.Infof("Loaded plugins from %s: %v", cfg.Dir, r.Names())
+	}
+	return r
+}
+
+func NewRegistry(ctx context.Context, cfg config.PluginConfig, reserved []string) (*Registry, error) {
+	pages := cfg.MaxMemory / pageSize
+	if pages < 1 || pages > 65536 {
+		return nil, fmt.Errorf("PLUGIN_MAX_MEMORY_BYTES must be between %d and 4GiB", pageSize)
+	}
+
+	// Closing on context done is what enforces PLUGIN_TIMEOUT: without it a
+	// module that never returns would hold its goroutine forever
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(pages)).
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	r := &Registry{
+		cfg:      cfg,
+		reserved: make(map[string]bool, len(reserved)),
+		runtime:  runtime,
+		plugins:  make(map[string]*plugin),
+		stamps:   make(map[string]stamp),
+	}
+	for _, name := range reserved {
+		r.reserved[name] = true
+	}
+
+	if cfg.Dir != "" {
+		if err := r.Reload(ctx); err != nil {
+			runtime.Close(ctx)
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Has reports whether taskType is handled by a plugin
+func (r *Registry) Has(taskType string) bool {
+	return r.get(taskType) != nil
+}
+
+func (r *Registry) get(name string) *plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.plugins[name]
+}
+
+// Names lists the loaded plugins
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reload brings the loaded plugins in line with the directory. A module
+// that fails to compile is logged and its previous version kept. Runs in
+// progress finish with the version they started with. Reload is not safe
+// for concurrent use with itself.
+func (r *Registry) Reload(ctx context.Context) error {
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".wasm")
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+
+		current := stamp{modTime: info.ModTime(), size: info.Size()}
+		if r.stamps[name] == current {
+			continue
+		}
+		r.stamps[name] = current
+
+		if err := r.load(ctx, name); err != nil {
+			logrus.WithError(err).Warnf("Failed to load plugin %s", entry.Name())
+			continue
+		}
+		logrus.Infof("Loaded plugin %s", name)
+	}
+
+	for name := range r.stamps {
+		if seen[name] {
+			continue
+		}
+		delete(r.stamps, name)
+		if r.swap(ctx, name, nil) {
+			logrus.Infof("Unloaded plugin %s", name)
+		}
+	}
+	return nil
+}
+
+func (r *Registry) load(ctx context.Context, name string) error {
+	if !taskType.MatchString(name) {
+		return fmt.Errorf("task type must match %s", taskType)
+	}
+	if r.reserved[name] {
+		return fmt.Errorf("%s is a built-in task type", name)
+	}
+
+	wasm, err := os.ReadFile(filepath.Join(r.cfg.Dir, name+".wasm"))
+	if err != nil {
+		return err
+	}
+	compiled, err := r.runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return err
+	}
+	if _, ok := compiled.ExportedFunctions()["_start"]; !ok {
+		compiled.Close(ctx)
+		return fmt.Errorf("not a WASI command: no _start export")
+	}
+
+	r.swap(ctx, name, &plugin{name: name, compiled: compiled})
+	return nil
+}
+
+// swap replaces or, with p nil, removes a plugin and reports whether there
+// was one. Closing a compiled module does not affect its running instances.
+func (r *Registry) swap(ctx context.Context, name string, p *plugin) bool {
+	r.mu.Lock()
+	old := r.plugins[name]
+	if p != nil {
+		r.plugins[name] = p
+	} else {
+		delete(r.plugins, name)
+	}
+	r.mu.Unlock()
+
+	if old != nil {
+		old.compiled.Close(ctx)
+	}
+	return old != nil
+}
+
+// Start reloads the plugins every PLUGIN_RELOAD_INTERVAL in the
+// background. The returned function stops reloading.
+func (r *Registry) Start() (stop func()) {
+	if r.cfg.Dir == "" || r.cfg.ReloadInterval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Watch(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Watch reloads every interval until ctx is cancelled
+func (r *Registry) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Reload(ctx); err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to reload plugins")
+		}
+	}
+}
+
+// Close releases the runtime and every plugin
+func (r *Registry) Close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
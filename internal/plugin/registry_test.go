@@ -0,0 +1,183 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	probeOnce sync.Once
+	probeWasm []byte
+	probeErr  error
+)
+
+// probe builds testdata/probe for wasip1 once per test run
+func probe(t *testing.T) []byte {
+	probeOnce.Do(func() {
+		goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+		if _, err := os.Stat(goBin); err != nil {
+			probeErr = err
+			return
+		}
+
+		dir, err := os.MkdirTemp("", "plugin-probe-")
+		if err != nil {
+			probeErr = err
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		out := filepath.Join(dir, "probe.wasm")
+		cmd := exec.Command(goBin, "build", "-o", out, "./testdata/probe")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			probeErr = err
+			t.Log(string(output))
+			return
+		}
+		probeWasm, probeErr = os.ReadFile(out)
+	})
+	if probeErr != nil {
+		t.Skipf("Cannot build the wasip1 test plugin: %v", probeErr)
+	}
+	return probeWasm
+}
+
+func testConfig(dir string) config.PluginConfig {
+	return config.PluginConfig{
+		Dir:       dir,
+		Timeout:   2 * time.Second,
+		MaxMemory: 64 << 20,
+		MaxOutput: 64 << 10,
+	}
+}
+
+func TestRegistry_Run(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "probe.wasm"), probe(t), 0o644))
+
+	ctx := context.Background()
+	r, err := NewRegistry(ctx, testConfig(dir), nil)
+	require.NoError(t, err)
+	defer r.Close(ctx)
+
+	run := func(mode string) (json.RawMessage, error) {
+		return r.Run(ctx, "probe", []byte(`{"mode": "`+mode+`"}`), map[string]string{"TASK_ID": "9"})
+	}
+
+	t.Run("Output", func(t *testing.T) {
+		out, err := run("echo")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"mode": "echo", "task_id": "9", "args": ["probe"]}`, string(out))
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := run("echo")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("ExitCode", func(t *testing.T) {
+		_, err := run("fail")
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, uint32(1), exitErr.Code)
+		assert.Equal(t, "something broke", exitErr.Stderr)
+		assert.False(t, exitErr.Temporary())
+
+		_, err = run("tempfail")
+		require.ErrorAs(t, err, &exitErr)
+		assert.True(t, exitErr.Temporary())
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := run("spin")
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.Less(t, time.Since(start), 10*time.Second)
+
+		// Cancelling the task is reported as such, not as a plugin failure
+		cancelCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = r.Run(cancelCtx, "probe", []byte(`{"mode": "spin"}`), nil)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("MemoryLimit", func(t *testing.T) {
+		_, err := run("alloc")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrTimeout)
+	})
+
+	t.Run("BadOutput", func(t *testing.T) {
+		_, err := run("garbage")
+		assert.ErrorIs(t, err, ErrInvalidOutput)
+
+		_, err = run("big")
+		assert.ErrorIs(t, err, ErrOutputTooLarge)
+	})
+
+	_, err = r.Run(ctx, "missing", []byte(`{}`), nil)
+	assert.ErrorIs(t, err, ErrUnknownPlugin)
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	wasm := probe(t)
+	dir := t.TempDir()
+	write := func(name string, data []byte, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	base := time.Now().Add(-time.Hour)
+	write("probe.wasm", wasm, base)
+	write("send_email.wasm", wasm, base)
+	write("Bad-Name.wasm", wasm, base)
+	write("notes.txt", []byte("ignored"), base)
+
+	ctx := context.Background()
+	r, err := NewRegistry(ctx, testConfig(dir), []string{"send_email"})
+	require.NoError(t, err)
+	defer r.Close(ctx)
+	assert.Equal(t, []string{"probe"}, r.Names())
+
+	// A broken new version keeps the old one serving
+	write("probe.wasm", []byte("not wasm"), base.Add(time.Minute))
+	require.NoError(t, r.Reload(ctx))
+	_, err = r.Run(ctx, "probe", []byte(`{}`), nil)
+	assert.NoError(t, err)
+
+	write("copy.wasm", wasm, base)
+	require.NoError(t, r.Reload(ctx))
+	assert.Equal(t, []string{"copy", "probe"}, r.Names())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "probe.wasm")))
+	require.NoError(t, r.Reload(ctx))
+	assert.Equal(t, []string{"copy"}, r.Names())
+	assert.False(t, r.Has("probe"))
+}
+
+func TestNewRegistry_MissingDir(t *testing.T) {
+	_, err := NewRegistry(context.Background(), testConfig(filepath.Join(t.TempDir(), "missing")), nil)
+	assert.Error(t, err)
+}
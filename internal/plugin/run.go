@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// TempFailCode is the exit code (EX_TEMPFAIL) with which a plugin asks
+// for the task to be retried
+const TempFailCode = 75
+
+// stderrLimit bounds the stderr kept for error messages
+const stderrLimit = 4 << 10
+
+var (
+	ErrUnknownPlugin  = errors.New("no plugin handles this task type")
+	ErrTimeout        = errors.New("plugin exceeded PLUGIN_TIMEOUT")
+	ErrOutputTooLarge = errors.New("plugin output exceeds PLUGIN_MAX_OUTPUT_BYTES")
+	ErrInvalidOutput  = errors.New("plugin output is not JSON")
+)
+
+// ExitError is a plugin that exited with a non-zero code
+type ExitError struct {
+	Code   uint32
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("plugin exited with code %d", e.Code)
+	}
+	return fmt.Sprintf("plugin exited with code %d: %s", e.Code, e.Stderr)
+}
+
+// Temporary reports whether the plugin asked to be retried
+func (e *ExitError) Temporary() bool {
+	return e.Code == TempFailCode
+}
+
+// Run starts the plugin for taskType with input on stdin and env as its
+// environment, and returns what it wrote to stdout. Every run gets a fresh
+// instance, so nothing is shared between tasks.
+func (r *Registry) Run(ctx context.Context, taskType string, input []byte, env map[string]string) (json.RawMessage, error) {
+	p := r.get(taskType)
+	if p == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPlugin, taskType)
+	}
+
+	stdout := &cappedBuffer{limit: r.cfg.MaxOutput}
+	stderr := &cappedBuffer{limit: stderrLimit}
+
+	// An empty name lets instances of the same module run concurrently
+	modCfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(taskType).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		modCfg = modCfg.WithEnv(name, env[name])
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	mod, err := r.runtime.InstantiateModule(runCtx, p.compiled, modCfg)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+	if err := exitStatus(ctx, runCtx, err, stderr); err != nil {
+		return nil, err
+	}
+
+	if stdout.overflow {
+		return nil, ErrOutputTooLarge
+	}
+	out := bytes.TrimSpace(stdout.Bytes())
+	if !json.Valid(out) {
+		return nil, ErrInvalidOutput
+	}
+	return out, nil
+}
+
+// exitStatus turns the outcome of a run into nil, ctx's error when the
+// task itself was cancelled, ErrTimeout, an ExitError or a trap
+func exitStatus(ctx, runCtx context.Context, err error, stderr *cappedBuffer) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case 0:
+			return nil
+		case sys.ExitCodeDeadlineExceeded:
+			return ErrTimeout
+		}
+		return &ExitError{Code: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	if runCtx.Err() != nil {
+		return ErrTimeout
+	}
+	return fmt.Errorf("plugin trapped: %w", err)
+}
+
+// cappedBuffer keeps the first limit bytes written to it
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.Len()); int64(len(p)) > room {
+		b.overflow = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,46 @@
+// Command probe is the plugin used by the plugin tests. Its behaviour is
+// chosen by the "mode" param.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var params struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&params); err != nil {
+		fmt.Fprintln(os.Stderr, "bad input:", err)
+		os.Exit(2)
+	}
+
+	switch params.Mode {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "something broke")
+		os.Exit(1)
+	case "tempfail":
+		os.Exit(75)
+	case "spin":
+		for {
+		}
+	case "alloc":
+		var blocks [][]byte
+		for {
+			blocks = append(blocks, make([]byte, 1<<20))
+		}
+	case "garbage":
+		fmt.Println("not json")
+	case "big":
+		fmt.Printf("%q\n", strings.Repeat("x", 1<<20))
+	default:
+		json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"mode":    params.Mode,
+			"task_id": os.Getenv("TASK_ID"),
+			"args":    os.Args,
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// delayQueueExpiry is how long an idle per-delay wait queue survives
+const delayQueueExpiry = time.Minute
+
+// AMQPBroker implements Broker on RabbitMQ. Consumers survive broker
+// restarts: a consumer is re-established every time the connection
+// manager reconnects.
+type AMQPBroker struct {
+	conn      *ConnectionManager
+	publisher *Publisher
+}
+
+func NewAMQPBroker(conn *ConnectionManager, publisher *Publisher) *AMQPBroker {
+	return &AMQPBroker{
+		conn:      conn,
+		publisher: publisher,
+	}
+}
+
+func toPublishing(msg *Message) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	return amqp.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.ID,
+		Timestamp:    msg.Timestamp,
+		Type:         msg.Type,
+		Headers:      headers,
+		Body:         msg.Body,
+	}
+}
+
+func fromDelivery(d *amqp.Delivery) *Message {
+	headers := make(map[string]string, len(d.Headers))
+	for k, v := range d.Headers {
+		headers[k] = fmt.Sprint(v)
+	}
+
+	return &Message{
+		ID:          d.MessageId,
+		Type:        d.Type,
+		ContentType: d.ContentType,
+		Body:        d.Body,
+		Headers:     headers,
+		Timestamp:   d.Timestamp,
+	}
+}
+
+func (b *AMQPBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	return b.publisher.Publish(ctx, "", queue, toPublishing(msg))
+}
+
+// PublishDelayed parks the message in a wait queue whose TTL equals the
+// delay; on expiry RabbitMQ dead-letters it back into the target queue.
+func (b *AMQPBroker) PublishDelayed(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	if delay <= 0 {
+		return b.Publish(ctx, queue, msg)
+	}
+
+	waitQueue := fmt.Sprintf("%s.delay.%d", queue, delay.Milliseconds())
+
+	ch, err := CreateChannel(b.conn)
+	if err != nil {
+		return err
+	}
+	_, err = ch.QueueDeclare(
+		waitQueue,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+			"x-expires":                 (delay + delayQueueExpiry).Milliseconds(),
+		},
+	)
+	_ = ch.Close()
+	if err != nil {
+		return fmt.Errorf("failed to declare delay queue: %w", err)
+	}
+
+	return b.publisher.Publish(ctx, "", waitQueue, toPublishing(msg))
+}
+
+func (b *AMQPBroker) Consume(ctx context.Context, queue string, consumer string) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+
+		for {
+			if err := b.conn.WaitConnected(ctx); err != nil {
+				return
+			}
+
+			if err := b.consumeSession(ctx, queue, consumer, out); err != nil {
+				logrus.WithError(err).Warnf("Consumer %s failed, retrying", consumer)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			logrus.Warnf("Consumer %s lost its channel, waiting for RabbitMQ to reconnect", consumer)
+		}
+	}()
+
+	return out, nil
+}
+
+// consumeSession runs one consumer on its own channel and returns once
+// the delivery stream ends, either by ctx cancellation or a disconnect.
+// The channel is only closed after the last delivery has been settled,
+// so in-flight work can still be acked during a graceful shutdown.
+func (b *AMQPBroker) consumeSession(ctx context.Context, queue, consumer string, out chan<- Delivery) error {
+	ch, err := CreateChannel(b.conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			logrus.WithError(err).Warnf("Consumer %s failed to close channel", consumer)
+		}
+	}()
+
+	if err := ch.Qos(1, 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	msgs, err := ch.Consume(
+		queue,
+		consumer,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming messages: %w", err)
+	}
+
+	// Cancelling the consumer makes the broker stop delivering; msgs is
+	// closed once the server confirms, which ends the loop below.
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := ch.Cancel(consumer, false); err != nil && !errors.Is(err, amqp.ErrClosed) {
+				logrus.WithError(err).Warnf("Consumer %s failed to cancel", consumer)
+			}
+		case <-sessionDone:
+		}
+	}()
+
+	for msg := range msgs {
+		d := &amqpDelivery{
+			broker:  b,
+			queue:   queue,
+			raw:     msg,
+			msg:     fromDelivery(&msg),
+			settled: make(chan struct{}),
+		}
+
+		select {
+		case out <- d:
+		case <-ctx.Done():
+			if err := msg.Nack(false, true); err != nil {
+				logrus.WithError(err).Warn("Failed to requeue undelivered message")
+			}
+			continue
+		}
+
+		<-d.settled
+	}
+
+	return nil
+}
+
+func (b *AMQPBroker) Ping(ctx context.Context) error {
+	if state := b.conn.State(); state != StateConnected {
+		return fmt.Errorf("rabbitmq %s", state)
+	}
+	return nil
+}
+
+// State exposes the underlying connection state
+func (b *AMQPBroker) State() ConnectionState {
+	return b.conn.State()
+}
+
+// Connection returns the managed AMQP connection
+func (b *AMQPBroker) Connection() *ConnectionManager {
+	return b.conn
+}
+
+func (b *AMQPBroker) Close() error {
+	return errors.Join(b.publisher.Close(), b.conn.Close())
+}
+
+type amqpDelivery struct {
+	broker  *AMQPBroker
+	queue   string
+	raw     amqp.Delivery
+	msg     *Message
+	once    sync.Once
+	settled chan struct{}
+}
+
+func (d *amqpDelivery) Message() *Message {
+	return d.msg
+}
+
+func (d *amqpDelivery) settle() {
+	d.once.Do(func() { close(d.settled) })
+}
+
+func (d *amqpDelivery) Ack() error {
+	defer d.settle()
+	return d.raw.Ack(false)
+}
+
+func (d *amqpDelivery) Nack(requeue bool) error {
+	defer d.settle()
+
+	if requeue {
+		return d.raw.Nack(false, true)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.broker.publisher.Publish(ctx, "", DeadLetterQueue(d.queue), toPublishing(d.msg)); err != nil {
+		logrus.WithError(err).Error("Failed to dead-letter message, dropping it")
+		return errors.Join(err, d.raw.Nack(false, false))
+	}
+	return d.raw.Ack(false)
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BackendRabbitMQ = "rabbitmq"
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+	BackendMemory   = "memory"
+)
+
+// HeaderRequestID carries the ID of the HTTP request that created a task,
+// so worker logs can be matched with the API's
+const HeaderRequestID = "x-request-id"
+
+var ErrBrokerClosed = errors.New("queue: broker closed")
+
+// ErrQueueFull is returned by brokers with a length cap when a new message
+// would exceed it
+var ErrQueueFull = errors.New("queue: queue is full")
+
+// instanceName names this process among the replicas sharing a queue.
+// Containers and processes on one host may share a host name, so the pid
+// is always part of it.
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// checkDuration rejects a setting too short to drive a ticker once divided
+// into parts, as the brokers' background loops do
+func checkDuration(name string, d time.Duration, parts int) error {
+	if d/time.Duration(parts) <= 0 {
+		return fmt.Errorf("queue: %s must be at least %s, got %s", name, time.Duration(parts), d)
+	}
+	return nil
+}
+
+// Message is a transport-independent queue message
+type Message struct {
+	ID          string
+	Type        string
+	ContentType string
+	Body        []byte
+	Headers     map[string]string
+	Timestamp   time.Time
+}
+
+// Delivery is a message handed to a consumer. Exactly one of Ack or Nack
+// must be called; a consumer receives its next delivery only after the
+// current one has been settled.
+type Delivery interface {
+	Message() *Message
+	Ack() error
+	// Nack rejects the message. With requeue it is delivered again,
+	// otherwise it is moved to the queue's dead-letter queue.
+	Nack(requeue bool) error
+}
+
+// Broker dispatches task messages between the API and the workers
+type Broker interface {
+	Publish(ctx context.Context, queue string, msg *Message) error
+	// PublishDelayed makes msg available to consumers once delay has passed
+	PublishDelayed(ctx context.Context, queue string, msg *Message, delay time.Duration) error
+	// Consume delivers messages until ctx is cancelled or the broker is
+	// closed, then closes the returned channel
+	Consume(ctx context.Context, queue string, consumer string) (<-chan Delivery, error)
+	// Ping reports whether the broker is currently usable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// DeadLetterQueue returns the name of the queue receiving messages
+// rejected without requeue
+func DeadLetterQueue(queue string) string {
+	return queue + ".dead"
+}
+
+// Setup creates the broker selected by QUEUE_BACKEND. The postgres
+// backend stores messages in db; the redis backend opens its own client
+// so blocking stream reads don't compete with the cache for connections.
+func Setup(cfg *config.Config, db *sql.DB) Broker {
+	switch cfg.Queue.Backend {
+	case BackendRabbitMQ, "":
+		conn := SetupRabbitMQ(&cfg.RabbitMQ)
+		publisher := NewPublisher(conn, cfg.RabbitMQ.PublisherPoolSize, cfg.RabbitMQ.ConfirmTimeout)
+		return NewAMQPBroker(conn, publisher)
+	case BackendPostgres:
+		logrus.Info("Using PostgreSQL queue backend")
+		broker, err := NewPostgresBroker(db, cfg.Queue.PollInterval, cfg.Queue.VisibilityTimeout)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid Postgres queue configuration")
+		}
+		return broker
+	case BackendRedis:
+		logrus.Info("Using Redis Streams queue backend")
+		broker, err := NewRedisStreamBroker(
+			cache.SetupRedis(&cfg.Redis),
+			cfg.Queue.StreamGroup,
+			cfg.Queue.StreamMaxLen,
+			cfg.Queue.PollInterval,
+			cfg.Queue.VisibilityTimeout,
+		)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid Redis queue configuration")
+		}
+		return broker
+	case BackendMemory:
+		logrus.Warn("Using in-memory queue backend, messages are lost on restart")
+		return NewMemoryBroker()
+	default:
+		logrus.Fatal(fmt.Sprintf("Unknown QUEUE_BACKEND %q", cfg.Queue.Backend))
+		return nil
+	}
+}
+
+func cloneHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+var ErrNotConnected = errors.New("rabbitmq: not connected")
+
+// ConnectionState describes the lifecycle of a ConnectionManager
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateConnected
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Topology declares the exchanges and queues the application relies on.
+// It runs after every (re)connect so a restarted broker gets them back.
+type Topology func(ch *amqp.Channel) error
+
+// ConnectionManager owns the AMQP connection, watches it through
+// NotifyClose and transparently redials with exponential backoff when
+// the broker goes away.
+type ConnectionManager struct {
+	url      string
+	topology Topology
+
+	mu    sync.RWMutex
+	conn  *amqp.Connection
+	state ConnectionState
+	// connected is closed (and replaced) every time a connection comes up
+	connected chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewConnectionManager(url string, topology Topology) *ConnectionManager {
+	return &ConnectionManager{
+		url:       url,
+		topology:  topology,
+		state:     StateConnecting,
+		connected: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Connect dials the broker, retrying up to maxAttempts times. Once it
+// succeeds the connection is supervised and re-established forever until
+// Close is called.
+func (m *ConnectionManager) Connect(maxAttempts int) error {
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		if err = m.dial(); err == nil {
+			return nil
+		}
+
+		logrus.Warnf("Failed to connect to RabbitMQ (attempt %d/%d): %v", i+1, maxAttempts, err)
+		if i < maxAttempts-1 {
+			time.Sleep(time.Duration(i+1) * time.Second)
+		}
+	}
+
+	return fmt.Errorf("failed to connect to RabbitMQ after %d attempts: %w", maxAttempts, err)
+}
+
+func (m *ConnectionManager) dial() error {
+	conn, err := amqp.Dial(m.url)
+	if err != nil {
+		return err
+	}
+
+	if m.topology != nil {
+		if err := m.declareTopology(conn); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.conn = conn
+	m.state = StateConnected
+	close(m.connected)
+	m.connected = make(chan struct{})
+	m.mu.Unlock()
+
+	go m.watch(conn)
+	return nil
+}
+
+func (m *ConnectionManager) declareTopology(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open topology channel: %w", err)
+	}
+	defer ch.Close()
+
+	return m.topology(ch)
+}
+
+// watch blocks until conn closes and then reconnects unless the manager
+// itself was closed.
+func (m *ConnectionManager) watch(conn *amqp.Connection) {
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	select {
+	case <-m.done:
+		return
+	case amqpErr := <-closed:
+		if amqpErr == nil {
+			// Graceful close initiated by this process
+			return
+		}
+		logrus.WithError(amqpErr).Warn("RabbitMQ connection lost, reconnecting")
+	}
+
+	m.mu.Lock()
+	m.state = StateReconnecting
+	m.mu.Unlock()
+
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := m.dial(); err != nil {
+			logrus.WithError(err).Warnf("RabbitMQ reconnect failed, retrying in %s", delay)
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+
+		logrus.Info("RabbitMQ connection re-established")
+		return
+	}
+}
+
+// Channel opens a new channel on the current connection
+func (m *ConnectionManager) Channel() (*amqp.Channel, error) {
+	m.mu.RLock()
+	conn := m.conn
+	m.mu.RUnlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+
+	return conn.Channel()
+}
+
+// State reports the current connection state, used by health checks
+func (m *ConnectionManager) State() ConnectionState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// WaitConnected blocks until a usable connection is available or ctx is
+// done. It returns immediately when the current connection is open.
+func (m *ConnectionManager) WaitConnected(ctx context.Context) error {
+	m.mu.RLock()
+	conn, state, connected := m.conn, m.state, m.connected
+	m.mu.RUnlock()
+
+	if state == StateClosed {
+		return ErrNotConnected
+	}
+	if conn != nil && !conn.IsClosed() {
+		return nil
+	}
+
+	select {
+	case <-connected:
+		return nil
+	case <-m.done:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops reconnecting and closes the underlying connection
+func (m *ConnectionManager) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+
+		m.mu.Lock()
+		conn := m.conn
+		m.state = StateClosed
+		m.mu.Unlock()
+
+		if conn != nil && !conn.IsClosed() {
+			err = conn.Close()
+		}
+	})
+	return err
+}
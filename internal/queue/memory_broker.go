@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBroker implements Broker in process memory. It is meant for tests
+// and single-process development setups; nothing survives a restart.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	closed bool
+	done   chan struct{}
+}
+
+type memoryQueue struct {
+	messages []*Message
+	// ready is closed and replaced whenever a message is added
+	ready chan struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		queues: make(map[string]*memoryQueue),
+		done:   make(chan struct{}),
+	}
+}
+
+// queue returns the named queue, creating it on first use. Callers must
+// hold b.mu.
+func (b *MemoryBroker) queue(name string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{ready: make(chan struct{})}
+		b.queues[name] = q
+	}
+	return q
+}
+
+func (b *MemoryBroker) push(name string, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	q := b.queue(name)
+	q.messages = append(q.messages, msg)
+	close(q.ready)
+	q.ready = make(chan struct{})
+	return nil
+}
+
+func copyMessage(msg *Message) *Message {
+	c := *msg
+	c.Body = append([]byte(nil), msg.Body...)
+	c.Headers = cloneHeaders(msg.Headers)
+	if c.Timestamp.IsZero() {
+		c.Timestamp = time.Now().UTC()
+	}
+	return &c
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	return b.push(queue, copyMessage(msg))
+}
+
+func (b *MemoryBroker) PublishDelayed(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	if delay <= 0 {
+		return b.Publish(ctx, queue, msg)
+	}
+
+	if err := b.Ping(ctx); err != nil {
+		return err
+	}
+
+	// Messages still waiting when the broker closes are dropped by push
+	c := copyMessage(msg)
+	time.AfterFunc(delay, func() {
+		_ = b.push(queue, c)
+	})
+	return nil
+}
+
+// pop removes the next message, or returns the channel to wait on when
+// the queue is empty
+func (b *MemoryBroker) pop(name string) (*Message, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q := b.queue(name)
+	if len(q.messages) == 0 {
+		return nil, q.ready
+	}
+
+	msg := q.messages[0]
+	q.messages = q.messages[1:]
+	return msg, nil
+}
+
+func (b *MemoryBroker) Consume(ctx context.Context, queue string, consumer string) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+
+		for {
+			msg, ready := b.pop(queue)
+			if msg == nil {
+				select {
+				case <-ready:
+					continue
+				case <-ctx.Done():
+					return
+				case <-b.done:
+					return
+				}
+			}
+
+			d := &memoryDelivery{
+				broker:  b,
+				queue:   queue,
+				msg:     msg,
+				settled: make(chan struct{}),
+			}
+
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				_ = d.Nack(true)
+				return
+			}
+
+			<-d.settled
+		}
+	}()
+
+	return out, nil
+}
+
+// Len returns the number of messages waiting in queue
+func (b *MemoryBroker) Len(queue string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue(queue).messages)
+}
+
+func (b *MemoryBroker) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+	close(b.done)
+	return nil
+}
+
+type memoryDelivery struct {
+	broker  *MemoryBroker
+	queue   string
+	msg     *Message
+	once    sync.Once
+	settled chan struct{}
+}
+
+func (d *memoryDelivery) Message() *Message {
+	return d.msg
+}
+
+func (d *memoryDelivery) Ack() error {
+	d.once.Do(func() { close(d.settled) })
+	return nil
+}
+
+func (d *memoryDelivery) Nack(requeue bool) error {
+	defer d.once.Do(func() { close(d.settled) })
+
+	if requeue {
+		return d.broker.push(d.queue, d.msg)
+	}
+	return d.broker.push(DeadLetterQueue(d.queue), d.msg)
+}
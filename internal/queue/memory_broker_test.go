@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, deliveries <-chan Delivery) Delivery {
+	t.Helper()
+
+	select {
+	case d, ok := <-deliveries:
+		require.True(t, ok, "delivery channel closed")
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+		return nil
+	}
+}
+
+func TestMemoryBroker_PublishConsumeAck(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m1", Body: []byte("hello")}))
+
+	deliveries, err := broker.Consume(ctx, "jobs", "test")
+	require.NoError(t, err)
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "m1", d.Message().ID)
+	assert.Equal(t, []byte("hello"), d.Message().Body)
+	assert.False(t, d.Message().Timestamp.IsZero())
+	require.NoError(t, d.Ack())
+
+	assert.Equal(t, 0, broker.Len("jobs"))
+}
+
+func TestMemoryBroker_NackRequeue(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m1"}))
+	deliveries, err := broker.Consume(ctx, "jobs", "test")
+	require.NoError(t, err)
+
+	require.NoError(t, receive(t, deliveries).Nack(true))
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "m1", d.Message().ID)
+	require.NoError(t, d.Ack())
+}
+
+func TestMemoryBroker_NackDeadLetters(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "poison"}))
+	deliveries, err := broker.Consume(ctx, "jobs", "test")
+	require.NoError(t, err)
+
+	require.NoError(t, receive(t, deliveries).Nack(false))
+
+	assert.Equal(t, 0, broker.Len("jobs"))
+	assert.Equal(t, 1, broker.Len(DeadLetterQueue("jobs")))
+}
+
+func TestMemoryBroker_PublishDelayed(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	ctx := context.Background()
+	require.NoError(t, broker.PublishDelayed(ctx, "jobs", &Message{ID: "later"}, 50*time.Millisecond))
+
+	assert.Equal(t, 0, broker.Len("jobs"))
+	assert.Eventually(t, func() bool {
+		return broker.Len("jobs") == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMemoryBroker_ConsumeStopsOnCancel(t *testing.T) {
+	broker := NewMemoryBroker()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries, err := broker.Consume(ctx, "jobs", "test")
+	require.NoError(t, err)
+
+	cancel()
+
+	select {
+	case _, ok := <-deliveries:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("delivery channel not closed after cancel")
+	}
+}
+
+func TestMemoryBroker_PublishAfterClose(t *testing.T) {
+	broker := NewMemoryBroker()
+	require.NoError(t, broker.Close())
+
+	err := broker.Publish(context.Background(), "jobs", &Message{ID: "m1"})
+	assert.ErrorIs(t, err, ErrBrokerClosed)
+	assert.ErrorIs(t, broker.Ping(context.Background()), ErrBrokerClosed)
+}
@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PostgresBroker implements Broker on a queue_messages table, for small
+// deployments that don't run RabbitMQ. Consumers claim rows with
+// FOR UPDATE SKIP LOCKED and hold them for a visibility timeout, which is
+// extended while the message is being processed. A consumer that dies
+// simply stops extending, and the message becomes visible again.
+type PostgresBroker struct {
+	db                *sql.DB
+	instance          string
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPostgresBroker(db *sql.DB, pollInterval, visibilityTimeout time.Duration) (*PostgresBroker, error) {
+	if err := checkDuration("QUEUE_POLL_INTERVAL", pollInterval, 1); err != nil {
+		return nil, err
+	}
+	// Claimed rows are refreshed every half timeout
+	if err := checkDuration("QUEUE_VISIBILITY_TIMEOUT", visibilityTimeout, 2); err != nil {
+		return nil, err
+	}
+
+	// Replicas number their consumers alike, so locks are taken in the name
+	// of this process: a replica must not settle a row another one has
+	// claimed again after its lock expired
+	return &PostgresBroker{
+		db:                db,
+		instance:          instanceName(),
+		pollInterval:      pollInterval,
+		visibilityTimeout: visibilityTimeout,
+		done:              make(chan struct{}),
+	}, nil
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	return b.PublishDelayed(ctx, queue, msg, 0)
+}
+
+func (b *PostgresBroker) PublishDelayed(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	select {
+	case <-b.done:
+		return ErrBrokerClosed
+	default:
+	}
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+
+	query := `
+		INSERT INTO queue_messages (
+			queue, message_id, message_type, content_type, headers, body,
+			available_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + make_interval(secs => $7), $8)
+	`
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+
+	_, err = b.db.ExecContext(
+		ctx,
+		query,
+		queue,
+		msg.ID,
+		msg.Type,
+		msg.ContentType,
+		string(headers),
+		msg.Body,
+		delay.Seconds(),
+		timestamp,
+	)
+	return err
+}
+
+func (b *PostgresBroker) Consume(ctx context.Context, queue string, consumer string) (<-chan Delivery, error) {
+	name := b.instance + "-" + consumer
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+
+		for {
+			d, err := b.claim(ctx, queue, name)
+			if err != nil && ctx.Err() == nil {
+				logrus.WithError(err).Warnf("Consumer %s failed to claim message", consumer)
+			}
+
+			if d == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-b.done:
+					return
+				case <-time.After(b.pollInterval):
+				}
+				continue
+			}
+
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				if err := d.Nack(true); err != nil {
+					logrus.WithError(err).Warn("Failed to release undelivered message")
+				}
+				return
+			}
+
+			d.keepAlive()
+		}
+	}()
+
+	return out, nil
+}
+
+// claim locks the oldest available message of queue, or returns nil when
+// there is none
+func (b *PostgresBroker) claim(ctx context.Context, queue, consumer string) (*postgresDelivery, error) {
+	query := `
+		UPDATE queue_messages
+		SET locked_by = $2,
+		    locked_until = NOW() + make_interval(secs => $3),
+		    delivery_count = delivery_count + 1
+		WHERE id = (
+			SELECT id
+			FROM queue_messages
+			WHERE queue = $1
+			  AND available_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, message_id, message_type, content_type, headers, body, created_at
+	`
+
+	var (
+		id      int64
+		headers []byte
+		msg     Message
+	)
+	err := b.db.QueryRowContext(ctx, query, queue, consumer, b.visibilityTimeout.Seconds()).Scan(
+		&id,
+		&msg.ID,
+		&msg.Type,
+		&msg.ContentType,
+		&headers,
+		&msg.Body,
+		&msg.Timestamp,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+		logrus.WithError(err).Warnf("Message %d has invalid headers", id)
+	}
+
+	return &postgresDelivery{
+		broker:   b,
+		id:       id,
+		queue:    queue,
+		consumer: consumer,
+		msg:      &msg,
+		settled:  make(chan struct{}),
+	}, nil
+}
+
+func (b *PostgresBroker) Ping(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+// Close stops all consumers. The database handle is owned by the caller.
+func (b *PostgresBroker) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+	return nil
+}
+
+type postgresDelivery struct {
+	broker   *PostgresBroker
+	id       int64
+	queue    string
+	consumer string
+	msg      *Message
+	once     sync.Once
+	settled  chan struct{}
+}
+
+func (d *postgresDelivery) Message() *Message {
+	return d.msg
+}
+
+// keepAlive extends the lock until the delivery is settled
+func (d *postgresDelivery) keepAlive() {
+	ticker := time.NewTicker(d.broker.visibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.settled:
+			return
+		case <-ticker.C:
+			query := `
+				UPDATE queue_messages
+				SET locked_until = NOW() + make_interval(secs => $3)
+				WHERE id = $1 AND locked_by = $2
+			`
+			if _, err := d.broker.db.Exec(query, d.id, d.consumer, d.broker.visibilityTimeout.Seconds()); err != nil {
+				logrus.WithError(err).Warnf("Failed to extend lock on message %d", d.id)
+			}
+		}
+	}
+}
+
+func (d *postgresDelivery) settle(query string, args ...any) error {
+	defer d.once.Do(func() { close(d.settled) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := d.broker.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (d *postgresDelivery) Ack() error {
+	return d.settle(`DELETE FROM queue_messages WHERE id = $1 AND locked_by = $2`, d.id, d.consumer)
+}
+
+func (d *postgresDelivery) Nack(requeue bool) error {
+	if requeue {
+		return d.settle(`
+			UPDATE queue_messages
+			SET locked_by = NULL, locked_until = NULL
+			WHERE id = $1 AND locked_by = $2
+		`, d.id, d.consumer)
+	}
+
+	return d.settle(`
+		UPDATE queue_messages
+		SET queue = $3, locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2
+	`, d.id, d.consumer, DeadLetterQueue(d.queue))
+}
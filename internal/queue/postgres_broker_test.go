@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresBroker_InstanceNamesProcess(t *testing.T) {
+	b, err := NewPostgresBroker(nil, time.Second, time.Minute)
+	require.NoError(t, err)
+
+	// Consumer tags repeat across replicas; the process part tells them apart
+	assert.True(t, strings.HasSuffix(b.instance, "-"+strconv.Itoa(os.Getpid())), b.instance)
+	if host, err := os.Hostname(); err == nil && host != "" {
+		assert.True(t, strings.HasPrefix(b.instance, host+"-"), b.instance)
+	}
+}
+
+func TestNewPostgresBroker_RejectsShortDurations(t *testing.T) {
+	for _, tc := range []struct {
+		poll, visibility time.Duration
+	}{
+		{0, time.Minute},
+		{-time.Second, time.Minute},
+		{time.Second, 0},
+		{time.Second, time.Nanosecond},
+	} {
+		_, err := NewPostgresBroker(nil, tc.poll, tc.visibility)
+		assert.Error(t, err, "poll %s, visibility %s", tc.poll, tc.visibility)
+	}
+}
@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrPublisherClosed = errors.New("rabbitmq: publisher closed")
+	ErrNacked          = errors.New("rabbitmq: message nacked by broker")
+	ErrUnroutable      = errors.New("rabbitmq: message unroutable")
+	ErrConfirmTimeout  = errors.New("rabbitmq: timed out waiting for publisher confirm")
+)
+
+// confirmChannel is a channel in confirm mode together with the listener
+// for messages the broker could not route
+type confirmChannel struct {
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
+// Publisher publishes with publisher confirms over a bounded pool of
+// reusable channels. Every message is published as mandatory, so a
+// message that matches no queue comes back as ErrUnroutable instead of
+// being silently dropped.
+//
+// The pool holds one slot per channel; a nil slot is opened lazily, which
+// also replaces channels that died with a broker disconnect.
+type Publisher struct {
+	conn           *ConnectionManager
+	slots          chan *confirmChannel
+	confirmTimeout time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewPublisher(conn *ConnectionManager, poolSize int, confirmTimeout time.Duration) *Publisher {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+
+	slots := make(chan *confirmChannel, poolSize)
+	for i := 0; i < poolSize; i++ {
+		slots <- nil
+	}
+
+	return &Publisher{
+		conn:           conn,
+		slots:          slots,
+		confirmTimeout: confirmTimeout,
+	}
+}
+
+// Publish sends msg and blocks until the broker confirms it, rejects it,
+// returns it as unroutable, or the confirm timeout expires
+func (p *Publisher) Publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPublisherClosed
+	}
+
+	cc, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.confirmTimeout)
+	defer cancel()
+
+	confirm, err := cc.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, msg)
+	if err != nil {
+		p.discard(cc)
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		// The confirm may still arrive later; the channel can't be reused safely
+		p.discard(cc)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrConfirmTimeout
+		}
+		return err
+	}
+
+	// The broker sends basic.return before the ack of the same message,
+	// so any return for this publish is already buffered by now.
+	select {
+	case ret, ok := <-cc.returns:
+		if !ok {
+			p.discard(cc)
+			return ErrNotConnected
+		}
+		p.release(cc)
+		return fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, key, ret.ReplyCode, ret.ReplyText)
+	default:
+	}
+
+	p.release(cc)
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+// acquire takes a slot from the pool, opening a fresh confirm channel if
+// the slot is empty or its channel was closed
+func (p *Publisher) acquire(ctx context.Context) (*confirmChannel, error) {
+	var cc *confirmChannel
+	select {
+	case cc = <-p.slots:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if cc != nil && !cc.ch.IsClosed() {
+		return cc, nil
+	}
+
+	cc, err := p.open()
+	if err != nil {
+		p.slots <- nil
+		return nil, err
+	}
+	return cc, nil
+}
+
+func (p *Publisher) open() (*confirmChannel, error) {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publisher channel: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to enable confirm mode: %w", err)
+	}
+
+	return &confirmChannel{
+		ch:      ch,
+		returns: ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+func (p *Publisher) release(cc *confirmChannel) {
+	p.slots <- cc
+}
+
+func (p *Publisher) discard(cc *confirmChannel) {
+	_ = cc.ch.Close()
+	p.slots <- nil
+}
+
+// Close waits for in-flight publishes and closes every pooled channel
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	var errs []error
+	for i := 0; i < cap(p.slots); i++ {
+		cc := <-p.slots
+		if cc != nil && !cc.ch.IsClosed() {
+			if err := cc.ch.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
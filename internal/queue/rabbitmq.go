@@ -4,36 +4,34 @@ import (
 	"fmt"
 	"log"
 	"task_handler/internal/config"
-	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func SetupRabbitMQ(rabbitMQCfg *config.RabbitMQConfig) *amqp.Connection {
-	var conn *amqp.Connection
-	var err error
-
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		conn, err = amqp.Dial(rabbitMQCfg.URL)
-		if err != nil {
-			log.Printf("Failed to connect to RabbitMQ (attempt %d/%d): %v", i+1, maxRetries, err)
-			time.Sleep(time.Duration(i+1) * time.Second)
-			continue
-		}
+const TaskQueue = "task_queue"
 
-		break
-	}
+func SetupRabbitMQ(rabbitMQCfg *config.RabbitMQConfig) *ConnectionManager {
+	manager := NewConnectionManager(rabbitMQCfg.URL, DeclareTopology)
 
-	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ after %d attempts: %v", maxRetries, err)
+	if err := manager.Connect(5); err != nil {
+		log.Fatal(err)
 	}
 
 	log.Println("RabbitMQ connection established successfully")
-	return conn
+	return manager
+}
+
+// DeclareTopology declares every queue used by the API and the workers
+func DeclareTopology(ch *amqp.Channel) error {
+	for _, name := range []string{TaskQueue, DeadLetterQueue(TaskQueue)} {
+		if _, err := DeclareQueue(ch, name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func CreateChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+func CreateChannel(conn *ConnectionManager) (*amqp.Channel, error) {
 	ch, err := conn.Channel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open a channel: %w", err)
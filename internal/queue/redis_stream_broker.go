@@ -0,0 +1,526 @@
+package queue
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// moveDueScript atomically moves due delayed messages from the sorted set
+// into the stream, so two brokers never enqueue the same message twice.
+//
+// KEYS[1] delayed set, KEYS[2] stream
+// ARGV[1] now (unix ms), ARGV[2] batch size
+var moveDueScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call('ZREM', KEYS[1], member)
+	local entry = cjson.decode(member)
+	redis.call('XADD', KEYS[2], '*', unpack(entry.fields))
+end
+return #due
+`)
+
+const delayedBatchSize = 100
+
+// RedisStreamBroker implements Broker on Redis Streams. Every queue is a
+// stream consumed through one consumer group; acked entries are deleted,
+// so a stream only holds unfinished messages and is never trimmed. Once
+// it holds maxLen entries, publishing new messages fails. Entries left
+// pending by a dead consumer are taken over with XAUTOCLAIM once they
+// have been idle for claimIdle. Delayed messages wait in a sorted set
+// until they are due.
+type RedisStreamBroker struct {
+	client       *redis.Client
+	group        string
+	instance     string
+	maxLen       int64
+	pollInterval time.Duration
+	claimIdle    time.Duration
+
+	movers sync.Map // queue name -> struct{}, one delayed mover per queue
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewRedisStreamBroker(client *redis.Client, group string, maxLen int64, pollInterval, claimIdle time.Duration) (*RedisStreamBroker, error) {
+	if err := checkDuration("QUEUE_POLL_INTERVAL", pollInterval, 1); err != nil {
+		return nil, err
+	}
+	// Pending entries are refreshed every third of claimIdle
+	if err := checkDuration("QUEUE_VISIBILITY_TIMEOUT", claimIdle, 3); err != nil {
+		return nil, err
+	}
+
+	// Consumer names must differ between processes, or one would take
+	// over the entries another is still working on
+	return &RedisStreamBroker{
+		client:       client,
+		group:        group,
+		instance:     instanceName(),
+		maxLen:       maxLen,
+		pollInterval: pollInterval,
+		claimIdle:    claimIdle,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+func streamKey(queue string) string {
+	return "queue:" + queue
+}
+
+func delayedKey(queue string) string {
+	return "queue:" + queue + ":delayed"
+}
+
+// encodeFields flattens msg into stream entry fields. The body is base64
+// encoded so arbitrary bytes survive the Lua path for delayed messages.
+func encodeFields(msg *Message) ([]interface{}, error) {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode headers: %w", err)
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+
+	return []interface{}{
+		"id", msg.ID,
+		"type", msg.Type,
+		"content_type", msg.ContentType,
+		"timestamp", timestamp.Format(time.RFC3339Nano),
+		"headers", string(headers),
+		"body", base64.StdEncoding.EncodeToString(msg.Body),
+	}, nil
+}
+
+func decodeFields(values map[string]interface{}) (*Message, error) {
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+
+	body, err := base64.StdEncoding.DecodeString(str("body"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+
+	msg := &Message{
+		ID:          str("id"),
+		Type:        str("type"),
+		ContentType: str("content_type"),
+		Body:        body,
+		Headers:     map[string]string{},
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, str("timestamp")); err == nil {
+		msg.Timestamp = ts
+	}
+	if h := str("headers"); h != "" {
+		if err := json.Unmarshal([]byte(h), &msg.Headers); err != nil {
+			return nil, fmt.Errorf("invalid headers: %w", err)
+		}
+	}
+
+	return msg, nil
+}
+
+func (b *RedisStreamBroker) xadd(ctx context.Context, pipe redis.Cmdable, queue string, msg *Message) error {
+	fields, err := encodeFields(msg)
+	if err != nil {
+		return err
+	}
+
+	return pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(queue),
+		Values: fields,
+	}).Err()
+}
+
+// Publish adds msg to the stream unless the stream is full. Retries and
+// dead letters of messages already accepted are never refused.
+func (b *RedisStreamBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	select {
+	case <-b.done:
+		return ErrBrokerClosed
+	default:
+	}
+
+	if b.maxLen > 0 {
+		n, err := b.client.XLen(ctx, streamKey(queue)).Result()
+		if err != nil {
+			return err
+		}
+		if n >= b.maxLen {
+			return fmt.Errorf("%w: %s holds %d messages", ErrQueueFull, queue, n)
+		}
+	}
+
+	return b.xadd(ctx, b.client, queue, msg)
+}
+
+func (b *RedisStreamBroker) PublishDelayed(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	if delay <= 0 {
+		return b.Publish(ctx, queue, msg)
+	}
+
+	fields, err := encodeFields(msg)
+	if err != nil {
+		return err
+	}
+
+	// The nonce keeps identical messages from collapsing into one member
+	nonce := strconv.FormatInt(time.Now().UnixNano(), 36)
+	member, err := json.Marshal(map[string]interface{}{
+		"nonce":  nonce,
+		"fields": fields,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.client.ZAdd(ctx, delayedKey(queue), &redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: string(member),
+	}).Err()
+}
+
+// ensureGroup creates the consumer group (and the stream) if needed. It
+// starts from the beginning of the stream so messages published before
+// the first worker started are not skipped.
+func (b *RedisStreamBroker) ensureGroup(ctx context.Context, queue string) error {
+	err := b.client.XGroupCreateMkStream(ctx, streamKey(queue), b.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (b *RedisStreamBroker) Consume(ctx context.Context, queue string, consumer string) (<-chan Delivery, error) {
+	if err := b.ensureGroup(ctx, queue); err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	if _, loaded := b.movers.LoadOrStore(queue, struct{}{}); !loaded {
+		b.wg.Add(1)
+		go b.moveDelayed(queue)
+	}
+
+	name := b.instance + "-" + consumer
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+
+		// Entries this consumer name already holds come first. A restarted
+		// process has a new name; its old entries are claimed once idle.
+		pendingStart := "0"
+		lastClaim := time.Time{}
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-b.done:
+				return
+			default:
+			}
+
+			var (
+				entry *redis.XMessage
+				err   error
+			)
+			switch {
+			case pendingStart != "":
+				entry, err = b.readPending(ctx, queue, name)
+				if entry == nil && err == nil {
+					pendingStart = ""
+				}
+			case time.Since(lastClaim) >= b.claimIdle/2:
+				lastClaim = time.Now()
+				entry, err = b.autoClaim(ctx, queue, name)
+				if entry == nil && err == nil {
+					continue
+				}
+			default:
+				entry, err = b.readNew(ctx, queue, name)
+			}
+
+			if err != nil {
+				select {
+				case <-b.done:
+					return
+				default:
+				}
+				if ctx.Err() == nil {
+					logrus.WithError(err).Warnf("Consumer %s failed to read stream", name)
+					sleepOrDone(ctx, b.done, time.Second)
+				}
+				continue
+			}
+			if entry == nil {
+				continue
+			}
+
+			msg, err := decodeFields(entry.Values)
+			if err != nil {
+				logrus.WithError(err).Errorf("Dropping malformed stream entry %s", entry.ID)
+				_ = b.settle(queue, entry.ID, "", nil)
+				continue
+			}
+
+			d := &redisDelivery{
+				broker:   b,
+				queue:    queue,
+				entryID:  entry.ID,
+				consumer: name,
+				msg:      msg,
+				settled:  make(chan struct{}),
+			}
+
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				// Still pending on this consumer; XAUTOCLAIM or a restart picks it up
+				return
+			}
+
+			d.keepAlive()
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *RedisStreamBroker) readPending(ctx context.Context, queue, consumer string) (*redis.XMessage, error) {
+	streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    b.group,
+		Consumer: consumer,
+		Streams:  []string{streamKey(queue), "0"},
+		Count:    1,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			// Entries deleted while pending come back without values
+			if m.Values == nil {
+				_ = b.settle(queue, m.ID, "", nil)
+				continue
+			}
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *RedisStreamBroker) readNew(ctx context.Context, queue, consumer string) (*redis.XMessage, error) {
+	streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    b.group,
+		Consumer: consumer,
+		Streams:  []string{streamKey(queue), ">"},
+		Count:    1,
+		Block:    b.pollInterval,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+// autoClaim takes over one entry that another consumer has left idle for
+// longer than claimIdle. It is issued through Do because the typed
+// XAutoClaim of go-redis v8 cannot parse the three-element reply that
+// Redis 7 returns.
+func (b *RedisStreamBroker) autoClaim(ctx context.Context, queue, consumer string) (*redis.XMessage, error) {
+	reply, err := b.client.Do(ctx,
+		"XAUTOCLAIM", streamKey(queue), b.group, consumer,
+		b.claimIdle.Milliseconds(), "0-0", "COUNT", 1,
+	).Slice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(reply) < 2 {
+		return nil, fmt.Errorf("unexpected XAUTOCLAIM reply: %v", reply)
+	}
+
+	entries, _ := reply[1].([]interface{})
+	for _, raw := range entries {
+		parts, ok := raw.([]interface{})
+		if !ok || len(parts) != 2 {
+			continue
+		}
+		id, _ := parts[0].(string)
+		kv, _ := parts[1].([]interface{})
+		if kv == nil {
+			_ = b.settle(queue, id, "", nil)
+			continue
+		}
+
+		values := make(map[string]interface{}, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			key, _ := kv[i].(string)
+			values[key] = kv[i+1]
+		}
+
+		logrus.Warnf("Consumer %s reclaimed stale entry %s from %s", consumer, id, queue)
+		return &redis.XMessage{ID: id, Values: values}, nil
+	}
+	return nil, nil
+}
+
+// moveDelayed periodically enqueues delayed messages that have come due
+func (b *RedisStreamBroker) moveDelayed(queue string) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := moveDueScript.Run(ctx, b.client,
+			[]string{delayedKey(queue), streamKey(queue)},
+			time.Now().UnixMilli(), delayedBatchSize,
+		).Err()
+		cancel()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logrus.WithError(err).Warnf("Failed to move delayed messages for %s", queue)
+		}
+	}
+}
+
+// settle acknowledges and deletes entryID, optionally re-adding msg to
+// target in the same transaction
+func (b *RedisStreamBroker) settle(queue, entryID, target string, msg *Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if msg != nil {
+			if err := b.xadd(ctx, pipe, target, msg); err != nil {
+				return err
+			}
+		}
+		pipe.XAck(ctx, streamKey(queue), b.group, entryID)
+		pipe.XDel(ctx, streamKey(queue), entryID)
+		return nil
+	})
+	return err
+}
+
+func (b *RedisStreamBroker) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Close stops consumers and the delayed mover, then closes the client
+func (b *RedisStreamBroker) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.done)
+		b.wg.Wait()
+		err = b.client.Close()
+	})
+	return err
+}
+
+func sleepOrDone(ctx context.Context, done <-chan struct{}, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-done:
+	case <-time.After(d):
+	}
+}
+
+type redisDelivery struct {
+	broker   *RedisStreamBroker
+	queue    string
+	entryID  string
+	consumer string
+	msg      *Message
+	once     sync.Once
+	settled  chan struct{}
+}
+
+func (d *redisDelivery) Message() *Message {
+	return d.msg
+}
+
+// keepAlive resets the entry's idle time until it is settled, so long
+// running tasks are not reclaimed by other consumers
+func (d *redisDelivery) keepAlive() {
+	ticker := time.NewTicker(d.broker.claimIdle / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.settled:
+			return
+		case <-ticker.C:
+			err := d.broker.client.XClaimJustID(context.Background(), &redis.XClaimArgs{
+				Stream:   streamKey(d.queue),
+				Group:    d.broker.group,
+				Consumer: d.consumer,
+				MinIdle:  0,
+				Messages: []string{d.entryID},
+			}).Err()
+			if err != nil {
+				logrus.WithError(err).Warnf("Failed to refresh stream entry %s", d.entryID)
+			}
+		}
+	}
+}
+
+func (d *redisDelivery) done() {
+	d.once.Do(func() { close(d.settled) })
+}
+
+func (d *redisDelivery) Ack() error {
+	defer d.done()
+	return d.broker.settle(d.queue, d.entryID, "", nil)
+}
+
+func (d *redisDelivery) Nack(requeue bool) error {
+	defer d.done()
+
+	target := DeadLetterQueue(d.queue)
+	if requeue {
+		target = d.queue
+	}
+	return d.broker.settle(d.queue, d.entryID, target, d.msg)
+}
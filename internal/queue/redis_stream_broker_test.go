@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestStreamBroker creates a Redis Streams broker for testing
+// Make sure Redis is running on localhost:6379
+func setupTestStreamBroker(t *testing.T, claimIdle time.Duration) *RedisStreamBroker {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   2, // Use DB 2 for broker tests (rate limiter tests use DB 1)
+	})
+
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+	client.FlushDB(ctx)
+
+	broker, err := NewRedisStreamBroker(client, "test-workers", 1000, 20*time.Millisecond, claimIdle)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.FlushDB(context.Background())
+		broker.Close()
+	})
+
+	return broker
+}
+
+func TestRedisStreamBroker_PublishConsumeAck(t *testing.T) {
+	broker := setupTestStreamBroker(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries, err := broker.Consume(ctx, "jobs", "c1")
+	require.NoError(t, err)
+
+	msg := &Message{
+		ID:          "m1",
+		Type:        "send_email",
+		ContentType: "application/json",
+		Headers:     map[string]string{"x-request-id": "abc"},
+		Body:        []byte(`{"task_id":1}`),
+	}
+	require.NoError(t, broker.Publish(ctx, "jobs", msg))
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "m1", d.Message().ID)
+	assert.Equal(t, "send_email", d.Message().Type)
+	assert.Equal(t, "abc", d.Message().Headers["x-request-id"])
+	assert.Equal(t, []byte(`{"task_id":1}`), d.Message().Body)
+	require.NoError(t, d.Ack())
+
+	length, err := broker.client.XLen(ctx, streamKey("jobs")).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
+
+func TestRedisStreamBroker_NackDeadLetters(t *testing.T) {
+	broker := setupTestStreamBroker(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries, err := broker.Consume(ctx, "jobs", "c1")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "poison"}))
+
+	require.NoError(t, receive(t, deliveries).Nack(false))
+
+	dead, err := broker.client.XRange(ctx, streamKey(DeadLetterQueue("jobs")), "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "poison", dead[0].Values["id"])
+}
+
+func TestRedisStreamBroker_NackRequeue(t *testing.T) {
+	broker := setupTestStreamBroker(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries, err := broker.Consume(ctx, "jobs", "c1")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m1"}))
+
+	require.NoError(t, receive(t, deliveries).Nack(true))
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "m1", d.Message().ID)
+	require.NoError(t, d.Ack())
+}
+
+func TestRedisStreamBroker_PublishDelayed(t *testing.T) {
+	broker := setupTestStreamBroker(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deliveries, err := broker.Consume(ctx, "jobs", "c1")
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, broker.PublishDelayed(ctx, "jobs", &Message{ID: "later", Body: []byte{0xff, 0x00}}, 200*time.Millisecond))
+
+	d := receive(t, deliveries)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Equal(t, "later", d.Message().ID)
+	assert.Equal(t, []byte{0xff, 0x00}, d.Message().Body)
+	require.NoError(t, d.Ack())
+}
+
+// TestRedisStreamBroker_FullStream checks that a full stream refuses new
+// messages instead of trimming queued ones, while retries still get in
+func TestRedisStreamBroker_FullStream(t *testing.T) {
+	broker := setupTestStreamBroker(t, time.Minute)
+	broker.maxLen = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m1"}))
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m2"}))
+	assert.ErrorIs(t, broker.Publish(ctx, "jobs", &Message{ID: "m3"}), ErrQueueFull)
+	require.NoError(t, broker.PublishDelayed(ctx, "jobs", &Message{ID: "retry"}, 10*time.Millisecond))
+
+	// Consuming starts moving the due retry in, past the cap
+	deliveries, err := broker.Consume(ctx, "jobs", "c1")
+	require.NoError(t, err)
+	for _, id := range []string{"m1", "m2", "retry"} {
+		d := receive(t, deliveries)
+		assert.Equal(t, id, d.Message().ID)
+		require.NoError(t, d.Ack())
+	}
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "m4"}))
+}
+
+// TestRedisStreamBroker_ReclaimsFromDeadConsumer simulates a worker that
+// received a message and died before settling it
+func TestRedisStreamBroker_ReclaimsFromDeadConsumer(t *testing.T) {
+	broker := setupTestStreamBroker(t, 100*time.Millisecond)
+
+	ctx := context.Background()
+	require.NoError(t, broker.ensureGroup(ctx, "jobs"))
+	require.NoError(t, broker.Publish(ctx, "jobs", &Message{ID: "orphan"}))
+
+	// A consumer on another host reads the entry and never acks it
+	_, err := broker.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    broker.group,
+		Consumer: "dead-host-worker-1",
+		Streams:  []string{streamKey("jobs"), ">"},
+		Count:    1,
+	}).Result()
+	require.NoError(t, err)
+
+	consumeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	deliveries, err := broker.Consume(consumeCtx, "jobs", "c1")
+	require.NoError(t, err)
+
+	d := receive(t, deliveries)
+	assert.Equal(t, "orphan", d.Message().ID)
+	require.NoError(t, d.Ack())
+}
+
+func TestRedisStreamBroker_InstanceNamesProcess(t *testing.T) {
+	b, err := NewRedisStreamBroker(nil, "test-workers", 0, time.Second, time.Minute)
+	require.NoError(t, err)
+
+	// Processes on one host, or containers sharing a host name, must not
+	// share consumer names
+	assert.True(t, strings.HasSuffix(b.instance, "-"+strconv.Itoa(os.Getpid())), b.instance)
+}
+
+func TestNewRedisStreamBroker_RejectsShortDurations(t *testing.T) {
+	for _, tc := range []struct {
+		poll, claimIdle time.Duration
+	}{
+		{0, time.Minute},
+		{time.Second, 0},
+		{time.Second, 2 * time.Nanosecond},
+	} {
+		_, err := NewRedisStreamBroker(nil, "test-workers", 0, tc.poll, tc.claimIdle)
+		assert.Error(t, err, "poll %s, claim idle %s", tc.poll, tc.claimIdle)
+	}
+}
@@ -0,0 +1,342 @@
+// Package retention removes task results, uploads and stored objects that
+// are older than the configured retention policy.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/utils"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// objectPrefixes are the store directories whose objects the database
+// tracks; anything else in the store is left alone
+var objectPrefixes = []string{"tasks/", "uploads/"}
+
+// errLimitReached stops an object listing once MaxPerRun is reached
+var errLimitReached = errors.New("retention: limit reached")
+
+// Report is what one run removed, or would remove in a dry run
+type Report struct {
+	DryRun  bool    `json:"dry_run"`
+	Results Removed `json:"results"`
+	Uploads Removed `json:"uploads"`
+	Orphans Removed `json:"orphaned_objects"`
+}
+
+// Removed lists the removed items of one kind: task IDs, upload IDs or
+// storage keys. LimitReached means more may be due on the next run.
+type Removed struct {
+	Count        int      `json:"count"`
+	Bytes        int64    `json:"bytes"`
+	Items        []string `json:"items"`
+	LimitReached bool     `json:"limit_reached,omitempty"`
+}
+
+func (r *Removed) add(item string, bytes int64) {
+	r.Count++
+	r.Items = append(r.Items, item)
+	if bytes > 0 {
+		r.Bytes += bytes
+	}
+}
+
+// Cleaner enforces a retention policy. Rows are always removed before
+// their objects, so a failed object delete leaves an orphan that a later
+// run removes, never a row pointing at nothing.
+type Cleaner struct {
+	repo  RetentionRepositoryInterface
+	db    *sql.DB
+	store artifact.Store
+	cfg   config.RetentionConfig
+	cache *cache.TaskCache
+	user  int
+}
+
+func NewCleaner(repo RetentionRepositoryInterface, db *sql.DB, store artifact.Store, cfg config.RetentionConfig) *Cleaner {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Cleaner{repo: repo, db: db, store: store, cfg: cfg}
+}
+
+// Setup builds the cleaner the worker binaries start: it covers every
+// user's data, orphaned objects included
+func Setup(cfg *config.RetentionConfig, db *sql.DB, store artifact.Store, taskCache *cache.TaskCache) *Cleaner {
+	c := NewCleaner(NewRetentionRepository(), db, store, *cfg)
+	c.SetCache(taskCache)
+	return c
+}
+
+// SetCache makes the cleaner drop the cached entries of tasks whose result
+// it removes
+func (c *Cleaner) SetCache(tc *cache.TaskCache) {
+	c.cache = tc
+}
+
+// SetUser limits runs to one user's results and uploads. Orphaned objects
+// belong to no user, so a limited run skips them.
+func (c *Cleaner) SetUser(userID int) {
+	c.user = userID
+}
+
+// Run removes expired results, then expired uploads, then orphaned
+// objects, each in batches and up to MaxPerRun items. A dry run only
+// reports what would be removed.
+func (c *Cleaner) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	report := &Report{
+		DryRun:  dryRun,
+		Results: Removed{Items: []string{}},
+		Uploads: Removed{Items: []string{}},
+		Orphans: Removed{Items: []string{}},
+	}
+
+	if err := c.cleanResults(ctx, dryRun, &report.Results); err != nil {
+		return nil, err
+	}
+	if err := c.cleanUploads(ctx, dryRun, &report.Uploads); err != nil {
+		return nil, err
+	}
+	if c.user == 0 {
+		if err := c.cleanOrphans(ctx, dryRun, &report.Orphans); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// Start runs the cleaner every Interval in the background; a zero Interval
+// disables it. The returned function stops it and waits for a run in
+// progress to finish.
+func (c *Cleaner) Start() (stop func()) {
+	if c.cfg.Interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.loop(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (c *Cleaner) loop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := c.Run(ctx, false)
+		if err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to enforce retention")
+		}
+		if report != nil && report.Results.Count+report.Uploads.Count+report.Orphans.Count > 0 {
+			logrus.Infof("Retention removed %d results, %d uploads and %d orphaned objects",
+				report.Results.Count, report.Uploads.Count, report.Orphans.Count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// batchLimit is the size of the next batch, 0 once MaxPerRun is reached
+func (c *Cleaner) batchLimit(done int) int {
+	if c.cfg.MaxPerRun <= 0 {
+		return c.cfg.BatchSize
+	}
+	return min(c.cfg.BatchSize, c.cfg.MaxPerRun-done)
+}
+
+func (c *Cleaner) cleanResults(ctx context.Context, dryRun bool, removed *Removed) error {
+	afterID := 0
+	for {
+		limit := c.batchLimit(removed.Count)
+		if limit <= 0 {
+			removed.LimitReached = true
+			return nil
+		}
+
+		expired, err := c.repo.ExpiredResults(ctx, c.db, &c.cfg, c.user, afterID, limit)
+		if err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		afterID = expired[len(expired)-1].TaskID
+
+		if !dryRun {
+			ids := make([]int, len(expired))
+			for i, e := range expired {
+				ids[i] = e.TaskID
+			}
+
+			var keys []string
+			if err := utils.WithTransaction(ctx, c.db, func(tx *sql.Tx) error {
+				keys, err = c.repo.PurgeResults(ctx, tx, ids)
+				return err
+			}); err != nil {
+				return err
+			}
+
+			for _, e := range expired {
+				c.invalidateCache(ctx, e)
+			}
+			c.deleteObjects(ctx, keys)
+		}
+
+		for _, e := range expired {
+			removed.add(strconv.Itoa(e.TaskID), e.Bytes)
+		}
+		if len(expired) < limit {
+			return nil
+		}
+	}
+}
+
+func (c *Cleaner) cleanUploads(ctx context.Context, dryRun bool, removed *Removed) error {
+	afterID := ""
+	for {
+		limit := c.batchLimit(removed.Count)
+		if limit <= 0 {
+			removed.LimitReached = true
+			return nil
+		}
+
+		expired, err := c.repo.ExpiredUploads(ctx, c.db, &c.cfg, c.user, afterID, limit)
+		if err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		afterID = expired[len(expired)-1].ID
+
+		// A task created meanwhile may have started using some of them;
+		// those are kept
+		deleted := expired
+		if !dryRun {
+			ids := make([]string, len(expired))
+			for i, u := range expired {
+				ids[i] = u.ID
+			}
+			if deleted, err = c.repo.DeleteUploads(ctx, c.db, ids); err != nil {
+				return err
+			}
+
+			keys := make([]string, len(deleted))
+			for i, u := range deleted {
+				keys[i] = u.StorageKey
+			}
+			c.deleteObjects(ctx, keys)
+		}
+
+		for _, u := range deleted {
+			removed.add(u.ID, u.Size)
+		}
+		if len(expired) < limit {
+			return nil
+		}
+	}
+}
+
+// cleanOrphans deletes objects below objectPrefixes that no row refers to.
+// Handlers store objects before recording them, so objects younger than
+// OrphanGrace are skipped; a zero grace disables the sweep.
+func (c *Cleaner) cleanOrphans(ctx context.Context, dryRun bool, removed *Removed) error {
+	if c.cfg.OrphanGrace <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-c.cfg.OrphanGrace)
+
+	var batch []artifact.ObjectInfo
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		keys := make([]string, len(batch))
+		for i, info := range batch {
+			keys[i] = info.Key
+		}
+		known, err := c.repo.KnownKeys(ctx, c.db, keys)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range batch {
+			if known[info.Key] {
+				continue
+			}
+			if c.batchLimit(removed.Count) <= 0 {
+				removed.LimitReached = true
+				return errLimitReached
+			}
+			if !dryRun {
+				if err := c.store.Delete(ctx, info.Key); err != nil {
+					logrus.WithError(err).Warnf("Failed to delete orphaned object %s", info.Key)
+					continue
+				}
+			}
+			removed.add(info.Key, info.Size)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, prefix := range objectPrefixes {
+		err := c.store.List(ctx, prefix, func(info artifact.ObjectInfo) error {
+			if !info.ModTime.Before(cutoff) {
+				return nil
+			}
+			batch = append(batch, info)
+			if len(batch) < c.cfg.BatchSize {
+				return nil
+			}
+			return flush()
+		})
+		if err == nil {
+			err = flush()
+		}
+		if errors.Is(err, errLimitReached) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteObjects is best effort: an object left behind is an orphan
+func (c *Cleaner) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := c.store.Delete(ctx, key); err != nil {
+			logrus.WithError(err).Warnf("Failed to delete object %s, it is left as an orphan", key)
+		}
+	}
+}
+
+func (c *Cleaner) invalidateCache(ctx context.Context, e *ExpiredResult) {
+	if c.cache == nil {
+		return
+	}
+	if err := c.cache.Invalidate(ctx, e.TaskID, e.UserID); err != nil {
+		logrus.WithError(err).Warn("Failed to invalidate task cache")
+	}
+}
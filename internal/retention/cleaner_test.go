@@ -0,0 +1,263 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+	"task_handler/internal/upload"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRetentionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRetentionRepository) ExpiredResults(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID int, limit int) ([]*ExpiredResult, error) {
+	args := m.Called(ctx, db, cfg, userID, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*ExpiredResult), args.Error(1)
+}
+
+func (m *MockRetentionRepository) PurgeResults(ctx context.Context, tx *sql.Tx, taskIDs []int) ([]string, error) {
+	args := m.Called(ctx, tx, taskIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRetentionRepository) ExpiredUploads(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID string, limit int) ([]*upload.Upload, error) {
+	args := m.Called(ctx, db, cfg, userID, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*upload.Upload), args.Error(1)
+}
+
+func (m *MockRetentionRepository) DeleteUploads(ctx context.Context, db *sql.DB, ids []string) ([]*upload.Upload, error) {
+	args := m.Called(ctx, db, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*upload.Upload), args.Error(1)
+}
+
+func (m *MockRetentionRepository) KnownKeys(ctx context.Context, db *sql.DB, keys []string) (map[string]bool, error) {
+	args := m.Called(ctx, db, keys)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+var testConfig = config.RetentionConfig{
+	Results:     time.Hour,
+	Uploads:     time.Hour,
+	OrphanGrace: time.Hour,
+	BatchSize:   2,
+	MaxPerRun:   10,
+}
+
+// newTestCleaner uses a local store whose root is returned so tests can
+// age objects
+func newTestCleaner(t *testing.T, cfg config.RetentionConfig) (*Cleaner, *MockRetentionRepository, artifact.Store, string) {
+	root := t.TempDir()
+	store, err := artifact.NewLocalStore(root)
+	require.NoError(t, err)
+
+	repo := new(MockRetentionRepository)
+	return NewCleaner(repo, nil, store, cfg), repo, store, root
+}
+
+// putObject stores key with a modification time age in the past
+func putObject(t *testing.T, store artifact.Store, root, key string, age time.Duration) {
+	require.NoError(t, store.Put(context.Background(), key, strings.NewReader("data"), 4, "text/plain"))
+	old := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), old, old))
+}
+
+func exists(t *testing.T, store artifact.Store, key string) bool {
+	obj, err := store.Get(context.Background(), key)
+	if err != nil {
+		require.ErrorIs(t, err, artifact.ErrNotFound)
+		return false
+	}
+	obj.Close()
+	return true
+}
+
+func noUploads(repo *MockRetentionRepository) {
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 0, "", mock.Anything).Return([]*upload.Upload{}, nil)
+}
+
+func TestRun_DryRunRemovesNothing(t *testing.T) {
+	cfg := testConfig
+	cfg.OrphanGrace = 0
+	cleaner, repo, _, _ := newTestCleaner(t, cfg)
+	ctx := context.Background()
+
+	// Batches of two, keyset paginated by task ID
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 2).
+		Return([]*ExpiredResult{{TaskID: 3, Bytes: 10}, {TaskID: 5, Bytes: 20}}, nil)
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 5, 2).
+		Return([]*ExpiredResult{{TaskID: 8, Bytes: 5}}, nil)
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 0, "", 2).
+		Return([]*upload.Upload{{ID: "u1", Size: 100, StorageKey: "uploads/u1"}}, nil)
+
+	report, err := cleaner.Run(ctx, true)
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, Removed{Count: 3, Bytes: 35, Items: []string{"3", "5", "8"}}, report.Results)
+	assert.Equal(t, Removed{Count: 1, Bytes: 100, Items: []string{"u1"}}, report.Uploads)
+	assert.Equal(t, 0, report.Orphans.Count)
+	repo.AssertNotCalled(t, "PurgeResults", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "DeleteUploads", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRun_MaxPerRun(t *testing.T) {
+	cfg := testConfig
+	cfg.MaxPerRun = 3
+	cfg.OrphanGrace = 0
+	cleaner, repo, _, _ := newTestCleaner(t, cfg)
+
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 2).
+		Return([]*ExpiredResult{{TaskID: 1}, {TaskID: 2}}, nil)
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 2, 1).
+		Return([]*ExpiredResult{{TaskID: 3}}, nil)
+	noUploads(repo)
+
+	report, err := cleaner.Run(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Results.Count)
+	assert.True(t, report.Results.LimitReached)
+}
+
+func TestRun_DeletesUploadsStillUnreferenced(t *testing.T) {
+	cfg := testConfig
+	cfg.OrphanGrace = 0
+	cleaner, repo, store, root := newTestCleaner(t, cfg)
+	ctx := context.Background()
+
+	putObject(t, store, root, "uploads/u1", 2*time.Hour)
+	putObject(t, store, root, "uploads/u2", 2*time.Hour)
+
+	u1 := &upload.Upload{ID: "u1", Size: 4, StorageKey: "uploads/u1"}
+	u2 := &upload.Upload{ID: "u2", Size: 4, StorageKey: "uploads/u2"}
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 2).Return([]*ExpiredResult{}, nil)
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 0, "", 2).Return([]*upload.Upload{u1, u2}, nil)
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 0, "u2", 2).Return([]*upload.Upload{}, nil)
+	// A task started using u2 in the meantime
+	repo.On("DeleteUploads", mock.Anything, mock.Anything, []string{"u1", "u2"}).Return([]*upload.Upload{u1}, nil)
+
+	report, err := cleaner.Run(ctx, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"u1"}, report.Uploads.Items)
+	assert.False(t, exists(t, store, "uploads/u1"))
+	assert.True(t, exists(t, store, "uploads/u2"))
+}
+
+func TestRun_Orphans(t *testing.T) {
+	cleaner, repo, store, root := newTestCleaner(t, testConfig)
+	ctx := context.Background()
+
+	putObject(t, store, root, "tasks/1/report.csv", 2*time.Hour)  // recorded
+	putObject(t, store, root, "tasks/2/report.csv", 2*time.Hour)  // orphan
+	putObject(t, store, root, "tasks/3/.upload-123", 2*time.Hour) // temp file of a crashed Put
+	putObject(t, store, root, "tasks/4/result.json", time.Minute) // being recorded right now
+	putObject(t, store, root, "uploads/gone", 2*time.Hour)        // orphan
+	putObject(t, store, root, "elsewhere/keep.txt", 2*time.Hour)  // not managed by the database
+
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 2).Return([]*ExpiredResult{}, nil)
+	noUploads(repo)
+	repo.On("KnownKeys", mock.Anything, mock.Anything, mock.Anything).Return(map[string]bool{"tasks/1/report.csv": true}, nil)
+
+	report, err := cleaner.Run(ctx, false)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"tasks/2/report.csv", "tasks/3/.upload-123", "uploads/gone"}, report.Orphans.Items)
+	assert.Equal(t, int64(12), report.Orphans.Bytes)
+
+	assert.True(t, exists(t, store, "tasks/1/report.csv"))
+	assert.False(t, exists(t, store, "tasks/2/report.csv"))
+	assert.True(t, exists(t, store, "tasks/4/result.json"))
+	assert.False(t, exists(t, store, "uploads/gone"))
+	assert.True(t, exists(t, store, "elsewhere/keep.txt"))
+}
+
+func TestRun_OrphansDryRunAndLimit(t *testing.T) {
+	cfg := testConfig
+	cfg.MaxPerRun = 1
+	cleaner, repo, store, root := newTestCleaner(t, cfg)
+
+	putObject(t, store, root, "tasks/1/a.txt", 2*time.Hour)
+	putObject(t, store, root, "tasks/2/b.txt", 2*time.Hour)
+
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 1).Return([]*ExpiredResult{}, nil)
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 0, "", 1).Return([]*upload.Upload{}, nil)
+	repo.On("KnownKeys", mock.Anything, mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+
+	report, err := cleaner.Run(context.Background(), true)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Orphans.Count)
+	assert.True(t, report.Orphans.LimitReached)
+	assert.True(t, exists(t, store, "tasks/1/a.txt"))
+	assert.True(t, exists(t, store, "tasks/2/b.txt"))
+}
+
+func TestRun_SetUser(t *testing.T) {
+	cleaner, repo, store, root := newTestCleaner(t, testConfig)
+	cleaner.SetUser(7)
+
+	putObject(t, store, root, "tasks/2/report.csv", 2*time.Hour) // orphan of some user
+
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 7, 0, 2).
+		Return([]*ExpiredResult{{TaskID: 3, UserID: 7, Bytes: 10}}, nil)
+	repo.On("ExpiredUploads", mock.Anything, mock.Anything, mock.Anything, 7, "", 2).Return([]*upload.Upload{}, nil)
+
+	report, err := cleaner.Run(context.Background(), true)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"3"}, report.Results.Items)
+	assert.Empty(t, report.Orphans.Items)
+	repo.AssertNotCalled(t, "KnownKeys", mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, exists(t, store, "tasks/2/report.csv"))
+}
+
+func TestStart_SweepsOrphans(t *testing.T) {
+	cfg := testConfig
+	cfg.Interval = time.Hour
+	cleaner, repo, store, root := newTestCleaner(t, cfg)
+
+	putObject(t, store, root, "tasks/2/report.csv", 2*time.Hour)
+
+	repo.On("ExpiredResults", mock.Anything, mock.Anything, mock.Anything, 0, 0, 2).Return([]*ExpiredResult{}, nil)
+	noUploads(repo)
+	repo.On("KnownKeys", mock.Anything, mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+
+	// The first run starts at once
+	stop := cleaner.Start()
+	defer stop()
+	assert.Eventually(t, func() bool { return !exists(t, store, "tasks/2/report.csv") }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStart_Disabled(t *testing.T) {
+	cleaner, repo, _, _ := newTestCleaner(t, testConfig)
+
+	cleaner.Start()()
+	repo.AssertNotCalled(t, "ExpiredResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
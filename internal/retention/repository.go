@@ -0,0 +1,241 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+	"task_handler/internal/upload"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExpiredResult is a finished task whose result is past its retention
+type ExpiredResult struct {
+	TaskID     int
+	UserID     int
+	TaskType   string
+	FinishedAt time.Time
+	Bytes      int64 // Inline result plus artifacts
+}
+
+type RetentionRepository struct{}
+
+type RetentionRepositoryInterface interface {
+	ExpiredResults(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID int, limit int) ([]*ExpiredResult, error)
+	PurgeResults(ctx context.Context, tx *sql.Tx, taskIDs []int) ([]string, error)
+	ExpiredUploads(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID string, limit int) ([]*upload.Upload, error)
+	DeleteUploads(ctx context.Context, db *sql.DB, ids []string) ([]*upload.Upload, error)
+	KnownKeys(ctx context.Context, db *sql.DB, keys []string) (map[string]bool, error)
+}
+
+func NewRetentionRepository() RetentionRepositoryInterface {
+	return &RetentionRepository{}
+}
+
+// userRules and typeRules pass the overrides to Postgres as parallel arrays
+// for unnest
+func userRules(cfg *config.RetentionConfig) ([]int64, []float64) {
+	ids := make([]int64, 0, len(cfg.ByUser))
+	secs := make([]float64, 0, len(cfg.ByUser))
+	for id, d := range cfg.ByUser {
+		ids = append(ids, int64(id))
+		secs = append(secs, d.Seconds())
+	}
+	return ids, secs
+}
+
+func typeRules(cfg *config.RetentionConfig) ([]string, []float64) {
+	types := make([]string, 0, len(cfg.ResultsByType))
+	secs := make([]float64, 0, len(cfg.ResultsByType))
+	for taskType, d := range cfg.ResultsByType {
+		types = append(types, taskType)
+		secs = append(secs, d.Seconds())
+	}
+	return types, secs
+}
+
+// ExpiredResults lists, in ID order after afterID, successful tasks whose
+// result or artifacts are older than the rule that applies to them: the
+// user's, else the task type's, else the default. A non-zero userID limits
+// the list to that user's tasks.
+func (r *RetentionRepository) ExpiredResults(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID int, limit int) ([]*ExpiredResult, error) {
+	query := `
+		SELECT t.id, t.user_id, t.task_type, t.updated_at,
+			COALESCE(octet_length(t.result::text), 0)
+				+ (SELECT COALESCE(SUM(a.size), 0) FROM task_artifacts a WHERE a.task_id = t.id)
+		FROM tasks t
+		LEFT JOIN unnest($1::bigint[], $2::float8[]) AS u(user_id, secs) ON u.user_id = t.user_id
+		LEFT JOIN unnest($3::text[], $4::float8[]) AS k(task_type, secs) ON k.task_type = t.task_type
+		WHERE t.id > $5
+			AND t.status = $6
+			AND t.result_expired_at IS NULL
+			AND COALESCE(u.secs, k.secs, $7) > 0
+			AND t.updated_at < NOW() - make_interval(secs => COALESCE(u.secs, k.secs, $7))
+			AND (t.result IS NOT NULL OR EXISTS (SELECT 1 FROM task_artifacts a WHERE a.task_id = t.id))
+			AND ($9 = 0 OR t.user_id = $9)
+		ORDER BY t.id
+		LIMIT $8
+	`
+
+	userIDs, userSecs := userRules(cfg)
+	types, typeSecs := typeRules(cfg)
+	rows, err := db.QueryContext(ctx, query,
+		userIDs, userSecs, types, typeSecs,
+		afterID, string(task.StatusSuccess), cfg.Results.Seconds(), limit, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var results []*ExpiredResult
+	for rows.Next() {
+		var e ExpiredResult
+		if err := rows.Scan(&e.TaskID, &e.UserID, &e.TaskType, &e.FinishedAt, &e.Bytes); err != nil {
+			return nil, err
+		}
+		results = append(results, &e)
+	}
+
+	return results, rows.Err()
+}
+
+// PurgeResults drops the inline results and artifact rows of the tasks and
+// marks the results expired. It returns the storage keys of the removed
+// artifacts, whose objects the caller deletes after commit.
+func (r *RetentionRepository) PurgeResults(ctx context.Context, tx *sql.Tx, taskIDs []int) ([]string, error) {
+	ids := make([]int64, len(taskIDs))
+	for i, id := range taskIDs {
+		ids[i] = int64(id)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tasks
+		SET result = NULL, result_file = NULL, result_expired_at = NOW()
+		WHERE id = ANY($1) AND result_expired_at IS NULL
+	`, ids); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM task_artifacts WHERE task_id = ANY($1) RETURNING storage_key`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// unreferencedUpload matches uploads no PENDING or PROCESSING task reads
+const unreferencedUpload = `
+	NOT EXISTS (
+		SELECT 1 FROM task_uploads tu
+		JOIN tasks t ON t.id = tu.task_id
+		WHERE tu.upload_id = up.id
+			AND t.status IN ('PENDING', 'PROCESSING')
+	)
+`
+
+// ExpiredUploads lists, in ID order after afterID, unreferenced uploads
+// older than the user's rule, else the default. A non-zero userID limits
+// the list to that user's uploads.
+func (r *RetentionRepository) ExpiredUploads(ctx context.Context, db *sql.DB, cfg *config.RetentionConfig, userID int, afterID string, limit int) ([]*upload.Upload, error) {
+	query := `
+		SELECT up.id, up.user_id, up.filename, up.content_type, up.size, up.storage_key, up.created_at
+		FROM uploads up
+		LEFT JOIN unnest($1::bigint[], $2::float8[]) AS u(user_id, secs) ON u.user_id = up.user_id
+		WHERE up.id > $3
+			AND COALESCE(u.secs, $4) > 0
+			AND up.created_at < NOW() - make_interval(secs => COALESCE(u.secs, $4))
+			AND ($6 = 0 OR up.user_id = $6)
+			AND ` + unreferencedUpload + `
+		ORDER BY up.id
+		LIMIT $5
+	`
+
+	userIDs, userSecs := userRules(cfg)
+	return queryUploads(ctx, db, query, userIDs, userSecs, afterID, cfg.Uploads.Seconds(), limit, userID)
+}
+
+// DeleteUploads removes the uploads that are still unreferenced and
+// returns them
+func (r *RetentionRepository) DeleteUploads(ctx context.Context, db *sql.DB, ids []string) ([]*upload.Upload, error) {
+	query := `
+		DELETE FROM uploads up
+		WHERE up.id = ANY($1) AND ` + unreferencedUpload + `
+		RETURNING up.id, up.user_id, up.filename, up.content_type, up.size, up.storage_key, up.created_at
+	`
+	return queryUploads(ctx, db, query, ids)
+}
+
+func queryUploads(ctx context.Context, db *sql.DB, query string, args ...any) ([]*upload.Upload, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var uploads []*upload.Upload
+	for rows.Next() {
+		var u upload.Upload
+		if err := rows.Scan(&u.ID, &u.UserID, &u.Filename, &u.ContentType, &u.Size, &u.StorageKey, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, &u)
+	}
+
+	return uploads, rows.Err()
+}
+
+// KnownKeys returns which of keys a task artifact or an upload refers to
+func (r *RetentionRepository) KnownKeys(ctx context.Context, db *sql.DB, keys []string) (map[string]bool, error) {
+	query := `
+		SELECT storage_key FROM task_artifacts WHERE storage_key = ANY($1)
+		UNION
+		SELECT storage_key FROM uploads WHERE storage_key = ANY($1)
+	`
+
+	rows, err := db.QueryContext(ctx, query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	known := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		known[key] = true
+	}
+
+	return known, rows.Err()
+}
@@ -0,0 +1,203 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"task_handler/internal/config"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownCommand = errors.New("command is not in the exec allowlist")
+	ErrInvalidArgs    = errors.New("invalid command arguments")
+)
+
+// WorkDirParam is the argument name under which templates find the task's
+// working directory; tasks cannot set it
+const WorkDirParam = "workdir"
+
+// paramName keeps argument names usable as template fields
+var paramName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Command is one allowlisted program. Every element of Args is a
+// text/template rendered with the task's arguments into exactly one argv
+// entry, so values are never split or interpreted by a shell. Each
+// argument must be declared in Params with a regular expression its whole
+// value has to match.
+type Command struct {
+	Path    string            `json:"path"`
+	Args    []string          `json:"args"`
+	Params  map[string]string `json:"params"`
+	Timeout string            `json:"timeout"` // Overrides EXEC_TIMEOUT, e.g. "10m"
+	Env     map[string]string `json:"env"`     // Added to the filtered environment
+
+	name     string
+	args     []*template.Template
+	patterns map[string]*regexp.Regexp
+	timeout  time.Duration
+}
+
+// Allowlist is the set of commands exec tasks may run, keyed by name
+type Allowlist struct {
+	commands map[string]*Command
+}
+
+// Setup loads the allowlist named by EXEC_COMMANDS_FILE. Without one the
+// allowlist is empty and every exec task fails.
+func Setup(cfg *config.ExecConfig) *Allowlist {
+	if cfg.CommandsFile == "" {
+		logrus.Info("EXEC_COMMANDS_FILE is not set, exec tasks are disabled")
+		return &Allowlist{}
+	}
+
+	allowlist, err := LoadAllowlist(cfg.CommandsFile)
+	if err != nil {
+		logrus.Fatalf("Invalid exec allowlist: %v", err)
+	}
+	logrus.Infof("Loaded exec commands: %v", allowlist.Names())
+	return allowlist
+}
+
+// LoadAllowlist reads a file of the form
+//
+//	{"commands": {"name": {"path": "/abs/path", "args": [...], "params": {...}}}}
+func LoadAllowlist(path string) (*Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAllowlist(data)
+}
+
+func ParseAllowlist(data []byte) (*Allowlist, error) {
+	var file struct {
+		Commands map[string]*Command `json:"commands"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	for name, c := range file.Commands {
+		if err := c.compile(name); err != nil {
+			return nil, fmt.Errorf("command %s: %w", name, err)
+		}
+	}
+	return &Allowlist{commands: file.Commands}, nil
+}
+
+func (c *Command) compile(name string) error {
+	c.name = name
+	if !paramName.MatchString(name) {
+		return fmt.Errorf("name must match %s", paramName)
+	}
+
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %q must be absolute", c.Path)
+	}
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable", c.Path)
+	}
+
+	c.patterns = make(map[string]*regexp.Regexp, len(c.Params))
+	for param, pattern := range c.Params {
+		if !paramName.MatchString(param) || param == WorkDirParam {
+			return fmt.Errorf("invalid param name %q", param)
+		}
+		if pattern == "" {
+			return fmt.Errorf("param %s needs a pattern", param)
+		}
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("param %s: %w", param, err)
+		}
+		c.patterns[param] = re
+	}
+
+	c.args = make([]*template.Template, len(c.Args))
+	for i, arg := range c.Args {
+		t, err := template.New(fmt.Sprintf("arg%d", i)).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return err
+		}
+		c.args[i] = t
+	}
+
+	if c.Timeout != "" {
+		if c.timeout, err = time.ParseDuration(c.Timeout); err != nil || c.timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q", c.Timeout)
+		}
+	}
+	return nil
+}
+
+// Names lists the allowed commands
+func (a *Allowlist) Names() []string {
+	names := make([]string, 0, len(a.commands))
+	for name := range a.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (a *Allowlist) Get(name string) (*Command, error) {
+	c, ok := a.commands[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCommand, name)
+	}
+	return c, nil
+}
+
+func (c *Command) Name() string {
+	return c.name
+}
+
+// TimeoutOr returns the command's own timeout, or def when it has none
+func (c *Command) TimeoutOr(def time.Duration) time.Duration {
+	if c.timeout > 0 {
+		return c.timeout
+	}
+	return def
+}
+
+// Argv validates the task's arguments and renders the command's argument
+// list. An argument a template needs but the task did not give is an error.
+func (c *Command) Argv(args map[string]string, workDir string) ([]string, error) {
+	data := make(map[string]string, len(args)+1)
+	for name, value := range args {
+		re, ok := c.patterns[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown argument %q", ErrInvalidArgs, name)
+		}
+		if !re.MatchString(value) {
+			return nil, fmt.Errorf("%w: %s does not match %s", ErrInvalidArgs, name, c.Params[name])
+		}
+		data[name] = value
+	}
+	data[WorkDirParam] = workDir
+
+	argv := make([]string, len(c.args))
+	for i, t := range c.args {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgs, err)
+		}
+		argv[i] = buf.String()
+	}
+	return argv, nil
+}
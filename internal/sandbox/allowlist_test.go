@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowlist(t *testing.T) {
+	a, err := ParseAllowlist([]byte(`{"commands": {
+		"greet": {
+			"path": "/bin/sh",
+			"args": ["-c", "echo \"$1\" > \"$2\"", "sh", "hello {{.name}}", "{{.workdir}}/out"],
+			"params": {"name": "[a-z]+"},
+			"timeout": "10s"
+		}
+	}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greet"}, a.Names())
+
+	c, err := a.Get("greet")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, c.TimeoutOr(time.Minute))
+
+	argv, err := c.Argv(map[string]string{"name": "bob"}, "/tmp/w")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-c", `echo "$1" > "$2"`, "sh", "hello bob", "/tmp/w/out"}, argv)
+
+	_, err = a.Get("rm")
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+
+	for name, args := range map[string]map[string]string{
+		"partial match":  {"name": "bob; rm -rf /"},
+		"undeclared arg": {"name": "bob", "other": "x"},
+		"workdir":        {"name": "bob", "workdir": "/"},
+		"missing arg":    {},
+	} {
+		_, err := c.Argv(args, "/tmp/w")
+		assert.ErrorIs(t, err, ErrInvalidArgs, name)
+	}
+}
+
+func TestParseAllowlist_Invalid(t *testing.T) {
+	plain := filepath.Join(t.TempDir(), "plain")
+	require.NoError(t, os.WriteFile(plain, []byte("#!/bin/sh\n"), 0o644))
+
+	for name, raw := range map[string]string{
+		"relative path":  `{"commands": {"a": {"path": "sh"}}}`,
+		"missing path":   `{"commands": {"a": {"path": "/no/such/binary"}}}`,
+		"not executable": `{"commands": {"a": {"path": "` + plain + `"}}}`,
+		"empty pattern":  `{"commands": {"a": {"path": "/bin/sh", "params": {"x": ""}}}}`,
+		"bad pattern":    `{"commands": {"a": {"path": "/bin/sh", "params": {"x": "("}}}}`,
+		"reserved param": `{"commands": {"a": {"path": "/bin/sh", "params": {"workdir": ".*"}}}}`,
+		"bad template":   `{"commands": {"a": {"path": "/bin/sh", "args": ["{{.x"]}}}`,
+		"bad timeout":    `{"commands": {"a": {"path": "/bin/sh", "timeout": "soon"}}}`,
+		"unknown field":  `{"commands": {"a": {"path": "/bin/sh", "shell": true}}}`,
+		"bad name":       `{"commands": {"A B": {"path": "/bin/sh"}}}`,
+	} {
+		_, err := ParseAllowlist([]byte(raw))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,45 @@
+// Package sandbox runs allowlisted commands for exec tasks with resource
+// limits. The limits are applied by a launcher: the worker binary started
+// again with the Subcommand argument, which sets its own rlimits and then
+// replaces itself with the command, so the command never runs unlimited.
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Subcommand is the argument every binary that runs exec tasks must hand
+// to Main
+const Subcommand = "sandbox-exec"
+
+// waitDelay bounds how long Wait waits for output pipes held open by
+// processes the command left behind
+const waitDelay = 5 * time.Second
+
+// Limits are the rlimits of a command; zero values are not set
+type Limits struct {
+	CPUSeconds  int
+	MemoryBytes int64
+	OpenFiles   int
+}
+
+// CommandContext prepares path to run with args under limits through launcher,
+// normally os.Executable(). The command gets its own process group, which
+// is killed when ctx is done.
+func CommandContext(ctx context.Context, launcher string, limits Limits, path string, args ...string) *exec.Cmd {
+	argv := []string{
+		Subcommand,
+		"-cpu", strconv.Itoa(limits.CPUSeconds),
+		"-mem", strconv.FormatInt(limits.MemoryBytes, 10),
+		"-nofile", strconv.Itoa(limits.OpenFiles),
+		"--", path,
+	}
+
+	cmd := exec.CommandContext(ctx, launcher, append(argv, args...)...)
+	cmd.WaitDelay = waitDelay
+	isolate(cmd)
+	return cmd
+}
@@ -0,0 +1,72 @@
+package sandbox
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Main implements the launcher: it sets the limits given as flags and
+// executes the command after "--". It only returns, with the exit code, on
+// failure.
+func Main(args []string) int {
+	fs := flag.NewFlagSet(Subcommand, flag.ContinueOnError)
+	cpu := fs.Uint64("cpu", 0, "RLIMIT_CPU in seconds")
+	mem := fs.Uint64("mem", 0, "RLIMIT_AS in bytes")
+	nofile := fs.Uint64("nofile", 0, "RLIMIT_NOFILE")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s [-cpu N] [-mem N] [-nofile N] -- path [args...]\n", Subcommand)
+		return 2
+	}
+
+	for _, limit := range []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, *cpu},
+		{syscall.RLIMIT_AS, *mem},
+		{syscall.RLIMIT_NOFILE, *nofile},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(limit.resource, &syscall.Rlimit{Cur: limit.value, Max: limit.value}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: setrlimit: %v\n", Subcommand, err)
+			return 126
+		}
+	}
+
+	err := syscall.Exec(argv[0], argv, os.Environ())
+	fmt.Fprintf(os.Stderr, "%s: %s: %v\n", Subcommand, argv[0], err)
+	return 127
+}
+
+// isolate puts the command in its own process group so that cancelling
+// kills everything it started, not just the launcher's process
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// KillGroup kills whatever the command left running after it exited
+func KillGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// Signal names the signal that ended the process, "" when it exited
+func Signal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the launcher
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == Subcommand {
+		os.Exit(Main(os.Args[2:]))
+	}
+	os.Exit(m.Run())
+}
+
+func launcher(t *testing.T) string {
+	path, err := os.Executable()
+	require.NoError(t, err)
+	return path
+}
+
+func TestCommandContext_Limits(t *testing.T) {
+	limits := Limits{CPUSeconds: 5, MemoryBytes: 512 << 20, OpenFiles: 32}
+	cmd := CommandContext(context.Background(), launcher(t), limits, "/bin/sh", "-c", "ulimit -n; ulimit -t; ulimit -v")
+
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"32", "5", "524288"}, strings.Fields(string(out)))
+}
+
+func TestCommandContext_CPULimit(t *testing.T) {
+	cmd := CommandContext(context.Background(), launcher(t), Limits{CPUSeconds: 1}, "/bin/sh", "-c", "while :; do :; done")
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Contains(t, []string{"killed", "CPU time limit exceeded"}, Signal(cmd.ProcessState))
+}
+
+func TestCommandContext_CancelKillsGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep keeps stdout open; killing only the shell would
+	// leave Wait blocked until WaitDelay
+	cmd := CommandContext(ctx, launcher(t), Limits{}, "/bin/sh", "-c", "sleep 30 & sleep 30")
+	var out strings.Builder
+	cmd.Stdout = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), waitDelay)
+	assert.Equal(t, "killed", Signal(cmd.ProcessState))
+}
+
+func TestMain_ExecFailure(t *testing.T) {
+	cmd := CommandContext(context.Background(), launcher(t), Limits{}, "/no/such/binary")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 127, exitErr.ExitCode())
+}
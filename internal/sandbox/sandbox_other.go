@@ -0,0 +1,23 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Main refuses to run commands: rlimits are only applied on Linux
+func Main(args []string) int {
+	fmt.Fprintf(os.Stderr, "%s: exec tasks are only supported on Linux\n", Subcommand)
+	return 126
+}
+
+func isolate(cmd *exec.Cmd) {}
+
+func KillGroup(cmd *exec.Cmd) {}
+
+func Signal(state *os.ProcessState) string {
+	return ""
+}
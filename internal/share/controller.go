@@ -0,0 +1,180 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"task_handler/internal/artifact"
+	"task_handler/internal/auth"
+	"task_handler/internal/task"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareController struct {
+	service ShareServiceInterface
+}
+
+func NewShareController(service ShareServiceInterface) *ShareController {
+	return &ShareController{
+		service: service,
+	}
+}
+
+// CreateShare creates a signed link to one artifact of the caller's task
+func (sc *ShareController) CreateShare(c *gin.Context) {
+	var req struct {
+		ExpiresIn int  `json:"expires_in"` // seconds, 0 for the default
+		SingleUse bool `json:"single_use"`
+	}
+
+	// The body is optional
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must not be negative"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	name := c.Param("name")
+	if !artifact.ValidName(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	share, err := sc.service.CreateShare(c.Request.Context(), userID, taskID, name, ttl, req.SingleUse)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, sc.response(share))
+}
+
+// ListShares lists every link created for the caller's task
+func (sc *ShareController) ListShares(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	shares, err := sc.service.ListShares(c.Request.Context(), userID, taskID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	list := make([]gin.H, 0, len(shares))
+	for _, share := range shares {
+		list = append(list, sc.response(share))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shares": list,
+		"count":  len(list),
+	})
+}
+
+// RevokeShare disables a link created by the caller
+func (sc *ShareController) RevokeShare(c *gin.Context) {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := sc.service.RevokeShare(c.Request.Context(), userID, c.Param("share_id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Download is the public, unauthenticated side of a share link
+func (sc *ShareController) Download(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || c.Query("sig") == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid share link"})
+		return
+	}
+
+	a, obj, err := sc.service.OpenShare(c.Request.Context(), c.Param("share_id"), expires, c.Query("sig"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer obj.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, a.ContentType, obj, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+		"Cache-Control":       "private, no-store",
+	})
+}
+
+func (sc *ShareController) response(share *Share) gin.H {
+	return gin.H{
+		"share_id":       share.ID,
+		"task_id":        share.TaskID,
+		"artifact_name":  share.ArtifactName,
+		"single_use":     share.SingleUse,
+		"expires_at":     share.ExpiresAt,
+		"revoked_at":     share.RevokedAt,
+		"used_at":        share.UsedAt,
+		"download_count": share.DownloadCount,
+		"created_at":     share.CreatedAt,
+		"url":            sc.service.URL(share),
+	}
+}
+
+// writeError maps service errors to responses
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTTL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only share your own tasks"})
+	case errors.Is(err, ErrInvalidSignature):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid share link"})
+	case errors.Is(err, ErrShareExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has expired"})
+	case errors.Is(err, ErrShareUnavailable):
+		c.JSON(http.StatusGone, gin.H{"error": "Share link is no longer available"})
+	case errors.Is(err, ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+	case errors.Is(err, task.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, task.ErrArtifactNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process share request"})
+	}
+}
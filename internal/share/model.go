@@ -0,0 +1,39 @@
+package share
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrShareNotFound = errors.New("share not found")
+	// ErrInvalidSignature means the link was altered or signed with another key
+	ErrInvalidSignature = errors.New("invalid share signature")
+	ErrShareExpired     = errors.New("share link expired")
+	// ErrShareUnavailable means the link was revoked or, if single-use, already used
+	ErrShareUnavailable = errors.New("share link no longer available")
+	ErrForbidden        = errors.New("not the owner of the task")
+	ErrInvalidTTL       = errors.New("invalid share lifetime")
+)
+
+// Share is a public download link for one task artifact
+type Share struct {
+	ID            string
+	TaskID        int
+	ArtifactName  string
+	UserID        int
+	SingleUse     bool
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	UsedAt        *time.Time
+	DownloadCount int
+	CreatedAt     time.Time
+}
+
+// Active reports whether the link may still be used at now
+func (s *Share) Active(now time.Time) bool {
+	if s.RevokedAt != nil || !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return !s.SingleUse || s.UsedAt == nil
+}
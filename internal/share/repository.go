@@ -0,0 +1,159 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ShareRepository struct{}
+
+type ShareRepositoryInterface interface {
+	Create(ctx context.Context, db *sql.DB, share *Share) error
+	GetByID(ctx context.Context, db *sql.DB, id string) (*Share, error)
+	GetByTaskID(ctx context.Context, db *sql.DB, taskID int) ([]*Share, error)
+	Claim(ctx context.Context, db *sql.DB, id string) error
+	Revoke(ctx context.Context, db *sql.DB, id string) error
+}
+
+func NewShareRepository() ShareRepositoryInterface {
+	return &ShareRepository{}
+}
+
+const shareColumns = `
+	id, task_id, artifact_name, user_id, single_use,
+	expires_at, revoked_at, used_at, download_count, created_at
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanShare(row scanner) (*Share, error) {
+	var s Share
+	err := row.Scan(
+		&s.ID,
+		&s.TaskID,
+		&s.ArtifactName,
+		&s.UserID,
+		&s.SingleUse,
+		&s.ExpiresAt,
+		&s.RevokedAt,
+		&s.UsedAt,
+		&s.DownloadCount,
+		&s.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ShareRepository) Create(ctx context.Context, db *sql.DB, share *Share) error {
+	query := `
+		INSERT INTO artifact_shares (
+			id, task_id, artifact_name, user_id, single_use, expires_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING created_at
+	`
+
+	return db.QueryRowContext(
+		ctx,
+		query,
+		share.ID,
+		share.TaskID,
+		share.ArtifactName,
+		share.UserID,
+		share.SingleUse,
+		share.ExpiresAt,
+	).Scan(&share.CreatedAt)
+}
+
+func (r *ShareRepository) GetByID(ctx context.Context, db *sql.DB, id string) (*Share, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+shareColumns+` FROM artifact_shares WHERE id = $1`, id)
+
+	s, err := scanShare(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareNotFound
+	}
+	return s, err
+}
+
+func (r *ShareRepository) GetByTaskID(ctx context.Context, db *sql.DB, taskID int) ([]*Share, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+shareColumns+` FROM artifact_shares WHERE task_id = $1 ORDER BY created_at`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var shares []*Share
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
+
+// Claim counts one download. It fails with ErrShareUnavailable when the
+// link was revoked, has expired, or is single-use and was claimed before;
+// the check and the update are one statement, so two concurrent downloads
+// of a single-use link cannot both succeed.
+func (r *ShareRepository) Claim(ctx context.Context, db *sql.DB, id string) error {
+	query := `
+		UPDATE artifact_shares
+		SET download_count = download_count + 1,
+			used_at = COALESCE(used_at, NOW())
+		WHERE id = $1
+			AND revoked_at IS NULL
+			AND expires_at > NOW()
+			AND (NOT single_use OR used_at IS NULL)
+	`
+
+	result, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareUnavailable
+	}
+	return nil
+}
+
+// Revoke disables the link; revoking twice keeps the first timestamp
+func (r *ShareRepository) Revoke(ctx context.Context, db *sql.DB, id string) error {
+	result, err := db.ExecContext(ctx,
+		`UPDATE artifact_shares SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
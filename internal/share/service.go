@@ -0,0 +1,160 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+	"task_handler/internal/utils"
+	"time"
+)
+
+type ShareServiceInterface interface {
+	// CreateShare makes a link to an artifact of a task owned by userID.
+	// A zero ttl selects the configured default.
+	CreateShare(ctx context.Context, userID, taskID int, name string, ttl time.Duration, singleUse bool) (*Share, error)
+	ListShares(ctx context.Context, userID, taskID int) ([]*Share, error)
+	RevokeShare(ctx context.Context, userID int, shareID string) error
+	// OpenShare verifies a link and opens its artifact, counting the
+	// download. The caller must close the object.
+	OpenShare(ctx context.Context, shareID string, expires int64, sig string) (*task.Artifact, *artifact.Object, error)
+	// URL returns the public download URL of a share
+	URL(share *Share) string
+}
+
+type ShareService struct {
+	repo   ShareRepositoryInterface
+	DB     *sql.DB
+	tasks  task.TaskServiceInterface
+	signer *Signer
+	cfg    config.ShareConfig
+	now    func() time.Time
+}
+
+func NewShareService(repo ShareRepositoryInterface, db *sql.DB, tasks task.TaskServiceInterface, cfg config.ShareConfig) ShareServiceInterface {
+	return &ShareService{
+		repo:   repo,
+		DB:     db,
+		tasks:  tasks,
+		signer: NewSigner(cfg.Secret),
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+func (s *ShareService) CreateShare(ctx context.Context, userID, taskID int, name string, ttl time.Duration, singleUse bool) (*Share, error) {
+	if ttl == 0 {
+		ttl = s.cfg.DefaultTTL
+	}
+	if ttl < time.Second || ttl > s.cfg.MaxTTL {
+		return nil, fmt.Errorf("%w: must be between 1s and %s", ErrInvalidTTL, s.cfg.MaxTTL)
+	}
+
+	if err := s.checkOwner(ctx, userID, taskID); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := s.tasks.GetArtifacts(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, a := range artifacts {
+		if a.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, task.ErrArtifactNotFound
+	}
+
+	share := &Share{
+		ID:           utils.RandomID(),
+		TaskID:       taskID,
+		ArtifactName: name,
+		UserID:       userID,
+		SingleUse:    singleUse,
+		// Whole seconds, so the expiry in the URL matches the stored one
+		ExpiresAt: s.now().Add(ttl).UTC().Truncate(time.Second),
+	}
+	if err := s.repo.Create(ctx, s.DB, share); err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s *ShareService) ListShares(ctx context.Context, userID, taskID int) ([]*Share, error) {
+	if err := s.checkOwner(ctx, userID, taskID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByTaskID(ctx, s.DB, taskID)
+}
+
+func (s *ShareService) RevokeShare(ctx context.Context, userID int, shareID string) error {
+	share, err := s.repo.GetByID(ctx, s.DB, shareID)
+	if err != nil {
+		return err
+	}
+	if share.UserID != userID {
+		return ErrForbidden
+	}
+	return s.repo.Revoke(ctx, s.DB, shareID)
+}
+
+func (s *ShareService) OpenShare(ctx context.Context, shareID string, expires int64, sig string) (*task.Artifact, *artifact.Object, error) {
+	if !s.signer.Verify(shareID, expires, sig) {
+		return nil, nil, ErrInvalidSignature
+	}
+	if !s.now().Before(time.Unix(expires, 0)) {
+		return nil, nil, ErrShareExpired
+	}
+
+	share, err := s.repo.GetByID(ctx, s.DB, shareID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !share.Active(s.now()) {
+		return nil, nil, ErrShareUnavailable
+	}
+
+	a, obj, err := s.tasks.OpenArtifact(ctx, share.TaskID, share.ArtifactName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Claimed only once the object is open, so a storage error does not
+	// use up a single-use link
+	if err := s.repo.Claim(ctx, s.DB, shareID); err != nil {
+		obj.Close()
+		return nil, nil, err
+	}
+
+	return a, obj, nil
+}
+
+func (s *ShareService) URL(share *Share) string {
+	expires := share.ExpiresAt.Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", s.signer.Sign(share.ID, expires))
+
+	return strings.TrimSuffix(s.cfg.BaseURL, "/") + "/shared/" + share.ID + "?" + query.Encode()
+}
+
+func (s *ShareService) checkOwner(ctx context.Context, userID, taskID int) error {
+	t, err := s.tasks.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if t.UserID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockShareRepository struct {
+	mock.Mock
+}
+
+func (m *MockShareRepository) Create(ctx context.Context, db *sql.DB, share *Share) error {
+	return m.Called(ctx, db, share).Error(0)
+}
+
+func (m *MockShareRepository) GetByID(ctx context.Context, db *sql.DB, id string) (*Share, error) {
+	args := m.Called(ctx, db, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Share), args.Error(1)
+}
+
+func (m *MockShareRepository) GetByTaskID(ctx context.Context, db *sql.DB, taskID int) ([]*Share, error) {
+	args := m.Called(ctx, db, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Share), args.Error(1)
+}
+
+func (m *MockShareRepository) Claim(ctx context.Context, db *sql.DB, id string) error {
+	return m.Called(ctx, db, id).Error(0)
+}
+
+func (m *MockShareRepository) Revoke(ctx context.Context, db *sql.DB, id string) error {
+	return m.Called(ctx, db, id).Error(0)
+}
+
+type MockTaskService struct {
+	mock.Mock
+}
+
+func (m *MockTaskService) CreateTask(ctx context.Context, t *task.Task) error {
+	return m.Called(ctx, t).Error(0)
+}
+
+func (m *MockTaskService) GetTask(ctx context.Context, id int) (*task.Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*task.Task), args.Error(1)
+}
+
+func (m *MockTaskService) GetCurrentTask(ctx context.Context, id int) (*task.Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*task.Task), args.Error(1)
+}
+
+func (m *MockTaskService) GetTasks(ctx context.Context, userID int) ([]*task.Task, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*task.Task), args.Error(1)
+}
+
+func (m *MockTaskService) GetArtifacts(ctx context.Context, taskID int) ([]*task.Artifact, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*task.Artifact), args.Error(1)
+}
+
+func (m *MockTaskService) OpenArtifact(ctx context.Context, taskID int, name string) (*task.Artifact, *artifact.Object, error) {
+	args := m.Called(ctx, taskID, name)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*task.Artifact), args.Get(1).(*artifact.Object), args.Error(2)
+}
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService() (*ShareService, *MockShareRepository, *MockTaskService) {
+	repo := new(MockShareRepository)
+	tasks := new(MockTaskService)
+	svc := NewShareService(repo, nil, tasks, config.ShareConfig{
+		Secret:     "test-secret",
+		DefaultTTL: time.Hour,
+		MaxTTL:     24 * time.Hour,
+		BaseURL:    "https://tasks.example.com/",
+	}).(*ShareService)
+	svc.now = func() time.Time { return testNow }
+	return svc, repo, tasks
+}
+
+// closeRecorder reports whether the artifact was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// signedParams extracts expires and sig from a share URL
+func signedParams(t *testing.T, raw string) (string, int64, string) {
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	expires, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	require.NoError(t, err)
+	return strings.TrimPrefix(u.Path, "/shared/"), expires, u.Query().Get("sig")
+}
+
+func TestCreateShare(t *testing.T) {
+	svc, repo, tasks := newTestService()
+	ctx := context.Background()
+
+	tasks.On("GetTask", mock.Anything, 7).Return(&task.Task{ID: 7, UserID: 1}, nil)
+	tasks.On("GetArtifacts", mock.Anything, 7).Return([]*task.Artifact{{TaskID: 7, Name: "report.csv"}}, nil)
+	repo.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	share, err := svc.CreateShare(ctx, 1, 7, "report.csv", 0, true)
+	require.NoError(t, err)
+
+	assert.Len(t, share.ID, 32)
+	assert.True(t, share.SingleUse)
+	assert.Equal(t, testNow.Add(time.Hour), share.ExpiresAt, "default TTL")
+
+	link := svc.URL(share)
+	assert.True(t, strings.HasPrefix(link, "https://tasks.example.com/shared/"+share.ID+"?"), link)
+
+	id, expires, sig := signedParams(t, strings.TrimPrefix(link, "https://tasks.example.com"))
+	assert.Equal(t, share.ID, id)
+	assert.Equal(t, share.ExpiresAt.Unix(), expires)
+	assert.True(t, svc.signer.Verify(id, expires, sig))
+}
+
+func TestCreateShare_Rejected(t *testing.T) {
+	svc, repo, tasks := newTestService()
+	ctx := context.Background()
+
+	tasks.On("GetTask", mock.Anything, 7).Return(&task.Task{ID: 7, UserID: 1}, nil)
+	tasks.On("GetArtifacts", mock.Anything, 7).Return([]*task.Artifact{{TaskID: 7, Name: "report.csv"}}, nil)
+
+	_, err := svc.CreateShare(ctx, 1, 7, "report.csv", 48*time.Hour, false)
+	assert.ErrorIs(t, err, ErrInvalidTTL)
+
+	_, err = svc.CreateShare(ctx, 2, 7, "report.csv", 0, false)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = svc.CreateShare(ctx, 1, 7, "other.csv", 0, false)
+	assert.ErrorIs(t, err, task.ErrArtifactNotFound)
+
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOpenShare(t *testing.T) {
+	share := &Share{
+		ID:           "0123456789abcdef0123456789abcdef",
+		TaskID:       7,
+		ArtifactName: "report.csv",
+		UserID:       1,
+		ExpiresAt:    testNow.Add(time.Hour),
+	}
+	expires := share.ExpiresAt.Unix()
+
+	t.Run("Success", func(t *testing.T) {
+		svc, repo, tasks := newTestService()
+		body := &closeRecorder{Reader: strings.NewReader("a,b")}
+
+		repo.On("GetByID", mock.Anything, mock.Anything, share.ID).Return(share, nil)
+		tasks.On("OpenArtifact", mock.Anything, 7, "report.csv").Return(
+			&task.Artifact{Name: "report.csv"}, &artifact.Object{ReadCloser: body, Size: 3}, nil)
+		repo.On("Claim", mock.Anything, mock.Anything, share.ID).Return(nil)
+
+		a, obj, err := svc.OpenShare(context.Background(), share.ID, expires, svc.signer.Sign(share.ID, expires))
+		require.NoError(t, err)
+		assert.Equal(t, "report.csv", a.Name)
+		assert.Equal(t, int64(3), obj.Size)
+		assert.False(t, body.closed)
+	})
+
+	t.Run("BadSignature", func(t *testing.T) {
+		svc, repo, _ := newTestService()
+
+		_, _, err := svc.OpenShare(context.Background(), share.ID, expires+3600, svc.signer.Sign(share.ID, expires))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		repo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		svc, repo, _ := newTestService()
+		past := testNow.Add(-time.Second).Unix()
+
+		_, _, err := svc.OpenShare(context.Background(), share.ID, past, svc.signer.Sign(share.ID, past))
+		assert.ErrorIs(t, err, ErrShareExpired)
+		repo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		svc, repo, tasks := newTestService()
+		revoked := *share
+		revokedAt := testNow.Add(-time.Minute)
+		revoked.RevokedAt = &revokedAt
+
+		repo.On("GetByID", mock.Anything, mock.Anything, share.ID).Return(&revoked, nil)
+
+		_, _, err := svc.OpenShare(context.Background(), share.ID, expires, svc.signer.Sign(share.ID, expires))
+		assert.ErrorIs(t, err, ErrShareUnavailable)
+		tasks.AssertNotCalled(t, "OpenArtifact", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ClaimLostClosesObject", func(t *testing.T) {
+		svc, repo, tasks := newTestService()
+		body := &closeRecorder{Reader: strings.NewReader("a,b")}
+
+		repo.On("GetByID", mock.Anything, mock.Anything, share.ID).Return(share, nil)
+		tasks.On("OpenArtifact", mock.Anything, 7, "report.csv").Return(
+			&task.Artifact{Name: "report.csv"}, &artifact.Object{ReadCloser: body, Size: 3}, nil)
+		repo.On("Claim", mock.Anything, mock.Anything, share.ID).Return(ErrShareUnavailable)
+
+		_, _, err := svc.OpenShare(context.Background(), share.ID, expires, svc.signer.Sign(share.ID, expires))
+		assert.ErrorIs(t, err, ErrShareUnavailable)
+		assert.True(t, body.closed)
+	})
+}
+
+func TestRevokeShare_OnlyOwner(t *testing.T) {
+	svc, repo, _ := newTestService()
+
+	repo.On("GetByID", mock.Anything, mock.Anything, "s1").Return(&Share{ID: "s1", UserID: 1}, nil)
+	repo.On("Revoke", mock.Anything, mock.Anything, "s1").Return(nil)
+
+	assert.ErrorIs(t, svc.RevokeShare(context.Background(), 2, "s1"), ErrForbidden)
+	repo.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.NoError(t, svc.RevokeShare(context.Background(), 1, "s1"))
+}
+
+func TestShareActive(t *testing.T) {
+	used := testNow.Add(-time.Minute)
+
+	assert.True(t, (&Share{ExpiresAt: testNow.Add(time.Second)}).Active(testNow))
+	assert.False(t, (&Share{ExpiresAt: testNow}).Active(testNow))
+	assert.True(t, (&Share{ExpiresAt: testNow.Add(time.Hour), UsedAt: &used}).Active(testNow), "multi-use")
+	assert.False(t, (&Share{ExpiresAt: testNow.Add(time.Hour), UsedAt: &used, SingleUse: true}).Active(testNow))
+}
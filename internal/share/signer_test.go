@@ -0,0 +1,19 @@
+package share
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner_SignVerify(t *testing.T) {
+	signer := NewSigner("secret")
+	sig := signer.Sign("abc", 1700000000)
+
+	assert.True(t, signer.Verify("abc", 1700000000, sig))
+	assert.False(t, signer.Verify("abc", 1700000001, sig), "altered expiry")
+	assert.False(t, signer.Verify("abd", 1700000000, sig), "altered id")
+	assert.False(t, NewSigner("other").Verify("abc", 1700000000, sig), "other key")
+	assert.False(t, signer.Verify("abc", 1700000000, "not-hex"))
+	assert.False(t, signer.Verify("abc", 1700000000, ""))
+}
@@ -1,9 +1,18 @@
 package task
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"task_handler/internal/artifact"
 	"task_handler/internal/auth"
+	"task_handler/internal/queue"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,7 +30,8 @@ func NewTaskController(service TaskServiceInterface) *TaskController {
 // CreateTask handles task creation
 func (tc *TaskController) CreateTask(c *gin.Context) {
 	var req struct {
-		TaskType string `json:"task_type" binding:"required"`
+		TaskType string          `json:"task_type" binding:"required"`
+		Params   json.RawMessage `json:"params"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -29,6 +39,16 @@ func (tc *TaskController) CreateTask(c *gin.Context) {
 		return
 	}
 
+	// Handlers read params as named fields, so only objects are accepted
+	params := bytes.TrimSpace(req.Params)
+	if bytes.Equal(params, []byte("null")) {
+		params = nil
+	}
+	if len(params) > 0 && params[0] != '{' {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "params must be a JSON object"})
+		return
+	}
+
 	// Extract userID from JWT context
 	userID, err := auth.GetUserIDFromContext(c)
 	if err != nil {
@@ -39,10 +59,23 @@ func (tc *TaskController) CreateTask(c *gin.Context) {
 	task := &Task{
 		UserID:   userID,
 		TaskType: req.TaskType,
-		Status:   "PENDING",
+		Status:   StatusPending,
+		Params:   params,
 	}
 
-	if err := tc.service.CreateTask(task); err != nil {
+	if err := tc.service.CreateTask(c.Request.Context(), task); err != nil {
+		if errors.Is(err, ErrInvalidUploadRef) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+			return
+		}
+		if errors.Is(err, queue.ErrQueueFull) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many tasks are waiting, try again later"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -56,40 +89,157 @@ func (tc *TaskController) CreateTask(c *gin.Context) {
 
 // GetTask handles getting task by ID
 func (tc *TaskController) GetTask(c *gin.Context) {
+	task, ok := tc.ownedTask(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":            task.ID,
+		"user_id":       task.UserID,
+		"task_type":     task.TaskType,
+		"status":        task.Status,
+		"params":        task.Params,
+		"result":        task.Result,
+		"result_file":   task.ResultFile,
+		"error_message": task.ErrorMessage,
+		"progress":      task.Progress,
+		"created_at":    task.CreatedAt,
+		"updated_at":    task.UpdatedAt,
+	})
+}
+
+// GetTaskResult returns the inline result of a successful task together
+// with the list of its artifacts
+func (tc *TaskController) GetTaskResult(c *gin.Context) {
+	// A result is asked for right after the task finished, before a stale
+	// cached status would have expired
+	task, ok := tc.ownedTaskFrom(c, tc.service.GetCurrentTask)
+	if !ok {
+		return
+	}
+
+	if task.Status != StatusSuccess {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Task has no result",
+			"status":        task.Status,
+			"error_message": task.ErrorMessage,
+		})
+		return
+	}
+
+	if task.ResultExpiredAt != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error":      "Task result has expired",
+			"expired_at": task.ResultExpiredAt,
+		})
+		return
+	}
+
+	artifacts, err := tc.service.GetArtifacts(c.Request.Context(), task.ID)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task artifacts"})
+		return
+	}
+
+	list := make([]gin.H, 0, len(artifacts))
+	for _, a := range artifacts {
+		list = append(list, gin.H{
+			"name":         a.Name,
+			"content_type": a.ContentType,
+			"size":         a.Size,
+			"created_at":   a.CreatedAt,
+			"url":          fmt.Sprintf("/api/v1/tasks/%d/artifacts/%s", task.ID, url.PathEscape(a.Name)),
+		})
+	}
+
+	result := task.Result
+	if len(result) == 0 {
+		result = json.RawMessage("null")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":   task.ID,
+		"status":    task.Status,
+		"result":    result,
+		"artifacts": list,
+	})
+}
+
+// DownloadArtifact streams one artifact of the task
+func (tc *TaskController) DownloadArtifact(c *gin.Context) {
+	name := c.Param("name")
+	if !artifact.ValidName(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+
+	task, ok := tc.ownedTask(c)
+	if !ok {
+		return
+	}
+
+	a, obj, err := tc.service.OpenArtifact(c.Request.Context(), task.ID, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrArtifactNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		case errors.Is(err, context.DeadlineExceeded):
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open artifact"})
+		}
+		return
+	}
+	defer obj.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, a.ContentType, obj, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+	})
+}
+
+// ownedTask loads the task named by the :id parameter and checks that it
+// belongs to the authenticated user. On failure the response has been
+// written and ok is false.
+func (tc *TaskController) ownedTask(c *gin.Context) (*Task, bool) {
+	return tc.ownedTaskFrom(c, tc.service.GetTask)
+}
+
+// ownedTaskFrom is ownedTask reading the task with get
+func (tc *TaskController) ownedTaskFrom(c *gin.Context, get func(ctx context.Context, taskID int) (*Task, error)) (*Task, bool) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
+		return nil, false
 	}
 
-	task, err := tc.service.GetTask(id)
+	task, err := get(c.Request.Context(), id)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+			return nil, false
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		return
+		return nil, false
 	}
 
 	// Authorization: Check task ownership
 	authenticatedUserID, err := auth.GetUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
-		return
+		return nil, false
 	}
 
 	if task.UserID != authenticatedUserID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own tasks"})
-		return
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":            task.ID,
-		"user_id":       task.UserID,
-		"task_type":     task.TaskType,
-		"status":        task.Status,
-		"result_file":   task.ResultFile,
-		"error_message": task.ErrorMessage,
-		"created_at":    task.CreatedAt,
-		"updated_at":    task.UpdatedAt,
-	})
+	return task, true
 }
 
 // GetTasksByUser handles getting all tasks for a user
@@ -102,8 +252,12 @@ func (tc *TaskController) GetTasksByUser(c *gin.Context) {
 	}
 
 	// Get tasks for authenticated user only
-	tasks, err := tc.service.GetTasks(userID)
+	tasks, err := tc.service.GetTasks(c.Request.Context(), userID)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tasks"})
 		return
 	}
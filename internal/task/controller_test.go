@@ -1,15 +1,19 @@
 package task
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"task_handler/internal/artifact"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,27 +25,51 @@ type MockTaskService struct {
 	mock.Mock
 }
 
-func (m *MockTaskService) CreateTask(task *Task) error {
-	args := m.Called(task)
+func (m *MockTaskService) CreateTask(ctx context.Context, task *Task) error {
+	args := m.Called(ctx, task)
 	return args.Error(0)
 }
 
-func (m *MockTaskService) GetTask(id int) (*Task, error) {
-	args := m.Called(id)
+func (m *MockTaskService) GetTask(ctx context.Context, id int) (*Task, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Task), args.Error(1)
+}
+
+func (m *MockTaskService) GetCurrentTask(ctx context.Context, id int) (*Task, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*Task), args.Error(1)
 }
 
-func (m *MockTaskService) GetTasks(userID int) ([]*Task, error) {
-	args := m.Called(userID)
+func (m *MockTaskService) GetTasks(ctx context.Context, userID int) ([]*Task, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*Task), args.Error(1)
 }
 
+func (m *MockTaskService) GetArtifacts(ctx context.Context, taskID int) ([]*Artifact, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Artifact), args.Error(1)
+}
+
+func (m *MockTaskService) OpenArtifact(ctx context.Context, taskID int, name string) (*Artifact, *artifact.Object, error) {
+	args := m.Called(ctx, taskID, name)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*Artifact), args.Get(1).(*artifact.Object), args.Error(2)
+}
+
 // setupTestRouter creates a test router with mocked service
 func setupTestRouter(service TaskServiceInterface) (*gin.Engine, *TaskController) {
 	gin.SetMode(gin.TestMode)
@@ -73,7 +101,7 @@ func TestGetTask_Success_OwnTask(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	mockService.On("GetTask", taskID).Return(expectedTask, nil)
+	mockService.On("GetTask", mock.Anything, taskID).Return(expectedTask, nil)
 
 	// Setup route with auth middleware mock
 	router.GET("/tasks/:id", func(c *gin.Context) {
@@ -116,7 +144,7 @@ func TestGetTask_Forbidden_OtherUserTask(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	mockService.On("GetTask", taskID).Return(expectedTask, nil)
+	mockService.On("GetTask", mock.Anything, taskID).Return(expectedTask, nil)
 
 	// Setup route
 	router.GET("/tasks/:id", func(c *gin.Context) {
@@ -146,7 +174,7 @@ func TestGetTask_NotFound(t *testing.T) {
 	authenticatedUserID := 1
 	taskID := 999
 
-	mockService.On("GetTask", taskID).Return(nil, errors.New("task not found"))
+	mockService.On("GetTask", mock.Anything, taskID).Return(nil, errors.New("task not found"))
 
 	router.GET("/tasks/:id", func(c *gin.Context) {
 		addAuthenticatedUser(c, authenticatedUserID)
@@ -168,6 +196,54 @@ func TestGetTask_NotFound(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestGetTask_Timeout(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	taskID := 42
+
+	mockService.On("GetTask", mock.Anything, taskID).Return(nil, fmt.Errorf("query task: %w", context.DeadlineExceeded))
+
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTask(c)
+	})
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/tasks/%d", taskID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// A slow database must not be reported as a missing task
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetTask_PassesRequestContext(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	type ctxKey struct{}
+	task := &Task{ID: 7, UserID: 1}
+
+	mockService.On("GetTask", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(ctxKey{}) == "marker"
+	}), 7).Return(task, nil)
+
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTask(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "marker"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
 func TestGetTask_InvalidTaskID(t *testing.T) {
 	mockService := new(MockTaskService)
 	router, controller := setupTestRouter(mockService)
@@ -208,7 +284,7 @@ func TestGetTask_Unauthorized_NoUserInContext(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	mockService.On("GetTask", taskID).Return(expectedTask, nil)
+	mockService.On("GetTask", mock.Anything, taskID).Return(expectedTask, nil)
 
 	// Don't add user to context (simulating missing JWT middleware)
 	router.GET("/tasks/:id", controller.GetTask)
@@ -251,7 +327,7 @@ func TestGetTasksByUser_Success_OwnTasks(t *testing.T) {
 		},
 	}
 
-	mockService.On("GetTasks", authenticatedUserID).Return(expectedTasks, nil)
+	mockService.On("GetTasks", mock.Anything, authenticatedUserID).Return(expectedTasks, nil)
 
 	// Route without :user_id parameter - uses JWT context only
 	router.GET("/users/tasks", func(c *gin.Context) {
@@ -284,7 +360,7 @@ func TestGetTasksByUser_EmptyList(t *testing.T) {
 	authenticatedUserID := 1
 
 	// Return empty list
-	mockService.On("GetTasks", authenticatedUserID).Return([]*Task{}, nil)
+	mockService.On("GetTasks", mock.Anything, authenticatedUserID).Return([]*Task{}, nil)
 
 	router.GET("/users/tasks", func(c *gin.Context) {
 		addAuthenticatedUser(c, authenticatedUserID)
@@ -314,8 +390,8 @@ func TestCreateTask_Success(t *testing.T) {
 
 	authenticatedUserID := 1
 
-	mockService.On("CreateTask", mock.AnythingOfType("*task.Task")).Return(nil).Run(func(args mock.Arguments) {
-		task := args.Get(0).(*Task)
+	mockService.On("CreateTask", mock.Anything, mock.AnythingOfType("*task.Task")).Return(nil).Run(func(args mock.Arguments) {
+		task := args.Get(1).(*Task)
 		task.ID = 123 // Simulate DB assigning ID
 	})
 
@@ -370,7 +446,7 @@ func TestCreateTask_ServiceError(t *testing.T) {
 
 	authenticatedUserID := 1
 
-	mockService.On("CreateTask", mock.AnythingOfType("*task.Task")).Return(errors.New("database error"))
+	mockService.On("CreateTask", mock.Anything, mock.AnythingOfType("*task.Task")).Return(errors.New("database error"))
 
 	router.POST("/tasks", func(c *gin.Context) {
 		addAuthenticatedUser(c, authenticatedUserID)
@@ -387,3 +463,251 @@ func TestCreateTask_ServiceError(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+func TestCreateTask_InvalidUploadRef(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("CreateTask", mock.Anything, mock.AnythingOfType("*task.Task")).
+		Return(fmt.Errorf("%w: upload not found", ErrInvalidUploadRef))
+
+	router.POST("/tasks", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.CreateTask(c)
+	})
+
+	reqBody := `{"task_type": "resize_image", "params": {"upload_id": "missing"}}`
+	req := httptest.NewRequest("POST", "/tasks", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid upload reference")
+}
+
+func TestCreateTask_WithParams(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("CreateTask", mock.Anything, mock.MatchedBy(func(task *Task) bool {
+		return string(task.Params) == `{"to": "user@example.com"}`
+	})).Return(nil)
+
+	router.POST("/tasks", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.CreateTask(c)
+	})
+
+	reqBody := `{"task_type": "send_email", "params": {"to": "user@example.com"}}`
+	req := httptest.NewRequest("POST", "/tasks", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateTask_ParamsNotObject(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	router.POST("/tasks", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.CreateTask(c)
+	})
+
+	reqBody := `{"task_type": "send_email", "params": [1, 2, 3]}`
+	req := httptest.NewRequest("POST", "/tasks", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertNotCalled(t, "CreateTask")
+}
+
+func TestGetTaskResult_Success(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("GetCurrentTask", mock.Anything, 7).Return(&Task{
+		ID:     7,
+		UserID: 1,
+		Status: StatusSuccess,
+		Result: json.RawMessage(`{"rows":3}`),
+	}, nil)
+	mockService.On("GetArtifacts", mock.Anything, 7).Return([]*Artifact{
+		{TaskID: 7, Name: "report.csv", ContentType: "text/csv", Size: 42},
+	}, nil)
+
+	router.GET("/tasks/:id/result", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTaskResult(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/result", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Result    map[string]interface{}   `json:"result"`
+		Artifacts []map[string]interface{} `json:"artifacts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, float64(3), response.Result["rows"])
+	require.Len(t, response.Artifacts, 1)
+	assert.Equal(t, "report.csv", response.Artifacts[0]["name"])
+	assert.Equal(t, "/api/v1/tasks/7/artifacts/report.csv", response.Artifacts[0]["url"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetTaskResult_NotFinished(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("GetCurrentTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 1, Status: StatusProcessing}, nil)
+
+	router.GET("/tasks/:id/result", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTaskResult(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/result", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"PROCESSING"`)
+	mockService.AssertNotCalled(t, "GetArtifacts", mock.Anything, mock.Anything)
+}
+
+func TestGetTaskResult_Expired(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	expired := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("GetCurrentTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 1, Status: StatusSuccess, ResultExpiredAt: &expired}, nil)
+
+	router.GET("/tasks/:id/result", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTaskResult(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/result", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), `"expired_at":"2025-03-01T00:00:00Z"`)
+	mockService.AssertNotCalled(t, "GetArtifacts", mock.Anything, mock.Anything)
+}
+
+func TestGetTaskResult_Forbidden_OtherUserTask(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("GetCurrentTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 2, Status: StatusSuccess}, nil)
+
+	router.GET("/tasks/:id/result", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.GetTaskResult(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/result", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertNotCalled(t, "GetArtifacts", mock.Anything, mock.Anything)
+}
+
+func TestDownloadArtifact_Success(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	body := "a,b\n1,2\n"
+	mockService.On("GetTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 1, Status: StatusSuccess}, nil)
+	mockService.On("OpenArtifact", mock.Anything, 7, "report.csv").Return(
+		&Artifact{TaskID: 7, Name: "report.csv", ContentType: "text/csv", Size: int64(len(body))},
+		&artifact.Object{ReadCloser: io.NopCloser(strings.NewReader(body)), Size: int64(len(body))},
+		nil,
+	)
+
+	router.GET("/tasks/:id/artifacts/:name", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.DownloadArtifact(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/artifacts/report.csv", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=report.csv`, w.Header().Get("Content-Disposition"))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDownloadArtifact_NotFound(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("GetTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 1, Status: StatusSuccess}, nil)
+	mockService.On("OpenArtifact", mock.Anything, 7, "missing.txt").Return(nil, nil, ErrArtifactNotFound)
+
+	router.GET("/tasks/:id/artifacts/:name", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.DownloadArtifact(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/artifacts/missing.txt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDownloadArtifact_Forbidden_OtherUserTask(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	mockService.On("GetTask", mock.Anything, 7).Return(&Task{ID: 7, UserID: 2, Status: StatusSuccess}, nil)
+
+	router.GET("/tasks/:id/artifacts/:name", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.DownloadArtifact(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/artifacts/report.csv", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockService.AssertNotCalled(t, "OpenArtifact", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDownloadArtifact_InvalidName(t *testing.T) {
+	mockService := new(MockTaskService)
+	router, controller := setupTestRouter(mockService)
+
+	router.GET("/tasks/:id/artifacts/:name", func(c *gin.Context) {
+		addAuthenticatedUser(c, 1)
+		controller.DownloadArtifact(c)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7/artifacts/.env", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertNotCalled(t, "GetTask", mock.Anything, mock.Anything)
+}
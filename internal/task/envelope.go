@@ -0,0 +1,81 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"task_handler/internal/utils"
+	"time"
+)
+
+// EnvelopeVersion is the schema version written by this build. Workers
+// keep decoding every older version so API and worker can be rolled out
+// independently.
+const EnvelopeVersion = 1
+
+var ErrUnsupportedEnvelopeVersion = errors.New("unsupported envelope version")
+
+// Envelope is the message published to the queue for every task
+type Envelope struct {
+	Version      int               `json:"version"`
+	MessageID    string            `json:"message_id"`
+	TaskID       int               `json:"task_id"`
+	UserID       int               `json:"user_id"`
+	TaskType     string            `json:"task_type"`
+	Params       json.RawMessage   `json:"params,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	Attempt      int               `json:"attempt"`
+}
+
+// NewEnvelope builds the first-attempt envelope for a persisted task
+func NewEnvelope(task *Task) *Envelope {
+	return &Envelope{
+		Version:   EnvelopeVersion,
+		MessageID: utils.RandomUUID(),
+		TaskID:    task.ID,
+		UserID:    task.UserID,
+		TaskType:  task.TaskType,
+		Params:    task.Params,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// DecodeEnvelope parses a queue message body of any supported version.
+// Bodies without a version field are the legacy TaskPayload format.
+func DecodeEnvelope(body []byte) (*Envelope, error) {
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+
+	if probe.Version == nil {
+		var legacy TaskPayload
+		if err := json.Unmarshal(body, &legacy); err != nil {
+			return nil, fmt.Errorf("invalid legacy payload: %w", err)
+		}
+		return &Envelope{
+			Version:  0,
+			TaskID:   legacy.ID,
+			UserID:   legacy.UserID,
+			TaskType: legacy.TaskType,
+		}, nil
+	}
+
+	switch *probe.Version {
+	case 1:
+		var env Envelope
+		if err := json.Unmarshal(body, &env); err != nil {
+			return nil, fmt.Errorf("invalid envelope: %w", err)
+		}
+		return &env, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEnvelopeVersion, *probe.Version)
+	}
+}
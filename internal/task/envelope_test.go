@@ -0,0 +1,73 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	task := &Task{
+		ID:       42,
+		UserID:   7,
+		TaskType: "send_email",
+		Params:   json.RawMessage(`{"to":"user@example.com"}`),
+	}
+
+	envelope := NewEnvelope(task)
+	assert.Equal(t, EnvelopeVersion, envelope.Version)
+	assert.Len(t, envelope.MessageID, 36)
+
+	body, err := envelope.Marshal()
+	require.NoError(t, err)
+
+	decoded, err := DecodeEnvelope(body)
+	require.NoError(t, err)
+
+	assert.Equal(t, envelope.MessageID, decoded.MessageID)
+	assert.Equal(t, 42, decoded.TaskID)
+	assert.Equal(t, 7, decoded.UserID)
+	assert.Equal(t, "send_email", decoded.TaskType)
+	assert.JSONEq(t, `{"to":"user@example.com"}`, string(decoded.Params))
+	assert.True(t, envelope.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, 0, decoded.Attempt)
+}
+
+// TestDecodeEnvelope_LegacyPayload covers messages published by API
+// instances that predate envelopes (rolling deploys)
+func TestDecodeEnvelope_LegacyPayload(t *testing.T) {
+	body := []byte(`{
+			"id": 15,
+			"user_id": 3,
+			"task_type": "resize_image"
+		}`)
+
+	decoded, err := DecodeEnvelope(body)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, decoded.Version)
+	assert.Equal(t, 15, decoded.TaskID)
+	assert.Equal(t, 3, decoded.UserID)
+	assert.Equal(t, "resize_image", decoded.TaskType)
+	assert.Empty(t, decoded.MessageID)
+}
+
+func TestDecodeEnvelope_UnsupportedVersion(t *testing.T) {
+	_, err := DecodeEnvelope([]byte(`{"version": 99, "task_id": 1}`))
+	assert.ErrorIs(t, err, ErrUnsupportedEnvelopeVersion)
+}
+
+func TestDecodeEnvelope_InvalidJSON(t *testing.T) {
+	_, err := DecodeEnvelope([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestNewEnvelope_UniqueMessageIDs(t *testing.T) {
+	task := &Task{ID: 1, UserID: 1, TaskType: "cleanup_temp"}
+
+	first, second := NewEnvelope(task), NewEnvelope(task)
+
+	assert.NotEqual(t, first.MessageID, second.MessageID)
+}
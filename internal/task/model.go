@@ -1,18 +1,40 @@
 package task
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Task struct {
 	ID           int
 	UserID       int
 	TaskType     string
-	Status       string
-	ResultFile   *string
+	Status       Status
+	Params       json.RawMessage
+	Result       json.RawMessage // Inline JSON result, nil when none or stored as an artifact
+	ResultFile   *string         // Name of the task's main artifact, if any
 	ErrorMessage *string
+	Progress     *int // Percent done while PROCESSING, nil when not reported
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+
+	ResultExpiredAt *time.Time // When retention removed the result, nil while it is kept
+}
+
+// Artifact is an output file of a task, kept in the artifact store under
+// StorageKey
+type Artifact struct {
+	ID          int64
+	TaskID      int
+	Name        string
+	StorageKey  string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
 }
 
+// TaskPayload is the legacy (version 0) queue message, still decoded by
+// DecodeEnvelope for messages published before envelopes existed
 type TaskPayload struct {
 	ID       int    `json:"id"`
 	UserID   int    `json:"user_id"`
@@ -21,7 +43,7 @@ type TaskPayload struct {
 
 type TaskResponse struct {
 	ID         int
-	Status     string
+	Status     Status
 	ResultFile *string
 	Error      *string
 }
@@ -0,0 +1,188 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxFailureReasons bounds the failure reasons listed in a report summary
+const maxFailureReasons = 10
+
+// ReportQuery selects the tasks of one user created in [From, To)
+type ReportQuery struct {
+	UserID    int
+	From      time.Time
+	To        time.Time
+	ExcludeID int // The report task itself
+}
+
+// ReportSummary aggregates the tasks a report covers. Durations are the
+// time from creation to the final status, over finished tasks only.
+type ReportSummary struct {
+	Total              int                `json:"total"`
+	ByType             map[string]int     `json:"by_type"`
+	ByStatus           map[Status]int     `json:"by_status"`
+	AvgDurationSeconds map[string]float64 `json:"avg_duration_seconds_by_type"`
+	FailureReasons     []FailureReason    `json:"failure_reasons"`
+}
+
+// FailureReason counts failed tasks sharing one error message
+type FailureReason struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// Duration is how long a finished task took, zero while it is not finished
+func (t *Task) Duration() time.Duration {
+	if t.Status != StatusSuccess && t.Status != StatusFailed {
+		return 0
+	}
+	return t.UpdatedAt.Sub(t.CreatedAt)
+}
+
+const reportWhere = `
+	WHERE user_id = $1 AND created_at >= $2 AND created_at < $3 AND id <> $4
+`
+
+func (q ReportQuery) args() []any {
+	return []any{q.UserID, q.From.UTC(), q.To.UTC(), q.ExcludeID}
+}
+
+func (r *TaskRepository) GetReportSummary(
+	ctx context.Context,
+	db *sql.DB,
+	q ReportQuery,
+) (*ReportSummary, error) {
+	query := `
+		SELECT task_type, status, COUNT(*),
+			COALESCE(SUM(EXTRACT(EPOCH FROM updated_at - created_at))
+				FILTER (WHERE status IN ('SUCCESS', 'FAILED')), 0)
+		FROM tasks
+	` + reportWhere + `
+		GROUP BY task_type, status
+	`
+
+	rows, err := db.QueryContext(ctx, query, q.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	summary := &ReportSummary{
+		ByType:             make(map[string]int),
+		ByStatus:           make(map[Status]int),
+		AvgDurationSeconds: make(map[string]float64),
+		FailureReasons:     []FailureReason{},
+	}
+	finished := make(map[string]int)
+	for rows.Next() {
+		var taskType string
+		var status Status
+		var count int
+		var seconds float64
+		if err := rows.Scan(&taskType, &status, &count, &seconds); err != nil {
+			return nil, err
+		}
+
+		summary.Total += count
+		summary.ByType[taskType] += count
+		summary.ByStatus[status] += count
+		if status == StatusSuccess || status == StatusFailed {
+			finished[taskType] += count
+			summary.AvgDurationSeconds[taskType] += seconds
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for taskType, total := range summary.AvgDurationSeconds {
+		summary.AvgDurationSeconds[taskType] = total / float64(finished[taskType])
+	}
+
+	reasons, err := r.failureReasons(ctx, db, q)
+	if err != nil {
+		return nil, err
+	}
+	summary.FailureReasons = reasons
+
+	return summary, nil
+}
+
+// failureReasons lists the most common error messages of failed tasks
+func (r *TaskRepository) failureReasons(ctx context.Context, db *sql.DB, q ReportQuery) ([]FailureReason, error) {
+	query := `
+		SELECT COALESCE(error_message, ''), COUNT(*)
+		FROM tasks
+	` + reportWhere + ` AND status = 'FAILED'
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+		LIMIT $5
+	`
+
+	rows, err := db.QueryContext(ctx, query, append(q.args(), maxFailureReasons)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	reasons := []FailureReason{}
+	for rows.Next() {
+		var reason FailureReason
+		if err := rows.Scan(&reason.Message, &reason.Count); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+
+	return reasons, rows.Err()
+}
+
+// EachReportTask calls fn for every task the query covers, oldest first,
+// without loading them all at once. An error from fn stops the iteration.
+func (r *TaskRepository) EachReportTask(
+	ctx context.Context,
+	db *sql.DB,
+	q ReportQuery,
+	fn func(*Task) error,
+) error {
+	query := `
+		SELECT id, user_id, task_type, status, error_message, created_at, updated_at
+		FROM tasks
+	` + reportWhere + `
+		ORDER BY created_at, id
+	`
+
+	rows, err := db.QueryContext(ctx, query, q.args()...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.UserID, &t.TaskType, &t.Status, &t.ErrorMessage, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
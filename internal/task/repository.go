@@ -1,8 +1,11 @@
 package task
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 )
@@ -10,12 +13,21 @@ import (
 type TaskRepository struct{}
 
 type TaskRepositoryInterface interface {
-	Create(tx *sql.Tx, task *Task) (int, error)
-	GetByID(db *sql.DB, id int) (*Task, error)
-	GetByUserID(db *sql.DB, userID int) ([]*Task, error)
-	MarkProcessing(tx *sql.Tx, id int) error
-	MarkSuccess(tx *sql.Tx, id int, resultFile string) error
-	MarkFailed(tx *sql.Tx, id int, errorMessage string) error
+	Create(ctx context.Context, tx *sql.Tx, task *Task) (int, error)
+	GetByID(ctx context.Context, db *sql.DB, id int) (*Task, error)
+	GetByUserID(ctx context.Context, db *sql.DB, userID int) ([]*Task, error)
+	MarkPending(ctx context.Context, tx *sql.Tx, id int) error
+	MarkProcessing(ctx context.Context, tx *sql.Tx, id int) error
+	MarkSuccess(ctx context.Context, tx *sql.Tx, id int, result json.RawMessage, resultFile *string) error
+	MarkFailed(ctx context.Context, tx *sql.Tx, id int, errorMessage string) error
+	AddArtifact(ctx context.Context, tx *sql.Tx, artifact *Artifact) error
+	GetArtifacts(ctx context.Context, db *sql.DB, taskID int) ([]*Artifact, error)
+	GetArtifact(ctx context.Context, db *sql.DB, taskID int, name string) (*Artifact, error)
+	AttachUploads(ctx context.Context, tx *sql.Tx, taskID, userID int, uploadIDs []string) error
+	SetProgress(ctx context.Context, db *sql.DB, id int, percent int) error
+	DeletePending(ctx context.Context, db *sql.DB, id int) error
+	GetReportSummary(ctx context.Context, db *sql.DB, q ReportQuery) (*ReportSummary, error)
+	EachReportTask(ctx context.Context, db *sql.DB, q ReportQuery, fn func(*Task) error) error
 }
 
 func NewTaskRepository() TaskRepositoryInterface {
@@ -23,23 +35,32 @@ func NewTaskRepository() TaskRepositoryInterface {
 }
 
 func (r *TaskRepository) Create(
+	ctx context.Context,
 	tx *sql.Tx,
 	task *Task,
 ) (int, error) {
 	query := `
 		INSERT INTO tasks (
-			user_id, task_type, status, created_at, updated_at
+			user_id, task_type, status, params, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, NOW(), NOW())
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
 		RETURNING id
 	`
 
+	// A nil params must reach the database as NULL, not as an empty string
+	var params any
+	if len(task.Params) > 0 {
+		params = string(task.Params)
+	}
+
 	var id int
-	err := tx.QueryRow(
+	err := tx.QueryRowContext(
+		ctx,
 		query,
 		task.UserID,
 		task.TaskType,
 		task.Status,
+		params,
 	).Scan(&id)
 
 	if err != nil {
@@ -50,55 +71,64 @@ func (r *TaskRepository) Create(
 }
 
 func (r *TaskRepository) GetByID(
+	ctx context.Context,
 	db *sql.DB,
 	id int,
 ) (*Task, error) {
 	query := `
 		SELECT
-			id, user_id, task_type, status,
-			result_file, error_message,
-			created_at, updated_at
+			id, user_id, task_type, status, params, result,
+			result_file, error_message, progress,
+			created_at, updated_at, result_expired_at
 		FROM tasks
 		WHERE id = $1
 	`
 
-	row := db.QueryRow(query, id)
+	row := db.QueryRowContext(ctx, query, id)
 
 	var t Task
+	var params, result []byte
 	err := row.Scan(
 		&t.ID,
 		&t.UserID,
 		&t.TaskType,
 		&t.Status,
+		&params,
+		&result,
 		&t.ResultFile,
 		&t.ErrorMessage,
+		&t.Progress,
 		&t.CreatedAt,
 		&t.UpdatedAt,
+		&t.ResultExpiredAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, err
 	}
+	t.Params = params
+	t.Result = result
 
 	return &t, nil
 }
 
 func (r *TaskRepository) GetByUserID(
+	ctx context.Context,
 	db *sql.DB,
 	userID int,
 ) ([]*Task, error) {
 	query := `
 		SELECT
-			id, user_id, task_type, status,
-			result_file, error_message,
-			created_at, updated_at
+			id, user_id, task_type, status, params, result,
+			result_file, error_message, progress,
+			created_at, updated_at, result_expired_at
 		FROM tasks
 		WHERE user_id = $1
 	`
 
-	rows, err := db.Query(query, userID)
+	rows, err := db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -111,20 +141,27 @@ func (r *TaskRepository) GetByUserID(
 
 	for rows.Next() {
 		var t Task
+		var params, result []byte
 		err := rows.Scan(
 			&t.ID,
 			&t.UserID,
 			&t.TaskType,
 			&t.Status,
+			&params,
+			&result,
 			&t.ResultFile,
 			&t.ErrorMessage,
+			&t.Progress,
 			&t.CreatedAt,
 			&t.UpdatedAt,
+			&t.ResultExpiredAt,
 		)
 		if err != nil {
 			logrus.Error("Error scanning task row: ", err)
 			continue
 		}
+		t.Params = params
+		t.Result = result
 		tasks = append(tasks, &t)
 	}
 
@@ -135,48 +172,251 @@ func (r *TaskRepository) GetByUserID(
 	return tasks, nil
 }
 
+// MarkPending puts a task back in the queue state, used when a worker
+// hands an unfinished task back to the broker during shutdown
+func (r *TaskRepository) MarkPending(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int,
+) error {
+	return r.transition(ctx, tx, id, StatusPending, "progress = NULL")
+}
+
 func (r *TaskRepository) MarkProcessing(
+	ctx context.Context,
 	tx *sql.Tx,
 	id int,
 ) error {
-	logrus.Info("Marking task as PROCESSING: ", id)
-	query := `
-		UPDATE tasks
-		SET status = 'PROCESSING', updated_at = NOW()
-		WHERE id = $1
-	`
-	_, err := tx.Exec(query, id)
-	return err
+	// Every attempt starts over
+	return r.transition(ctx, tx, id, StatusProcessing, "progress = NULL")
 }
 
 func (r *TaskRepository) MarkSuccess(
+	ctx context.Context,
+	tx *sql.Tx,
+	id int,
+	result json.RawMessage,
+	resultFile *string,
+) error {
+	// A nil result must reach the database as NULL, like params in Create
+	var inline any
+	if len(result) > 0 {
+		inline = string(result)
+	}
+	return r.transition(ctx, tx, id, StatusSuccess,
+		"result = $4, result_file = $5, progress = CASE WHEN progress IS NOT NULL THEN 100 END",
+		inline, resultFile)
+}
+
+func (r *TaskRepository) MarkFailed(
+	ctx context.Context,
 	tx *sql.Tx,
 	id int,
-	resultFile string,
+	errorMessage string,
+) error {
+	return r.transition(ctx, tx, id, StatusFailed, "error_message = $4", errorMessage)
+}
+
+// AddArtifact records a stored output file. Recording the same name again,
+// as a retried task does, replaces the earlier entry.
+func (r *TaskRepository) AddArtifact(
+	ctx context.Context,
+	tx *sql.Tx,
+	artifact *Artifact,
 ) error {
 	query := `
-		UPDATE tasks
-		SET status = 'SUCCESS',
-		    result_file = $1,
-		    updated_at = NOW()
-		WHERE id = $2
+		INSERT INTO task_artifacts (
+			task_id, name, storage_key, content_type, size, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (task_id, name) DO UPDATE
+		SET storage_key = EXCLUDED.storage_key,
+			content_type = EXCLUDED.content_type,
+			size = EXCLUDED.size,
+			created_at = EXCLUDED.created_at
+		RETURNING id, created_at
+	`
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		artifact.TaskID,
+		artifact.Name,
+		artifact.StorageKey,
+		artifact.ContentType,
+		artifact.Size,
+	).Scan(&artifact.ID, &artifact.CreatedAt)
+}
+
+func (r *TaskRepository) GetArtifacts(
+	ctx context.Context,
+	db *sql.DB,
+	taskID int,
+) ([]*Artifact, error) {
+	query := `
+		SELECT id, task_id, name, storage_key, content_type, size, created_at
+		FROM task_artifacts
+		WHERE task_id = $1
+		ORDER BY id
+	`
+
+	rows, err := db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var artifacts []*Artifact
+	for rows.Next() {
+		var a Artifact
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.Name, &a.StorageKey, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, &a)
+	}
+
+	return artifacts, rows.Err()
+}
+
+func (r *TaskRepository) GetArtifact(
+	ctx context.Context,
+	db *sql.DB,
+	taskID int,
+	name string,
+) (*Artifact, error) {
+	query := `
+		SELECT id, task_id, name, storage_key, content_type, size, created_at
+		FROM task_artifacts
+		WHERE task_id = $1 AND name = $2
 	`
-	_, err := tx.Exec(query, resultFile, id)
+
+	var a Artifact
+	err := db.QueryRowContext(ctx, query, taskID, name).
+		Scan(&a.ID, &a.TaskID, &a.Name, &a.StorageKey, &a.ContentType, &a.Size, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// SetProgress records how far a running task is. It is a no-op once the
+// task has left PROCESSING, so a late update cannot touch a finished task.
+func (r *TaskRepository) SetProgress(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	percent int,
+) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE tasks SET progress = $2 WHERE id = $1 AND status = $3`,
+		id, percent, string(StatusProcessing),
+	)
 	return err
 }
 
-func (r *TaskRepository) MarkFailed(
+// DeletePending removes a task that was never queued. Its upload references
+// go with it, so the uploads can be collected again. Only a PENDING task is
+// removed.
+func (r *TaskRepository) DeletePending(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) error {
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM tasks WHERE id = $1 AND status = $2`,
+		id, string(StatusPending),
+	)
+	return err
+}
+
+// AttachUploads records that the task reads the given uploads, which keeps
+// them from being garbage collected while it runs. Every upload must exist
+// and belong to userID, otherwise ErrInvalidUploadRef is returned.
+func (r *TaskRepository) AttachUploads(
+	ctx context.Context,
+	tx *sql.Tx,
+	taskID int,
+	userID int,
+	uploadIDs []string,
+) error {
+	if len(uploadIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO task_uploads (task_id, upload_id)
+		SELECT $1, id FROM uploads
+		WHERE id = ANY($2) AND user_id = $3
+		ON CONFLICT DO NOTHING
+	`
+
+	result, err := tx.ExecContext(ctx, query, taskID, uploadIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(n) != len(uploadIDs) {
+		return fmt.Errorf("%w: upload not found", ErrInvalidUploadRef)
+	}
+	return nil
+}
+
+// transition moves a task to status `to`, but only from a status the
+// transition table allows. assignments are extra SET clauses whose
+// placeholders start at $4. When no row matches, the current status is
+// read back to tell a missing task from an illegal transition.
+func (r *TaskRepository) transition(
+	ctx context.Context,
 	tx *sql.Tx,
 	id int,
-	errorMessage string,
+	to Status,
+	assignments string,
+	args ...any,
 ) error {
+	set := "status = $1, updated_at = NOW()"
+	if assignments != "" {
+		set += ", " + assignments
+	}
 	query := `
 		UPDATE tasks
-		SET status = 'FAILED',
-		    error_message = $1,
-		    updated_at = NOW()
-		WHERE id = $2
+		SET ` + set + `
+		WHERE id = $2 AND status = ANY($3)
 	`
-	_, err := tx.Exec(query, errorMessage, id)
-	return err
+
+	params := append([]any{string(to), id, allowedFrom(to)}, args...)
+	result, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM tasks WHERE id = $1`, id).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return &TransitionError{TaskID: id, From: Status(current), To: to}
 }
@@ -4,133 +4,249 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"task_handler/internal/artifact"
 	"task_handler/internal/cache"
+	"task_handler/internal/logger"
+	"task_handler/internal/observability"
 	"task_handler/internal/queue"
 	"task_handler/internal/utils"
 	"time"
 
-	"github.com/go-redis/redis/v8"
-	amqp "github.com/rabbitmq/amqp091-go"
-	"github.com/sirupsen/logrus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TaskServiceInterface interface {
-	CreateTask(task *Task) error
-	GetTask(taskID int) (*Task, error)
-	GetTasks(userID int) ([]*Task, error)
+	CreateTask(ctx context.Context, task *Task) error
+	GetTask(ctx context.Context, taskID int) (*Task, error)
+	// GetCurrentTask reads the task from the database, for callers that
+	// must see its latest status
+	GetCurrentTask(ctx context.Context, taskID int) (*Task, error)
+	GetTasks(ctx context.Context, userID int) ([]*Task, error)
+	GetArtifacts(ctx context.Context, taskID int) ([]*Artifact, error)
+	OpenArtifact(ctx context.Context, taskID int, name string) (*Artifact, *artifact.Object, error)
 }
 
+// cacheTimeout bounds cache calls so a slow cache degrades to a miss
+// instead of holding up the request
+const cacheTimeout = 2 * time.Second
+
 type TaskService struct {
-	repo  TaskRepositoryInterface
-	conn  *amqp.Connection
-	DB    *sql.DB
-	cache *cache.TaskCache
+	repo   TaskRepositoryInterface
+	broker queue.Broker
+	DB     *sql.DB
+	cache  *cache.TaskCache
+	store  artifact.Store
 }
 
-func NewTaskService(repo TaskRepositoryInterface, db *sql.DB, conn *amqp.Connection, redisClient *redis.Client) TaskServiceInterface {
+func NewTaskService(repo TaskRepositoryInterface, db *sql.DB, broker queue.Broker, taskCache *cache.TaskCache, store artifact.Store) TaskServiceInterface {
 	return &TaskService{
-		repo:  repo,
-		DB:    db,
-		conn:  conn,
-		cache: cache.NewTaskCache(redisClient),
+		repo:   repo,
+		DB:     db,
+		broker: broker,
+		cache:  taskCache,
+		store:  store,
 	}
 }
 
-func (s *TaskService) CreateTask(task *Task) error {
+func (s *TaskService) CreateTask(ctx context.Context, task *Task) error {
 	if task.UserID == 0 || task.TaskType == "" {
 		return fmt.Errorf("invalid task payload")
 	}
 
-	if err := utils.WithTransaction(s.DB, func(tx *sql.Tx) error {
-		taskID, err := s.repo.Create(tx, task)
+	uploadIDs, err := UploadReferences(task.Params)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.WithTransaction(ctx, s.DB, func(tx *sql.Tx) error {
+		taskID, err := s.repo.Create(ctx, tx, task)
 		if err != nil {
 			return err
 		}
 		task.ID = taskID
-		return nil
+		return s.repo.AttachUploads(ctx, tx, taskID, task.UserID, uploadIDs)
 	}); err != nil {
 		return err
 	}
 
-	ch, err := queue.CreateChannel(s.conn)
+	envelope := NewEnvelope(task)
+
+	pubCtx, span := observability.Tracer().Start(ctx, "publish "+queue.TaskQueue,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationName(queue.TaskQueue),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingMessageID(envelope.MessageID),
+			observability.TaskIDKey.Int(task.ID),
+			observability.TaskTypeKey.String(task.TaskType),
+		),
+	)
+	defer span.End()
+
+	// The trace context travels in the headers and, for consumers reading
+	// only the body, in the envelope
+	headers := map[string]string{}
+	observability.InjectHeaders(pubCtx, headers)
+	if len(headers) > 0 {
+		envelope.TraceContext = maps.Clone(headers)
+	}
+	if id := logger.RequestID(ctx); id != "" {
+		headers[queue.HeaderRequestID] = id
+	}
+
+	body, err := envelope.Marshal()
 	if err != nil {
+		err = fmt.Errorf("failed to encode task message: %w", err)
+		observability.RecordError(span, err)
 		return err
 	}
-	defer ch.Close()
-
-	return ch.Publish(
-		"",
-		"task_queue",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body: []byte(`{
-			"id": ` + fmt.Sprintf("%d", task.ID) + `,
-			"user_id": ` + fmt.Sprintf("%d", task.UserID) + `,
-			"task_type": ` + fmt.Sprintf("%q", task.TaskType) + `
-		}`),
-		},
-	)
+
+	// The cached task list no longer includes the new task
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	if err := s.cache.Invalidate(cacheCtx, task.ID, task.UserID); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to invalidate cache for new task")
+	}
+
+	msg := &queue.Message{
+		ID:          envelope.MessageID,
+		Type:        envelope.TaskType,
+		ContentType: "application/json",
+		Timestamp:   envelope.CreatedAt,
+		Headers:     headers,
+		Body:        body,
+	}
+	if err := s.broker.Publish(pubCtx, queue.TaskQueue, msg); err != nil {
+		observability.RecordError(span, err)
+		if errors.Is(err, queue.ErrQueueFull) {
+			s.discard(ctx, task)
+		}
+		return err
+	}
+	observability.TasksCreated.WithLabelValues(observability.TaskType(task.TaskType)).Inc()
+	return nil
 }
 
-func (s *TaskService) GetTask(taskID int) (*Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// discard drops a task the queue refused. Nothing would ever run it, and a
+// client retrying after the refusal would otherwise leave a duplicate behind.
+func (s *TaskService) discard(ctx context.Context, task *Task) {
+	if err := s.repo.DeletePending(ctx, s.DB, task.ID); err != nil {
+		logger.FromContext(ctx).WithError(err).Errorf("Failed to delete task %d refused by the queue", task.ID)
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	if err := s.cache.Invalidate(cacheCtx, task.ID, task.UserID); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to invalidate cache for discarded task")
+	}
+}
+
+func (s *TaskService) GetTask(ctx context.Context, taskID int) (*Task, error) {
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
 	defer cancel()
 
 	// Try cache first
 	cacheKey := cache.TaskKey(taskID)
-	cachedData, err := s.cache.Get(ctx, cacheKey)
+	cachedData, err := s.cache.Get(cacheCtx, cacheKey)
 	if err == nil && cachedData != nil {
 		var task Task
 		if json.Unmarshal(cachedData, &task) == nil {
-			logrus.Info("cache hit for task ", taskID)
+			logger.FromContext(ctx).Debugf("cache hit for task %d", taskID)
+			observability.CacheRequests.WithLabelValues("task", observability.CacheHit).Inc()
 			return &task, nil
 		}
 	}
+	logger.FromContext(ctx).Debugf("cache miss for task %d", taskID)
+	observability.CacheRequests.WithLabelValues("task", observability.CacheMiss).Inc()
 
 	// Cache miss, get from DB
-	task, err := s.repo.GetByID(s.DB, taskID)
+	task, err := s.repo.GetByID(ctx, s.DB, taskID)
 	if err != nil {
 		return nil, err
 	}
 
-	logrus.Info("cache miss for task ", taskID)
 	// Set cache (ignore error, cache miss is not critical)
-	if err := s.cache.Set(ctx, cacheKey, task); err != nil {
-		logrus.WithError(err).Warn("Failed to set cache for task")
+	if err := s.cache.Set(cacheCtx, cacheKey, task); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to set cache for task")
+	}
+
+	return task, nil
+}
+
+// GetCurrentTask skips the cache and refreshes it with what it read. The
+// worker's invalidation is best effort and can race a reader refilling the
+// entry with the old status, so callers acting on the status read it here.
+func (s *TaskService) GetCurrentTask(ctx context.Context, taskID int) (*Task, error) {
+	task, err := s.repo.GetByID(ctx, s.DB, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	if err := s.cache.Set(cacheCtx, cache.TaskKey(taskID), task); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to set cache for task")
 	}
 
 	return task, nil
 }
 
-func (s *TaskService) GetTasks(userID int) ([]*Task, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+func (s *TaskService) GetTasks(ctx context.Context, userID int) ([]*Task, error) {
+	cacheCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
 	defer cancel()
 
 	// Try cache first
 	cacheKey := cache.UserTasksKey(userID)
-	cachedData, err := s.cache.Get(ctx, cacheKey)
+	cachedData, err := s.cache.Get(cacheCtx, cacheKey)
 	if err == nil && cachedData != nil {
 		var tasks []*Task
 		if json.Unmarshal(cachedData, &tasks) == nil {
-			logrus.Infof("cache hit for user %d tasks", userID)
+			logger.FromContext(ctx).Debugf("cache hit for user %d tasks", userID)
+			observability.CacheRequests.WithLabelValues("user_tasks", observability.CacheHit).Inc()
 			return tasks, nil
 		}
 	}
-	logrus.Infof("cache miss for user %d tasks", userID)
+	logger.FromContext(ctx).Debugf("cache miss for user %d tasks", userID)
+	observability.CacheRequests.WithLabelValues("user_tasks", observability.CacheMiss).Inc()
 
 	// Cache miss, get from DB
-	tasks, err := s.repo.GetByUserID(s.DB, userID)
+	tasks, err := s.repo.GetByUserID(ctx, s.DB, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set cache (ignore error, cache miss is not critical)
-	if err := s.cache.Set(ctx, cacheKey, tasks); err != nil {
-		logrus.WithError(err).Warn("Failed to set cache for user tasks")
+	if err := s.cache.Set(cacheCtx, cacheKey, tasks); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("Failed to set cache for user tasks")
 	}
 
 	return tasks, nil
 }
+
+func (s *TaskService) GetArtifacts(ctx context.Context, taskID int) ([]*Artifact, error) {
+	return s.repo.GetArtifacts(ctx, s.DB, taskID)
+}
+
+// OpenArtifact looks up a task's artifact and opens it for reading. The
+// object is read with a context detached from ctx's deadline, so a large
+// download is not cut off by the request timeout; the caller must close it.
+func (s *TaskService) OpenArtifact(ctx context.Context, taskID int, name string) (*Artifact, *artifact.Object, error) {
+	a, err := s.repo.GetArtifact(ctx, s.DB, taskID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := s.store.Get(context.WithoutCancel(ctx), a.StorageKey)
+	if errors.Is(err, artifact.ErrNotFound) {
+		return nil, nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, obj, nil
+}
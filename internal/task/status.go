@@ -0,0 +1,87 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is the lifecycle state of a task
+type Status string
+
+const (
+	StatusPending    Status = "PENDING"
+	StatusProcessing Status = "PROCESSING"
+	StatusSuccess    Status = "SUCCESS"
+	StatusFailed     Status = "FAILED"
+)
+
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrInvalidUploadRef means params name an upload that is malformed,
+	// missing or owned by someone else
+	ErrInvalidUploadRef = errors.New("invalid upload reference")
+	// ErrInvalidTransition is matched by every *TransitionError
+	ErrInvalidTransition = errors.New("invalid task status transition")
+)
+
+// transitions lists the states each status may move to. PROCESSING may be
+// re-entered because a message is redelivered when a worker dies or fails
+// to record the outcome; SUCCESS and FAILED are final.
+var transitions = map[Status][]Status{
+	StatusPending:    {StatusProcessing, StatusFailed},
+	StatusProcessing: {StatusProcessing, StatusSuccess, StatusFailed, StatusPending},
+	StatusSuccess:    {},
+	StatusFailed:     {},
+}
+
+// Valid reports whether s is a known status
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// IsTerminal reports whether no further transition is allowed from s
+func (s Status) IsTerminal() bool {
+	next, ok := transitions[s]
+	return ok && len(next) == 0
+}
+
+// CanTransitionTo reports whether the table allows moving from s to next
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedFrom returns every status that may move to target, i.e. the
+// statuses a conditional UPDATE to target must match
+func allowedFrom(target Status) []string {
+	var from []string
+	for s := range transitions {
+		if s.CanTransitionTo(target) {
+			from = append(from, string(s))
+		}
+	}
+	return from
+}
+
+// TransitionError reports a status change rejected because the task was
+// not in a state that allows it, e.g. a redelivered message for a task
+// that already succeeded
+type TransitionError struct {
+	TaskID int
+	From   Status
+	To     Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("task %d: cannot move from %s to %s", e.TaskID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_Transitions(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		allowed  bool
+	}{
+		{StatusPending, StatusProcessing, true},
+		{StatusPending, StatusFailed, true},
+		{StatusPending, StatusSuccess, false},
+		{StatusProcessing, StatusSuccess, true},
+		{StatusProcessing, StatusFailed, true},
+		{StatusProcessing, StatusPending, true},
+		{StatusProcessing, StatusProcessing, true},
+		{StatusSuccess, StatusProcessing, false},
+		{StatusSuccess, StatusFailed, false},
+		{StatusFailed, StatusProcessing, false},
+		{StatusFailed, StatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s_to_%s", tt.from, tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestStatus_Terminal(t *testing.T) {
+	assert.True(t, StatusSuccess.IsTerminal())
+	assert.True(t, StatusFailed.IsTerminal())
+	assert.False(t, StatusPending.IsTerminal())
+	assert.False(t, StatusProcessing.IsTerminal())
+	assert.False(t, Status("COMPLETED").IsTerminal(), "unknown status is not terminal")
+	assert.False(t, Status("COMPLETED").Valid())
+}
+
+func TestAllowedFrom(t *testing.T) {
+	assert.ElementsMatch(t, []string{"PROCESSING"}, allowedFrom(StatusSuccess))
+	assert.ElementsMatch(t, []string{"PENDING", "PROCESSING"}, allowedFrom(StatusProcessing))
+	assert.Empty(t, allowedFrom(Status("COMPLETED")))
+}
+
+func TestTransitionError_MatchesSentinel(t *testing.T) {
+	err := fmt.Errorf("mark success: %w", &TransitionError{TaskID: 1, From: StatusSuccess, To: StatusProcessing})
+
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+
+	var transitionErr *TransitionError
+	assert.True(t, errors.As(err, &transitionErr))
+	assert.True(t, transitionErr.From.IsTerminal())
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// UploadReferences returns the upload IDs a task's params refer to. A
+// top-level key "upload_id" or ending in "_upload_id" holds one ID, and
+// "upload_ids" or "*_upload_ids" holds a list of them.
+func UploadReferences(params json.RawMessage) ([]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(params, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUploadRef, err)
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(key, id string) error {
+		if id == "" {
+			return fmt.Errorf("%w: %s is empty", ErrInvalidUploadRef, key)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		return nil
+	}
+
+	for key, raw := range fields {
+		switch {
+		case key == "upload_id" || strings.HasSuffix(key, "_upload_id"):
+			var id string
+			if err := json.Unmarshal(raw, &id); err != nil {
+				return nil, fmt.Errorf("%w: %s must be a string", ErrInvalidUploadRef, key)
+			}
+			if err := add(key, id); err != nil {
+				return nil, err
+			}
+		case key == "upload_ids" || strings.HasSuffix(key, "_upload_ids"):
+			var list []string
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("%w: %s must be a list of strings", ErrInvalidUploadRef, key)
+			}
+			for _, id := range list {
+				if err := add(key, id); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return ids, nil
+}
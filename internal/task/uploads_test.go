@@ -0,0 +1,37 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadReferences(t *testing.T) {
+	ids, err := UploadReferences(json.RawMessage(`{
+		"upload_id": "a",
+		"logo_upload_id": "b",
+		"attachment_upload_ids": ["c", "a"],
+		"width": 100,
+		"uploaded_by": "x"
+	}`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, ids)
+
+	ids, err = UploadReferences(nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestUploadReferences_Invalid(t *testing.T) {
+	for _, params := range []string{
+		`{"upload_id": 5}`,
+		`{"upload_id": ""}`,
+		`{"upload_ids": "a"}`,
+		`{"upload_ids": [""]}`,
+	} {
+		_, err := UploadReferences(json.RawMessage(params))
+		assert.ErrorIs(t, err, ErrInvalidUploadRef, params)
+	}
+}
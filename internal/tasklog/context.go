@@ -0,0 +1,19 @@
+package tasklog
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// FromContext returns the task logger of a handler's context. Outside a
+// task it returns an entry of the standard logger, whose lines are not
+// stored.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
@@ -0,0 +1,179 @@
+package tasklog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"task_handler/internal/auth"
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLimit = 500
+	maxLimit     = 1000
+)
+
+type TaskLogController struct {
+	service TaskLogServiceInterface
+	cfg     config.TaskLogConfig
+	// poll is how often a follow request looks for new lines
+	poll time.Duration
+}
+
+func NewTaskLogController(service TaskLogServiceInterface, cfg config.TaskLogConfig) *TaskLogController {
+	return &TaskLogController{
+		service: service,
+		cfg:     cfg,
+		poll:    time.Second,
+	}
+}
+
+// Follows reports whether a request asks to follow a task's log. Such
+// requests outlive HTTP_REQUEST_TIMEOUT, up to TASK_LOG_FOLLOW_TIMEOUT.
+func Follows(c *gin.Context) bool {
+	follow, _ := strconv.ParseBool(c.Query("follow"))
+	return follow
+}
+
+// GetLogs returns a page of the caller's task log, or with follow=true
+// streams it as NDJSON until the task is done
+func (lc *TaskLogController) GetLogs(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	after, err := queryInt(c, "after", 0)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a line ID"})
+		return
+	}
+	limit, err := queryInt(c, "limit", defaultLimit)
+	if err != nil || limit < 1 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+	attempt, err := queryInt(c, "attempt", 0)
+	if err != nil || attempt < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attempt must be a positive number"})
+		return
+	}
+
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if Follows(c) {
+		lc.follow(c, userID, taskID, int(attempt), after)
+		return
+	}
+
+	lines, status, err := lc.service.GetLogs(c.Request.Context(), userID, taskID, int(attempt), after, int(limit))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	next := after
+	if len(lines) > 0 {
+		next = lines[len(lines)-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":    taskID,
+		"status":     status,
+		"logs":       lines,
+		"next_after": next,
+		"has_more":   len(lines) == int(limit),
+	})
+}
+
+// follow writes one line per JSON object as lines are stored. It ends once
+// the task is done and every line was sent, at TASK_LOG_FOLLOW_TIMEOUT, or
+// when the client goes away.
+func (lc *TaskLogController) follow(c *gin.Context, userID, taskID, attempt int, after int64) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), lc.cfg.FollowTimeout)
+	defer cancel()
+
+	// HTTP_WRITE_TIMEOUT would cut the stream short; not every writer
+	// supports deadlines, in which case there is none to extend
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetWriteDeadline(time.Now().Add(lc.cfg.FollowTimeout + 10*time.Second)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logrus.WithError(err).Warn("Failed to extend the write deadline of a log follow request")
+	}
+
+	ticker := time.NewTicker(lc.poll)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(c.Writer)
+	started := false
+	for {
+		lines, status, err := lc.service.GetLogs(ctx, userID, taskID, attempt, after, maxLimit)
+		if err != nil {
+			if !started {
+				writeError(c, err)
+			} else if ctx.Err() == nil {
+				logrus.WithError(err).Warnf("Stopped following the log of task %d", taskID)
+			}
+			return
+		}
+
+		if !started {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("Cache-Control", "no-store")
+			c.Status(http.StatusOK)
+			started = true
+		}
+		for _, line := range lines {
+			if err := enc.Encode(line); err != nil {
+				return
+			}
+			after = line.ID
+		}
+		c.Writer.Flush()
+
+		if len(lines) == maxLimit {
+			continue
+		}
+		if status.IsTerminal() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func queryInt(c *gin.Context, name string, def int64) (int64, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// writeError maps service errors to responses
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only read the logs of your own tasks"})
+	case errors.Is(err, task.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get task logs"})
+	}
+}
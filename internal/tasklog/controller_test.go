@@ -0,0 +1,124 @@
+package tasklog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTaskLogService struct {
+	mock.Mock
+}
+
+func (m *MockTaskLogService) GetLogs(ctx context.Context, userID, taskID, attempt int, after int64, limit int) ([]*Line, task.Status, error) {
+	args := m.Called(ctx, userID, taskID, attempt, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(task.Status), args.Error(2)
+	}
+	return args.Get(0).([]*Line), args.Get(1).(task.Status), args.Error(2)
+}
+
+func setupTestRouter(service TaskLogServiceInterface) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	controller := NewTaskLogController(service, config.TaskLogConfig{FollowTimeout: 5 * time.Second})
+	controller.poll = 10 * time.Millisecond
+	router.GET("/tasks/:id/logs", func(c *gin.Context) {
+		c.Set("userID", 1)
+		controller.GetLogs(c)
+	})
+
+	return router
+}
+
+func lines(ids ...int64) []*Line {
+	out := make([]*Line, len(ids))
+	for i, id := range ids {
+		out[i] = &Line{ID: id, Attempt: 1, Level: "info", Message: "line"}
+	}
+	return out
+}
+
+func TestGetLogs_Page(t *testing.T) {
+	svc := new(MockTaskLogService)
+	svc.On("GetLogs", mock.Anything, 1, 5, 2, int64(10), 2).Return(lines(11, 12), task.StatusProcessing, nil)
+	router := setupTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/5/logs?after=10&limit=2&attempt=2", nil))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Status    task.Status `json:"status"`
+		Logs      []*Line     `json:"logs"`
+		NextAfter int64       `json:"next_after"`
+		HasMore   bool        `json:"has_more"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, task.StatusProcessing, resp.Status)
+	assert.Len(t, resp.Logs, 2)
+	assert.Equal(t, int64(12), resp.NextAfter)
+	assert.True(t, resp.HasMore)
+}
+
+func TestGetLogs_InvalidQuery(t *testing.T) {
+	router := setupTestRouter(new(MockTaskLogService))
+
+	for _, query := range []string{"limit=0", "limit=5000", "after=-1", "attempt=x"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/5/logs?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetLogs_Errors(t *testing.T) {
+	svc := new(MockTaskLogService)
+	svc.On("GetLogs", mock.Anything, 1, 5, 0, int64(0), defaultLimit).Return(nil, task.Status(""), ErrForbidden)
+	svc.On("GetLogs", mock.Anything, 1, 6, 0, int64(0), maxLimit).Return(nil, task.Status(""), task.ErrTaskNotFound)
+	router := setupTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/5/logs", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A follow request that fails before streaming gets a normal error
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/6/logs?follow=true", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetLogs_FollowUntilDone(t *testing.T) {
+	svc := new(MockTaskLogService)
+	svc.On("GetLogs", mock.Anything, 1, 5, 0, int64(0), maxLimit).Return(lines(1, 2), task.StatusProcessing, nil).Once()
+	svc.On("GetLogs", mock.Anything, 1, 5, 0, int64(2), maxLimit).Return(lines(), task.StatusProcessing, nil).Once()
+	svc.On("GetLogs", mock.Anything, 1, 5, 0, int64(2), maxLimit).Return(lines(3), task.StatusFailed, nil).Once()
+	router := setupTestRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/5/logs?follow=true", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var ids []int64
+	for _, raw := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		var l Line
+		require.NoError(t, json.Unmarshal([]byte(raw), &l))
+		ids = append(ids, l.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	svc.AssertExpectations(t)
+}
@@ -0,0 +1,23 @@
+// Package tasklog stores what task handlers log, per attempt, so users can
+// see why a task did what it did, and serves it back to them.
+package tasklog
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrForbidden = errors.New("not the owner of the task")
+
+// Line is one stored log line. IDs grow with every line of a task, so
+// they double as a cursor for reading new lines.
+type Line struct {
+	ID       int64           `json:"id"`
+	TaskID   int             `json:"-"`
+	Attempt  int             `json:"attempt"`
+	Level    string          `json:"level"`
+	Message  string          `json:"message"`
+	Fields   json.RawMessage `json:"fields,omitempty"`
+	LoggedAt time.Time       `json:"time"`
+}
@@ -0,0 +1,209 @@
+package tasklog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"task_handler/internal/config"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeTimeout bounds one write of buffered lines
+const writeTimeout = 5 * time.Second
+
+// scopeFields are on every line of a task logger; they are not stored
+// with each line because the line's row already says the same
+var scopeFields = []string{"request_id", "task_id", "task_type", "attempt", "worker_id", "trace_id"}
+
+type recorderKey struct{}
+
+var installHook sync.Once
+
+// Recorder collects the lines logged through its task logger and writes
+// them in batches. Lines past MaxLines are counted, not stored.
+type Recorder struct {
+	repo    TaskLogRepositoryInterface
+	db      *sql.DB
+	cfg     config.TaskLogConfig
+	taskID  int
+	attempt int
+
+	mu      sync.Mutex
+	pending []*Line
+	kept    int
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder starts recording an attempt of a task. Close must be called
+// when the attempt ends. Without a database lines only go to the standard
+// logger.
+func NewRecorder(repo TaskLogRepositoryInterface, db *sql.DB, cfg config.TaskLogConfig, taskID, attempt int) *Recorder {
+	installHook.Do(func() {
+		logrus.AddHook(hook{})
+	})
+
+	r := &Recorder{
+		repo:    repo,
+		db:      db,
+		cfg:     cfg,
+		taskID:  taskID,
+		attempt: attempt,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.flushLoop()
+	return r
+}
+
+// Context returns ctx carrying the recorder, so that FromContext(ctx)
+// logs into it with the fields of entry
+func (r *Recorder) Context(ctx context.Context, entry *logrus.Entry) context.Context {
+	entry = entry.WithContext(context.WithValue(ctx, recorderKey{}, r))
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
+
+func (r *Recorder) record(entry *logrus.Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.kept >= r.cfg.MaxLines {
+		r.dropped++
+		return
+	}
+	r.kept++
+	r.pending = append(r.pending, &Line{
+		Attempt:  r.attempt,
+		Level:    entry.Level.String(),
+		Message:  truncate(entry.Message, r.cfg.MaxLineBytes),
+		Fields:   encodeFields(entry.Data),
+		LoggedAt: entry.Time.UTC(),
+	})
+}
+
+func (r *Recorder) flushLoop() {
+	defer close(r.done)
+
+	interval := r.cfg.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
+
+// flush writes the pending lines. Lines that fail to be written are lost:
+// the task must not fail because its log could not be stored.
+func (r *Recorder) flush() {
+	r.mu.Lock()
+	lines := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	if len(lines) == 0 || r.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := r.repo.Append(ctx, r.db, r.taskID, r.attempt, lines); err != nil {
+		logrus.WithError(err).Warnf("Failed to store %d log lines of task %d", len(lines), r.taskID)
+	}
+}
+
+// Close writes the remaining lines, noting how many were dropped
+func (r *Recorder) Close() {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	if r.dropped > 0 {
+		r.pending = append(r.pending, &Line{
+			Attempt:  r.attempt,
+			Level:    logrus.WarnLevel.String(),
+			Message:  fmt.Sprintf("%d more lines were not stored, the limit is %d per attempt", r.dropped, r.cfg.MaxLines),
+			LoggedAt: time.Now().UTC(),
+		})
+	}
+	r.mu.Unlock()
+
+	r.flush()
+}
+
+// hook passes entries logged through a task logger to its recorder. It is
+// installed once on the standard logger, so task lines are also printed
+// like any other line.
+type hook struct{}
+
+func (hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if r, ok := entry.Context.Value(recorderKey{}).(*Recorder); ok {
+		r.record(entry)
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// encodeFields stores an entry's fields other than the scope fields as a
+// JSON object, errors and other values JSON cannot encode as text
+func encodeFields(data logrus.Fields) json.RawMessage {
+	fields := make(map[string]any, len(data))
+	for k, v := range data {
+		fields[k] = v
+	}
+	for _, k := range scopeFields {
+		delete(fields, k)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for k, v := range fields {
+		switch v := v.(type) {
+		case error:
+			fields[k] = v.Error()
+		default:
+			if _, err := json.Marshal(v); err != nil {
+				fields[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return out
+}
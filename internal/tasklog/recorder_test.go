@@ -0,0 +1,91 @@
+package tasklog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTaskLogRepository struct {
+	mock.Mock
+}
+
+func (m *MockTaskLogRepository) Append(ctx context.Context, db *sql.DB, taskID, attempt int, lines []*Line) error {
+	args := m.Called(ctx, db, taskID, attempt, lines)
+	return args.Error(0)
+}
+
+func (m *MockTaskLogRepository) List(ctx context.Context, db *sql.DB, taskID int, attempt int, after int64, limit int) ([]*Line, error) {
+	args := m.Called(ctx, db, taskID, attempt, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Line), args.Error(1)
+}
+
+func TestRecorder_StoresBoundedLines(t *testing.T) {
+	repo := new(MockTaskLogRepository)
+	var stored []*Line
+	repo.On("Append", mock.Anything, mock.Anything, 7, 2, mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = append(stored, args.Get(4).([]*Line)...)
+		}).
+		Return(nil)
+
+	cfg := config.TaskLogConfig{MaxLines: 3, MaxLineBytes: 8, FlushInterval: time.Hour}
+	rec := NewRecorder(repo, new(sql.DB), cfg, 7, 2)
+	ctx := rec.Context(context.Background(), logrus.WithFields(logrus.Fields{"task_id": 7, "attempt": 2, "worker_id": 1}))
+
+	log := FromContext(ctx)
+	log.WithField("rows", 12).Info("started")
+	logrus.Info("not a task line")
+	log.WithError(errors.New("boom")).Warn("héllo wörld")
+	log.Debug("below the logger's level")
+	for range 3 {
+		log.Error("later")
+	}
+	rec.Close()
+
+	require.Len(t, stored, 4)
+
+	assert.Equal(t, "info", stored[0].Level)
+	assert.Equal(t, "started", stored[0].Message)
+	assert.JSONEq(t, `{"rows": 12}`, string(stored[0].Fields))
+	assert.Equal(t, 2, stored[0].Attempt)
+
+	assert.Equal(t, "héllo w…", stored[1].Message)
+	assert.JSONEq(t, `{"error": "boom"}`, string(stored[1].Fields))
+
+	assert.Equal(t, "later", stored[2].Message)
+	assert.Nil(t, stored[2].Fields)
+
+	assert.Equal(t, "warning", stored[3].Level)
+	assert.True(t, strings.HasPrefix(stored[3].Message, "2 more lines were not stored"), stored[3].Message)
+}
+
+func TestRecorder_KeepsGoingWhenStoreFails(t *testing.T) {
+	repo := new(MockTaskLogRepository)
+	repo.On("Append", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("database is down"))
+
+	rec := NewRecorder(repo, new(sql.DB), config.TaskLogConfig{MaxLines: 10, MaxLineBytes: 100, FlushInterval: time.Hour}, 1, 1)
+	FromContext(rec.Context(context.Background(), logrus.NewEntry(logrus.StandardLogger()))).Info("line")
+	rec.Close()
+
+	repo.AssertNumberOfCalls(t, "Append", 1)
+}
+
+func TestFromContext_OutsideTask(t *testing.T) {
+	entry := FromContext(context.Background())
+	assert.Empty(t, entry.Data)
+}
@@ -0,0 +1,81 @@
+package tasklog
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TaskLogRepository struct{}
+
+type TaskLogRepositoryInterface interface {
+	// Append stores lines of one attempt in order
+	Append(ctx context.Context, db *sql.DB, taskID, attempt int, lines []*Line) error
+	// List returns up to limit lines of a task with an ID above after,
+	// oldest first. An attempt of 0 selects every attempt.
+	List(ctx context.Context, db *sql.DB, taskID int, attempt int, after int64, limit int) ([]*Line, error)
+}
+
+func NewTaskLogRepository() TaskLogRepositoryInterface {
+	return &TaskLogRepository{}
+}
+
+func (r *TaskLogRepository) Append(ctx context.Context, db *sql.DB, taskID, attempt int, lines []*Line) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	levels := make([]string, len(lines))
+	messages := make([]string, len(lines))
+	fields := make([]*string, len(lines))
+	times := make([]time.Time, len(lines))
+	for i, l := range lines {
+		levels[i], messages[i], times[i] = l.Level, l.Message, l.LoggedAt
+		if len(l.Fields) > 0 {
+			f := string(l.Fields)
+			fields[i] = &f
+		}
+	}
+
+	// WITH ORDINALITY keeps the lines' order in their IDs
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO task_logs (task_id, attempt, level, message, fields, logged_at)
+		SELECT $1, $2, l.level, l.message, l.fields::jsonb, l.logged_at
+		FROM unnest($3::text[], $4::text[], $5::text[], $6::timestamptz[])
+			WITH ORDINALITY AS l(level, message, fields, logged_at, n)
+		ORDER BY l.n
+	`, taskID, attempt, levels, messages, fields, times)
+	return err
+}
+
+func (r *TaskLogRepository) List(ctx context.Context, db *sql.DB, taskID int, attempt int, after int64, limit int) ([]*Line, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, task_id, attempt, level, message, fields, logged_at
+		FROM task_logs
+		WHERE task_id = $1 AND id > $2 AND ($3 = 0 OR attempt = $3)
+		ORDER BY id
+		LIMIT $4
+	`, taskID, after, attempt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	lines := []*Line{}
+	for rows.Next() {
+		var l Line
+		var fields []byte
+		if err := rows.Scan(&l.ID, &l.TaskID, &l.Attempt, &l.Level, &l.Message, &fields, &l.LoggedAt); err != nil {
+			return nil, err
+		}
+		l.Fields = fields
+		lines = append(lines, &l)
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,45 @@
+package tasklog
+
+import (
+	"context"
+	"database/sql"
+	"task_handler/internal/task"
+)
+
+type TaskLogServiceInterface interface {
+	// GetLogs returns up to limit lines of a task owned by userID with an
+	// ID above after, and the task's status read before the lines, so a
+	// terminal status means no lines follow the ones returned. The status is
+	// read from the database, not the cache, so a follower sees it change.
+	GetLogs(ctx context.Context, userID, taskID, attempt int, after int64, limit int) ([]*Line, task.Status, error)
+}
+
+type TaskLogService struct {
+	repo  TaskLogRepositoryInterface
+	DB    *sql.DB
+	tasks task.TaskServiceInterface
+}
+
+func NewTaskLogService(repo TaskLogRepositoryInterface, db *sql.DB, tasks task.TaskServiceInterface) TaskLogServiceInterface {
+	return &TaskLogService{
+		repo:  repo,
+		DB:    db,
+		tasks: tasks,
+	}
+}
+
+func (s *TaskLogService) GetLogs(ctx context.Context, userID, taskID, attempt int, after int64, limit int) ([]*Line, task.Status, error) {
+	t, err := s.tasks.GetCurrentTask(ctx, taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	if t.UserID != userID {
+		return nil, "", ErrForbidden
+	}
+
+	lines, err := s.repo.List(ctx, s.DB, taskID, attempt, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	return lines, t.Status, nil
+}
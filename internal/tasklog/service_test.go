@@ -0,0 +1,54 @@
+package tasklog
+
+import (
+	"context"
+	"testing"
+
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTaskService only implements GetCurrentTask; any other call panics on
+// the nil embedded interface
+type MockTaskService struct {
+	task.TaskServiceInterface
+	mock.Mock
+}
+
+func (m *MockTaskService) GetCurrentTask(ctx context.Context, taskID int) (*task.Task, error) {
+	args := m.Called(ctx, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*task.Task), args.Error(1)
+}
+
+func TestGetLogs_ReadsCurrentStatus(t *testing.T) {
+	tasks := new(MockTaskService)
+	repo := new(MockTaskLogRepository)
+	svc := NewTaskLogService(repo, nil, tasks)
+
+	lines := []*Line{{ID: 1, Message: "done"}}
+	tasks.On("GetCurrentTask", mock.Anything, 5).Return(&task.Task{ID: 5, UserID: 1, Status: task.StatusSuccess}, nil)
+	repo.On("List", mock.Anything, mock.Anything, 5, 0, int64(0), 10).Return(lines, nil)
+
+	got, status, err := svc.GetLogs(context.Background(), 1, 5, 0, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, lines, got)
+	assert.Equal(t, task.StatusSuccess, status)
+}
+
+func TestGetLogs_Forbidden(t *testing.T) {
+	tasks := new(MockTaskService)
+	repo := new(MockTaskLogRepository)
+	svc := NewTaskLogService(repo, nil, tasks)
+
+	tasks.On("GetCurrentTask", mock.Anything, 5).Return(&task.Task{ID: 5, UserID: 2}, nil)
+
+	_, _, err := svc.GetLogs(context.Background(), 1, 5, 0, 0, 10)
+	assert.ErrorIs(t, err, ErrForbidden)
+	repo.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,100 @@
+package upload
+
+import (
+	"context"
+	"database/sql"
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// collectBatch bounds how many uploads one sweep deletes per query
+const collectBatch = 100
+
+// Collector periodically deletes uploads no unfinished task refers to
+type Collector struct {
+	repo     UploadRepositoryInterface
+	db       *sql.DB
+	store    artifact.Store
+	grace    time.Duration
+	interval time.Duration
+}
+
+func NewCollector(repo UploadRepositoryInterface, db *sql.DB, store artifact.Store, cfg config.UploadConfig) *Collector {
+	return &Collector{
+		repo:     repo,
+		db:       db,
+		store:    store,
+		grace:    cfg.GCGrace,
+		interval: cfg.GCInterval,
+	}
+}
+
+// Start runs the collector in the background; a zero interval disables it.
+// The returned function stops it and waits for a sweep in progress to
+// finish.
+func (c *Collector) Start() (stop func()) {
+	if c.interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := c.Collect(ctx)
+		if err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to collect unreferenced uploads")
+		}
+		if n > 0 {
+			logrus.Infof("Deleted %d unreferenced uploads", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect deletes every upload that is due and returns how many it deleted.
+// Rows go first: an object whose delete fails is orphaned but never
+// referenced again.
+func (c *Collector) Collect(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		uploads, err := c.repo.DeleteUnreferenced(ctx, c.db, c.grace, collectBatch)
+		if err != nil {
+			return total, err
+		}
+
+		for _, u := range uploads {
+			if err := c.store.Delete(ctx, u.StorageKey); err != nil {
+				logrus.WithError(err).Warnf("Failed to delete object of upload %s", u.ID)
+			}
+		}
+		total += len(uploads)
+
+		if len(uploads) < collectBatch {
+			return total, nil
+		}
+	}
+}
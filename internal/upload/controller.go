@@ -0,0 +1,98 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"task_handler/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is allowed on top of the file size for the multipart
+// boundaries and part headers
+const multipartOverhead = 64 << 10
+
+type UploadController struct {
+	service  UploadServiceInterface
+	maxBytes int64
+}
+
+func NewUploadController(service UploadServiceInterface, maxBytes int64) *UploadController {
+	return &UploadController{
+		service:  service,
+		maxBytes: maxBytes,
+	}
+}
+
+// CreateUpload stores the multipart field "file" and returns its ID. The
+// file is streamed to the artifact store, never buffered whole.
+func (uc *UploadController) CreateUpload(c *gin.Context) {
+	userID, err := auth.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uc.maxBytes+multipartOverhead)
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart/form-data body"})
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing form field \"file\""})
+			return
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(c, err)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Malformed multipart body"})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		upload, err := uc.service.Create(c.Request.Context(), userID, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"upload_id":    upload.ID,
+			"filename":     upload.Filename,
+			"content_type": upload.ContentType,
+			"size":         upload.Size,
+			"created_at":   upload.CreatedAt,
+		})
+		return
+	}
+}
+
+// writeError maps service and body errors to responses
+func writeError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+	case errors.Is(err, ErrTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store upload"})
+	}
+}
@@ -0,0 +1,109 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestRouter(service UploadServiceInterface, maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	controller := NewUploadController(service, maxBytes)
+	router.POST("/uploads", func(c *gin.Context) {
+		c.Set("userID", 1)
+		controller.CreateUpload(c)
+	})
+
+	return router
+}
+
+// multipartBody builds a form with the given field holding content
+func multipartBody(t *testing.T, field, filename string, content []byte) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField("note", "ignored"))
+	fw, err := mw.CreateFormFile(field, filename)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	return &buf, mw.FormDataContentType()
+}
+
+func TestCreateUpload_Success(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	repo.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	router := setupTestRouter(svc, 64)
+
+	body, contentType := multipartBody(t, "file", "cat.png", pngHeader)
+	req := httptest.NewRequest("POST", "/uploads", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp["upload_id"], 32)
+	assert.Equal(t, "cat.png", resp["filename"])
+	assert.Equal(t, "image/png", resp["content_type"])
+	assert.Equal(t, float64(len(pngHeader)), resp["size"])
+}
+
+func TestCreateUpload_TooLarge(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	router := setupTestRouter(svc, 64)
+
+	body, contentType := multipartBody(t, "file", "a.txt", []byte(strings.Repeat("a", 100)))
+	req := httptest.NewRequest("POST", "/uploads", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateUpload_TypeNotAllowed(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	router := setupTestRouter(svc, 64)
+
+	// The client's claimed type does not matter, the content is a PDF
+	body, contentType := multipartBody(t, "file", "a.png", []byte("%PDF-1.7"))
+	req := httptest.NewRequest("POST", "/uploads", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestCreateUpload_BadRequest(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	router := setupTestRouter(svc, 64)
+
+	body, contentType := multipartBody(t, "other", "a.txt", []byte("hello"))
+	req := httptest.NewRequest("POST", "/uploads", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "missing file field")
+
+	req = httptest.NewRequest("POST", "/uploads", strings.NewReader(`{"file": "x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "not multipart")
+}
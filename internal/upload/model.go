@@ -0,0 +1,25 @@
+package upload
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrTooLarge       = errors.New("upload too large")
+	ErrEmpty          = errors.New("upload is empty")
+	// ErrTypeNotAllowed means the sniffed content type is not accepted
+	ErrTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// Upload is an input file a task's params may refer to by ID
+type Upload struct {
+	ID          string
+	UserID      int
+	Filename    string
+	ContentType string // Sniffed from the content, not taken from the client
+	Size        int64
+	StorageKey  string
+	CreatedAt   time.Time
+}
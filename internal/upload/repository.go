@@ -0,0 +1,122 @@
+package upload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type UploadRepository struct{}
+
+type UploadRepositoryInterface interface {
+	Create(ctx context.Context, db *sql.DB, upload *Upload) error
+	GetByID(ctx context.Context, db *sql.DB, id string) (*Upload, error)
+	DeleteUnreferenced(ctx context.Context, db *sql.DB, olderThan time.Duration, limit int) ([]*Upload, error)
+}
+
+func NewUploadRepository() UploadRepositoryInterface {
+	return &UploadRepository{}
+}
+
+const uploadColumns = `
+	id, user_id, filename, content_type, size, storage_key, created_at
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUpload(row scanner) (*Upload, error) {
+	var u Upload
+	err := row.Scan(
+		&u.ID,
+		&u.UserID,
+		&u.Filename,
+		&u.ContentType,
+		&u.Size,
+		&u.StorageKey,
+		&u.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *UploadRepository) Create(ctx context.Context, db *sql.DB, upload *Upload) error {
+	query := `
+		INSERT INTO uploads (
+			id, user_id, filename, content_type, size, storage_key, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING created_at
+	`
+
+	return db.QueryRowContext(
+		ctx,
+		query,
+		upload.ID,
+		upload.UserID,
+		upload.Filename,
+		upload.ContentType,
+		upload.Size,
+		upload.StorageKey,
+	).Scan(&upload.CreatedAt)
+}
+
+func (r *UploadRepository) GetByID(ctx context.Context, db *sql.DB, id string) (*Upload, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+uploadColumns+` FROM uploads WHERE id = $1`, id)
+
+	u, err := scanUpload(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUploadNotFound
+	}
+	return u, err
+}
+
+// DeleteUnreferenced removes up to limit uploads older than olderThan that
+// no PENDING or PROCESSING task refers to, and returns them so their
+// objects can be deleted. Rows locked by another collector, or by a task
+// being created with a reference to them, are skipped.
+func (r *UploadRepository) DeleteUnreferenced(ctx context.Context, db *sql.DB, olderThan time.Duration, limit int) ([]*Upload, error) {
+	query := `
+		DELETE FROM uploads
+		WHERE id IN (
+			SELECT u.id FROM uploads u
+			WHERE u.created_at < NOW() - make_interval(secs => $1)
+				AND NOT EXISTS (
+					SELECT 1 FROM task_uploads tu
+					JOIN tasks t ON t.id = tu.task_id
+					WHERE tu.upload_id = u.id
+						AND t.status IN ('PENDING', 'PROCESSING')
+				)
+			ORDER BY u.created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + uploadColumns
+
+	rows, err := db.QueryContext(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logrus.WithError(err).Warn("Failed to close rows")
+		}
+	}()
+
+	var uploads []*Upload
+	for rows.Next() {
+		u, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+
+	return uploads, rows.Err()
+}
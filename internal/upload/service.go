@@ -0,0 +1,163 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+	"task_handler/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+type UploadServiceInterface interface {
+	// Create stores r as a new upload of userID. The content type is
+	// sniffed from the first bytes; filename is only kept for display.
+	Create(ctx context.Context, userID int, filename string, r io.Reader) (*Upload, error)
+	// Open returns an upload of userID with its content. The caller must
+	// close the object.
+	Open(ctx context.Context, userID int, id string) (*Upload, *artifact.Object, error)
+}
+
+type UploadService struct {
+	repo  UploadRepositoryInterface
+	DB    *sql.DB
+	store artifact.Store
+	cfg   config.UploadConfig
+}
+
+func NewUploadService(repo UploadRepositoryInterface, db *sql.DB, store artifact.Store, cfg config.UploadConfig) UploadServiceInterface {
+	return &UploadService{
+		repo:  repo,
+		DB:    db,
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// StorageKey is where the content of an upload is stored
+func StorageKey(id string) string {
+	return "uploads/" + id
+}
+
+func (s *UploadService) Create(ctx context.Context, userID int, filename string, r io.Reader) (*Upload, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrEmpty
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !s.allowed(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotAllowed, contentType)
+	}
+
+	id := utils.RandomID()
+	upload := &Upload{
+		ID:          id,
+		UserID:      userID,
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		StorageKey:  StorageKey(id),
+	}
+
+	body := &limitedReader{r: io.MultiReader(bytes.NewReader(head), r), max: s.cfg.MaxBytes}
+	if err := s.store.Put(ctx, upload.StorageKey, body, -1, contentType); err != nil {
+		if body.exceeded {
+			return nil, ErrTooLarge
+		}
+		return nil, err
+	}
+	upload.Size = body.n
+
+	if err := s.repo.Create(ctx, s.DB, upload); err != nil {
+		// Without its row nothing refers to the object any more
+		if delErr := s.store.Delete(context.WithoutCancel(ctx), upload.StorageKey); delErr != nil {
+			logrus.WithError(delErr).Warnf("Failed to delete object of upload %s", upload.ID)
+		}
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (s *UploadService) Open(ctx context.Context, userID int, id string) (*Upload, *artifact.Object, error) {
+	upload, err := s.repo.GetByID(ctx, s.DB, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Someone else's upload looks the same as a missing one
+	if upload.UserID != userID {
+		return nil, nil, ErrUploadNotFound
+	}
+
+	obj, err := s.store.Get(ctx, upload.StorageKey)
+	if errors.Is(err, artifact.ErrNotFound) {
+		return nil, nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return upload, obj, nil
+}
+
+// allowed reports whether the media type of contentType is configured
+func (s *UploadService) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range s.cfg.AllowedTypes {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFilename drops any directory part and bounds the length
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
+
+// limitedReader fails once more than max bytes have been read, so an
+// oversized upload aborts the store write instead of being truncated
+type limitedReader struct {
+	r        io.Reader
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		l.exceeded = true
+		return n, ErrTooLarge
+	}
+	return n, err
+}
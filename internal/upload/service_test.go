@@ -0,0 +1,148 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockUploadRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadRepository) Create(ctx context.Context, db *sql.DB, upload *Upload) error {
+	return m.Called(ctx, db, upload).Error(0)
+}
+
+func (m *MockUploadRepository) GetByID(ctx context.Context, db *sql.DB, id string) (*Upload, error) {
+	args := m.Called(ctx, db, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Upload), args.Error(1)
+}
+
+func (m *MockUploadRepository) DeleteUnreferenced(ctx context.Context, db *sql.DB, olderThan time.Duration, limit int) ([]*Upload, error) {
+	args := m.Called(ctx, db, olderThan, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Upload), args.Error(1)
+}
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newTestService(t *testing.T) (*UploadService, *MockUploadRepository, artifact.Store) {
+	store, err := artifact.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	repo := new(MockUploadRepository)
+	svc := NewUploadService(repo, nil, store, config.UploadConfig{
+		MaxBytes:     64,
+		AllowedTypes: []string{"image/png", "text/plain"},
+	}).(*UploadService)
+	return svc, repo, store
+}
+
+func TestCreate_SniffsAndStores(t *testing.T) {
+	svc, repo, store := newTestService(t)
+	ctx := context.Background()
+	repo.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	body := append(append([]byte{}, pngHeader...), "pixels"...)
+	upload, err := svc.Create(ctx, 1, "../../photos/cat.png", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	assert.Len(t, upload.ID, 32)
+	assert.Equal(t, 1, upload.UserID)
+	assert.Equal(t, "cat.png", upload.Filename)
+	assert.Equal(t, "image/png", upload.ContentType)
+	assert.Equal(t, int64(len(body)), upload.Size)
+	assert.Equal(t, StorageKey(upload.ID), upload.StorageKey)
+
+	obj, err := store.Get(ctx, upload.StorageKey)
+	require.NoError(t, err)
+	defer obj.Close()
+	stored, _ := io.ReadAll(obj)
+	assert.Equal(t, body, stored)
+}
+
+func TestCreate_Rejected(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, 1, "a.pdf", strings.NewReader("%PDF-1.7 ..."))
+	assert.ErrorIs(t, err, ErrTypeNotAllowed)
+
+	_, err = svc.Create(ctx, 1, "a.txt", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	_, err = svc.Create(ctx, 1, "a.txt", strings.NewReader(strings.Repeat("a", 65)))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreate_ExactlyMaxBytes(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	repo.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	upload, err := svc.Create(context.Background(), 1, "a.txt", strings.NewReader(strings.Repeat("a", 64)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(64), upload.Size)
+}
+
+func TestOpen_OnlyOwner(t *testing.T) {
+	svc, repo, store := newTestService(t)
+	ctx := context.Background()
+
+	upload := &Upload{ID: "u1", UserID: 1, ContentType: "text/plain", StorageKey: StorageKey("u1")}
+	require.NoError(t, store.Put(ctx, upload.StorageKey, strings.NewReader("hello"), 5, "text/plain"))
+	repo.On("GetByID", mock.Anything, mock.Anything, "u1").Return(upload, nil)
+
+	_, _, err := svc.Open(ctx, 2, "u1")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	got, obj, err := svc.Open(ctx, 1, "u1")
+	require.NoError(t, err)
+	defer obj.Close()
+	assert.Equal(t, "u1", got.ID)
+	assert.Equal(t, int64(5), obj.Size)
+}
+
+func TestCollect_DeletesObjects(t *testing.T) {
+	_, repo, store := newTestService(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, StorageKey("old"), strings.NewReader("x"), 1, "text/plain"))
+	repo.On("DeleteUnreferenced", mock.Anything, mock.Anything, time.Hour, collectBatch).
+		Return([]*Upload{{ID: "old", StorageKey: StorageKey("old")}}, nil)
+
+	c := NewCollector(repo, nil, store, config.UploadConfig{GCGrace: time.Hour, GCInterval: time.Minute})
+	n, err := c.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = store.Get(ctx, StorageKey("old"))
+	assert.ErrorIs(t, err, artifact.ErrNotFound)
+}
+
+func TestCollector_DisabledByZeroInterval(t *testing.T) {
+	_, repo, store := newTestService(t)
+
+	c := NewCollector(repo, nil, store, config.UploadConfig{GCGrace: time.Hour})
+	c.Start()()
+	repo.AssertNotCalled(t, "DeleteUnreferenced", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
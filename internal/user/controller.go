@@ -1,6 +1,8 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -35,8 +37,12 @@ func (a *UserController) Register(c *gin.Context) {
 	}
 
 	// Create user
-	userID, err := a.userService.CreateUser(req.Username, req.Password)
+	userID, err := a.userService.CreateUser(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+			return
+		}
 		// Check for service layer duplicate user error
 		if err.Error() == "username already exists" {
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
@@ -77,7 +83,7 @@ func (a *UserController) Login(c *gin.Context) {
 	}
 
 	// Validate credentials
-	tokens, err := a.userService.LoginUser(req.Username, req.Password, a.jwtSecret)
+	tokens, err := a.userService.LoginUser(c.Request.Context(), req.Username, req.Password, a.jwtSecret)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 
@@ -10,10 +11,10 @@ import (
 type UserRepository struct{}
 
 type UserRepositoryInterface interface {
-	Create(tx *sql.Tx, user *User) (int, error)
-	GetByID(db *sql.DB, id int) (*User, error)
-	GetByUsername(db *sql.DB, username string) (*User, error)
-	UpdatePassword(tx *sql.Tx, id int, hashedPassword string) error
+	Create(ctx context.Context, tx *sql.Tx, user *User) (int, error)
+	GetByID(ctx context.Context, db *sql.DB, id int) (*User, error)
+	GetByUsername(ctx context.Context, db *sql.DB, username string) (*User, error)
+	UpdatePassword(ctx context.Context, tx *sql.Tx, id int, hashedPassword string) error
 }
 
 func NewUserRepository() UserRepositoryInterface {
@@ -22,6 +23,7 @@ func NewUserRepository() UserRepositoryInterface {
 
 // Create creates a new user in the database
 func (r *UserRepository) Create(
+	ctx context.Context,
 	tx *sql.Tx,
 	user *User,
 ) (int, error) {
@@ -34,7 +36,8 @@ func (r *UserRepository) Create(
 	`
 
 	var id int
-	err := tx.QueryRow(
+	err := tx.QueryRowContext(
+		ctx,
 		query,
 		user.Username,
 		user.Password,
@@ -54,7 +57,7 @@ func (r *UserRepository) Create(
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(db *sql.DB, id int) (*User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, db *sql.DB, id int) (*User, error) {
 	query := `
 		SELECT id, username, password, created_at
 		FROM users
@@ -62,7 +65,7 @@ func (r *UserRepository) GetByID(db *sql.DB, id int) (*User, error) {
 	`
 
 	user := &User{}
-	err := db.QueryRow(query, id).Scan(
+	err := db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
@@ -82,7 +85,7 @@ func (r *UserRepository) GetByID(db *sql.DB, id int) (*User, error) {
 }
 
 // GetByUsername retrieves a user by username
-func (r *UserRepository) GetByUsername(db *sql.DB, username string) (*User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, db *sql.DB, username string) (*User, error) {
 	query := `
 		SELECT id, username, password, created_at
 		FROM users
@@ -90,7 +93,7 @@ func (r *UserRepository) GetByUsername(db *sql.DB, username string) (*User, erro
 	`
 
 	user := &User{}
-	err := db.QueryRow(query, username).Scan(
+	err := db.QueryRowContext(ctx, query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
@@ -110,14 +113,14 @@ func (r *UserRepository) GetByUsername(db *sql.DB, username string) (*User, erro
 }
 
 // UpdatePassword updates user's password
-func (r *UserRepository) UpdatePassword(tx *sql.Tx, id int, hashedPassword string) error {
+func (r *UserRepository) UpdatePassword(ctx context.Context, tx *sql.Tx, id int, hashedPassword string) error {
 	query := `
 		UPDATE users
 		SET password = $1
 		WHERE id = $2
 	`
 
-	result, err := tx.Exec(query, hashedPassword, id)
+	result, err := tx.ExecContext(ctx, query, hashedPassword, id)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to update password")
 		return err
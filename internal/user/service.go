@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"task_handler/internal/auth"
@@ -14,10 +15,10 @@ type UserService struct {
 }
 
 type UserServiceInterface interface {
-	CreateUser(username, password string) (int, error)
-	LoginUser(username, password, jwtSecret string) (*auth.TokenPair, error)
-	GetUserByID(id int) (*User, error)
-	GetUserByUsername(username string) (*User, error)
+	CreateUser(ctx context.Context, username, password string) (int, error)
+	LoginUser(ctx context.Context, username, password, jwtSecret string) (*auth.TokenPair, error)
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
 }
 
 func NewUserService(repo UserRepositoryInterface, db *sql.DB) UserServiceInterface {
@@ -28,9 +29,9 @@ func NewUserService(repo UserRepositoryInterface, db *sql.DB) UserServiceInterfa
 }
 
 // CreateUser creates a new user with hashed password
-func (s *UserService) CreateUser(username, password string) (int, error) {
+func (s *UserService) CreateUser(ctx context.Context, username, password string) (int, error) {
 	// Get user by username
-	userData, err := s.repo.GetByUsername(s.db, username)
+	userData, err := s.repo.GetByUsername(ctx, s.db, username)
 	if err == nil && userData != nil {
 		return 0, errors.New("username already exists")
 	}
@@ -47,7 +48,7 @@ func (s *UserService) CreateUser(username, password string) (int, error) {
 	}
 
 	// Start transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to begin transaction")
 		return 0, err
@@ -59,7 +60,7 @@ func (s *UserService) CreateUser(username, password string) (int, error) {
 	}()
 
 	// Create user
-	id, err := s.repo.Create(tx, user)
+	id, err := s.repo.Create(ctx, tx, user)
 	if err != nil {
 		return 0, err
 	}
@@ -74,9 +75,9 @@ func (s *UserService) CreateUser(username, password string) (int, error) {
 }
 
 // ValidateCredentials validates username and password, returns user if valid
-func (s *UserService) LoginUser(username, password, jwtSecret string) (*auth.TokenPair, error) {
+func (s *UserService) LoginUser(ctx context.Context, username, password, jwtSecret string) (*auth.TokenPair, error) {
 	// Get user by username
-	user, err := s.repo.GetByUsername(s.db, username)
+	user, err := s.repo.GetByUsername(ctx, s.db, username)
 	if err != nil {
 		return nil, errors.New("invalid credentials")
 	}
@@ -95,11 +96,11 @@ func (s *UserService) LoginUser(username, password, jwtSecret string) (*auth.Tok
 }
 
 // GetUserByID retrieves user by ID
-func (s *UserService) GetUserByID(id int) (*User, error) {
-	return s.repo.GetByID(s.db, id)
+func (s *UserService) GetUserByID(ctx context.Context, id int) (*User, error) {
+	return s.repo.GetByID(ctx, s.db, id)
 }
 
 // GetUserByUsername retrieves user by username
-func (s *UserService) GetUserByUsername(username string) (*User, error) {
-	return s.repo.GetByUsername(s.db, username)
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return s.repo.GetByUsername(ctx, s.db, username)
 }
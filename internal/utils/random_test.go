@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomID(t *testing.T) {
+	id := RandomID()
+	assert.Regexp(t, `^[0-9a-f]{32}$`, id)
+	assert.NotEqual(t, id, RandomID())
+}
+
+func TestRandomUUID(t *testing.T) {
+	id := RandomUUID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	assert.NotEqual(t, id, RandomUUID())
+}
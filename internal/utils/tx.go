@@ -1,19 +1,24 @@
 package utils
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"database/sql"
 
-import "database/sql"
+	"github.com/sirupsen/logrus"
+)
 
-func WithTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
-	tx, err := db.Begin()
+// WithTransaction runs fn in a transaction bound to ctx: if ctx is cancelled
+// before commit, the driver rolls the transaction back
+func WithTransaction(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	logrus.Info("Transaction started")
+	logrus.Debug("Transaction started")
 
 	defer func() {
 		if r := recover(); r != nil {
-			logrus.Info("Panic occurred, rolling back transaction")
+			logrus.Error("Panic occurred, rolling back transaction")
 			_ = tx.Rollback()
 			panic(r)
 		}
@@ -21,12 +26,15 @@ func WithTransaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
 
 	if err := fn(tx); err != nil {
 		_ = tx.Rollback()
-		logrus.Info("Error occurred, rolling back transaction")
+		logrus.Debug("Error occurred, rolling back transaction")
 		return err
 	}
 
-	logrus.Info("Transaction committed successfully")
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logrus.Debug("Transaction committed successfully")
+	return nil
 }
 
 // func StartWorker(conn *amqp.Connection, workerID int) {
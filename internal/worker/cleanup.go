@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"task_handler/internal/config"
+	"task_handler/internal/retention"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"time"
+)
+
+// DefaultRetentionConfig is used until SetRetentionConfig is called
+var DefaultRetentionConfig = config.RetentionConfig{
+	Results:     30 * 24 * time.Hour,
+	Uploads:     24 * time.Hour,
+	OrphanGrace: time.Hour,
+	BatchSize:   100,
+	MaxPerRun:   1000,
+}
+
+var errInvalidCleanupParams = errors.New("invalid cleanup_temp params")
+
+// cleanupParams are the params of a cleanup_temp task. The retention rules
+// themselves are configuration, not params.
+type cleanupParams struct {
+	DryRun bool `json:"dry_run"`
+}
+
+func parseCleanupParams(raw json.RawMessage) (*cleanupParams, error) {
+	var p cleanupParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidCleanupParams, err)
+		}
+	}
+	return &p, nil
+}
+
+// processCleanupTemp enforces the retention policy on the submitting user's
+// data and returns what it removed, or would remove in a dry run
+func (p *Pool) processCleanupTemp(ctx context.Context, payload *task.Envelope, workerID int) (*Result, error) {
+	params, err := parseCleanupParams(payload.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d enforcing retention for user=%d (dry run: %t)", workerID, payload.UserID, params.DryRun)
+
+	cleaner := retention.NewCleaner(retention.NewRetentionRepository(), p.db, p.store, p.retention)
+	cleaner.SetCache(p.cache)
+	cleaner.SetUser(payload.UserID)
+	report, err := cleaner.Run(ctx, params.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d retention done: %d results, %d uploads, %d orphaned objects",
+		workerID, report.Results.Count, report.Uploads.Count, report.Orphans.Count)
+	return &Result{Data: report}, nil
+}
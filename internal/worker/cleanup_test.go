@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCleanupParams(t *testing.T) {
+	p, err := parseCleanupParams(nil)
+	require.NoError(t, err)
+	assert.False(t, p.DryRun)
+
+	p, err = parseCleanupParams(json.RawMessage(`{"dry_run": true}`))
+	require.NoError(t, err)
+	assert.True(t, p.DryRun)
+
+	_, err = parseCleanupParams(json.RawMessage(`{"dry_run": "yes"}`))
+	assert.ErrorIs(t, err, errInvalidCleanupParams)
+}
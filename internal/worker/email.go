@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"task_handler/internal/mail"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"time"
+)
+
+// maxEmailRecipients bounds how many addresses one send_email task may use
+const maxEmailRecipients = 50
+
+var errInvalidEmailParams = errors.New("invalid send_email params")
+
+// recipients is one address or a list of them
+type recipients []string
+
+func (r *recipients) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*r = recipients{one}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return errors.New("to must be a string or a list of strings")
+	}
+	*r = list
+	return nil
+}
+
+// emailParams are the params of a send_email task. Data is passed to the
+// template as is.
+type emailParams struct {
+	To       recipients     `json:"to"`
+	Template string         `json:"template"`
+	Data     map[string]any `json:"data"`
+}
+
+func parseEmailParams(raw json.RawMessage) (*emailParams, error) {
+	var p emailParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidEmailParams, err)
+		}
+	}
+
+	if len(p.To) == 0 {
+		return nil, fmt.Errorf("%w: to is required", errInvalidEmailParams)
+	}
+	if len(p.To) > maxEmailRecipients {
+		return nil, fmt.Errorf("%w: at most %d recipients", errInvalidEmailParams, maxEmailRecipients)
+	}
+
+	if p.Template == "" {
+		return nil, fmt.Errorf("%w: template is required", errInvalidEmailParams)
+	}
+	if p.Data == nil {
+		p.Data = map[string]any{}
+	}
+	return &p, nil
+}
+
+// processSendEmail renders the template and sends it. Temporary delivery
+// failures are retried with backoff; anything else fails the task.
+func processSendEmail(ctx context.Context, payload *task.Envelope, mailer *mail.Mailer, workerID int) (*Result, error) {
+	params, err := parseEmailParams(payload.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d sending %s email to %d recipients for user=%d", workerID, params.Template, len(params.To), payload.UserID)
+
+	msg, err := mailer.Send(ctx, params.To, params.Template, params.Data)
+	if err != nil {
+		if mail.IsPermanent(err) {
+			return nil, err
+		}
+		return nil, retryable(err)
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d email %s sent for user=%d", workerID, msg.ID, payload.UserID)
+	return &Result{Data: map[string]any{
+		"sent_at":    time.Now().UTC(),
+		"message_id": msg.ID,
+		"recipients": len(msg.To),
+	}}, nil
+}
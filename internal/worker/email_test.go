@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"task_handler/internal/mail"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmailParams(t *testing.T) {
+	p, err := parseEmailParams(json.RawMessage(`{"to": "a@example.com", "template": "welcome"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a@example.com"}, []string(p.To))
+	assert.NotNil(t, p.Data)
+
+	p, err = parseEmailParams(json.RawMessage(`{"to": ["a@example.com", "b@example.com"], "template": "welcome", "data": {"name": "A"}}`))
+	require.NoError(t, err)
+	assert.Len(t, p.To, 2)
+	assert.Equal(t, "A", p.Data["name"])
+
+	for _, raw := range []string{
+		``,
+		`{"template": "welcome"}`,
+		`{"to": "a@example.com"}`,
+		`{"to": 42, "template": "welcome"}`,
+		`{"to": [1], "template": "welcome"}`,
+		`{"to": "a@example.com", "template": "welcome", "data": []}`,
+	} {
+		_, err := parseEmailParams(json.RawMessage(raw))
+		assert.ErrorIs(t, err, errInvalidEmailParams, raw)
+	}
+}
+
+// stubSender fails every delivery with err
+type stubSender struct {
+	err error
+}
+
+func (s *stubSender) Send(ctx context.Context, msg *mail.Message) error {
+	return s.err
+}
+
+func TestProcessSendEmail_ErrorClassification(t *testing.T) {
+	templates, err := mail.LoadTemplates("")
+	require.NoError(t, err)
+
+	payload := &task.Envelope{
+		TaskID:   1,
+		UserID:   1,
+		TaskType: "send_email",
+		Params:   json.RawMessage(`{"to": "a@example.com", "template": "welcome", "data": {"name": "A"}}`),
+	}
+	send := func(sendErr error) (*Result, error) {
+		mailer := mail.NewMailer(&stubSender{err: sendErr}, templates, "noreply@example.com")
+		return processSendEmail(context.Background(), payload, mailer, 1)
+	}
+
+	res, err := send(nil)
+	require.NoError(t, err)
+	data := res.Data.(map[string]any)
+	assert.Equal(t, 1, data["recipients"])
+	assert.NotEmpty(t, data["message_id"])
+
+	_, err = send(&mail.SendError{Err: errors.New("451 try later")})
+	assert.True(t, isRetryable(err))
+
+	_, err = send(&mail.SendError{Err: errors.New("550 no such user"), Permanent: true})
+	require.Error(t, err)
+	assert.False(t, isRetryable(err))
+}
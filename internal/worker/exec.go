@@ -0,0 +1,241 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"task_handler/internal/config"
+	"task_handler/internal/sandbox"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"time"
+)
+
+// DefaultExecConfig is used until SetExec is called
+var DefaultExecConfig = config.ExecConfig{
+	WorkDir:     filepath.Join(os.TempDir(), "task-exec"),
+	Timeout:     5 * time.Minute,
+	MaxOutput:   10 << 20,
+	EnvAllow:    []string{"PATH", "LANG", "TZ"},
+	CPUSeconds:  60,
+	MemoryBytes: 1 << 30,
+	OpenFiles:   256,
+}
+
+var errInvalidExecParams = errors.New("invalid exec params")
+
+// execParams name an allowlisted command and the values of its arguments
+type execParams struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args"`
+}
+
+func parseExecParams(raw json.RawMessage) (*execParams, error) {
+	var p execParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidExecParams, err)
+	}
+	if p.Command == "" {
+		return nil, fmt.Errorf("%w: command is required", errInvalidExecParams)
+	}
+	return &p, nil
+}
+
+// processExec runs an allowlisted command in a fresh working directory
+// under the configured rlimits. A non-zero exit still succeeds the task:
+// the exit code is part of the result, next to the captured output.
+func processExec(ctx context.Context, payload *task.Envelope, commands *sandbox.Allowlist, cfg config.ExecConfig, workerID int) (*Result, error) {
+	params, err := parseExecParams(payload.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := commands.Get(params.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	launcher, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cfg.WorkDir, 0o700); err != nil {
+		return nil, err
+	}
+	workDir, err := os.MkdirTemp(cfg.WorkDir, fmt.Sprintf("task-%d-", payload.TaskID))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	argv, err := command.Argv(params.Args, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := newCapture(cfg.WorkDir, cfg.MaxOutput)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := newCapture(cfg.WorkDir, cfg.MaxOutput)
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	discard := func() {
+		stdout.Close()
+		stderr.Close()
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d running command %s for user=%d", workerID, command.Name(), payload.UserID)
+
+	runCtx, cancel := context.WithTimeout(ctx, command.TimeoutOr(cfg.Timeout))
+	defer cancel()
+
+	limits := sandbox.Limits{CPUSeconds: cfg.CPUSeconds, MemoryBytes: cfg.MemoryBytes, OpenFiles: cfg.OpenFiles}
+	cmd := sandbox.CommandContext(runCtx, launcher, limits, command.Path, argv...)
+	cmd.Dir = workDir
+	cmd.Env = execEnv(cfg.EnvAllow, command.Env, payload.TaskID, workDir)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	sandbox.KillGroup(cmd)
+	duration := time.Since(start)
+
+	// The task was aborted rather than the command timing out, so it is
+	// requeued like any other interrupted task
+	if ctx.Err() != nil {
+		discard()
+		return nil, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		discard()
+		return nil, err
+	}
+
+	state := cmd.ProcessState
+	timedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded)
+	tasklog.FromContext(ctx).Infof("Worker %d command %s exited with %d after %s for user=%d (timed out: %t)",
+		workerID, command.Name(), state.ExitCode(), duration.Round(time.Millisecond), payload.UserID, timedOut)
+
+	data := map[string]any{
+		"command":          command.Name(),
+		"exit_code":        state.ExitCode(),
+		"timed_out":        timedOut,
+		"duration_seconds": duration.Seconds(),
+		"stdout_bytes":     stdout.written,
+		"stderr_bytes":     stderr.written,
+		"stdout_truncated": stdout.truncated,
+		"stderr_truncated": stderr.truncated,
+	}
+	if sig := sandbox.Signal(state); sig != "" {
+		data["signal"] = sig
+	}
+
+	files := make([]File, 0, 2)
+	for _, out := range []struct {
+		name string
+		c    *capture
+	}{{"stdout.txt", stdout}, {"stderr.txt", stderr}} {
+		if err := out.c.rewind(); err != nil {
+			discard()
+			return nil, err
+		}
+		files = append(files, File{
+			Name:        out.name,
+			ContentType: "text/plain; charset=utf-8",
+			Body:        out.c,
+			Size:        out.c.stored,
+		})
+	}
+	return &Result{Data: data, Files: files}, nil
+}
+
+// execEnv passes on the allowed variables of the worker's environment,
+// then the command's own, then the task's
+func execEnv(allow []string, extra map[string]string, taskID int, workDir string) []string {
+	env := make([]string, 0, len(allow)+len(extra)+4)
+	for _, name := range allow {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+extra[name])
+	}
+
+	return append(env,
+		"TASK_ID="+strconv.Itoa(taskID),
+		"TASK_WORKDIR="+workDir,
+		"HOME="+workDir,
+		"TMPDIR="+workDir,
+	)
+}
+
+// capture keeps the first limit bytes written to it in a temporary file
+// and counts the rest. Closing it removes the file. The file is not
+// embedded: its ReadFrom would let io.Copy bypass the limit.
+type capture struct {
+	file      *os.File
+	limit     int64
+	stored    int64
+	written   int64
+	truncated bool
+}
+
+func newCapture(dir string, limit int64) (*capture, error) {
+	f, err := os.CreateTemp(dir, ".output-*")
+	if err != nil {
+		return nil, err
+	}
+	return &capture{file: f, limit: limit}, nil
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.written += int64(len(p))
+	keep := p
+	if room := c.limit - c.stored; int64(len(keep)) > room {
+		keep = keep[:max(room, 0)]
+		c.truncated = true
+	}
+	if len(keep) > 0 {
+		n, err := c.file.Write(keep)
+		c.stored += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	// The command must not see a short write for the discarded part
+	return len(p), nil
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	return c.file.Read(p)
+}
+
+func (c *capture) rewind() error {
+	_, err := c.file.Seek(0, 0)
+	return err
+}
+
+func (c *capture) Close() error {
+	err := c.file.Close()
+	os.Remove(c.file.Name())
+	return err
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+	"task_handler/internal/sandbox"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the exec launcher
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == sandbox.Subcommand {
+		os.Exit(sandbox.Main(os.Args[2:]))
+	}
+	os.Exit(m.Run())
+}
+
+func testExec(t *testing.T) (*sandbox.Allowlist, config.ExecConfig) {
+	commands, err := sandbox.ParseAllowlist([]byte(`{"commands": {
+		"echo": {
+			"path": "/bin/sh",
+			"args": ["-c", "echo \"$1\"; pwd; echo \"$TASK_ID $SECRET $GREETING\"; echo err >&2; exit 3", "sh", "{{.msg}}"],
+			"params": {"msg": "[a-z ]+"},
+			"env": {"GREETING": "hi"}
+		},
+		"spam": {
+			"path": "/bin/sh",
+			"args": ["-c", "yes | head -c 100000"]
+		},
+		"hang": {
+			"path": "/bin/sh",
+			"args": ["-c", "sleep 30"],
+			"timeout": "200ms"
+		}
+	}}`))
+	require.NoError(t, err)
+
+	cfg := DefaultExecConfig
+	cfg.WorkDir = t.TempDir()
+	cfg.MaxOutput = 1000
+	return commands, cfg
+}
+
+func runExec(t *testing.T, ctx context.Context, params string) (*Result, error) {
+	commands, cfg := testExec(t)
+	payload := &task.Envelope{TaskID: 7, UserID: 1, TaskType: "exec", Params: json.RawMessage(params)}
+	return processExec(ctx, payload, commands, cfg, 1)
+}
+
+func readFile(t *testing.T, f File) string {
+	data, err := io.ReadAll(f.Body)
+	require.NoError(t, err)
+	require.NoError(t, f.Body.(io.Closer).Close())
+	return string(data)
+}
+
+func TestProcessExec(t *testing.T) {
+	t.Setenv("SECRET", "leaked")
+
+	res, err := runExec(t, context.Background(), `{"command": "echo", "args": {"msg": "hello world"}}`)
+	require.NoError(t, err)
+
+	data := res.Data.(map[string]any)
+	assert.Equal(t, 3, data["exit_code"])
+	assert.Equal(t, false, data["timed_out"])
+	assert.NotContains(t, data, "signal")
+
+	require.Len(t, res.Files, 2)
+	assert.Equal(t, "stdout.txt", res.Files[0].Name)
+	stdout := strings.Split(readFile(t, res.Files[0]), "\n")
+	assert.Equal(t, "hello world", stdout[0])
+	assert.Contains(t, stdout[1], "task-7-")
+	assert.Equal(t, "7  hi", stdout[2], "SECRET must not be passed on")
+	assert.Equal(t, "err\n", readFile(t, res.Files[1]))
+
+	// The working directory and the captured output are gone
+	entries, err := os.ReadDir(strings.TrimSuffix(stdout[1], "/"+lastElem(stdout[1])))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func lastElem(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func TestProcessExec_TruncatesOutput(t *testing.T) {
+	res, err := runExec(t, context.Background(), `{"command": "spam"}`)
+	require.NoError(t, err)
+
+	data := res.Data.(map[string]any)
+	assert.Equal(t, 0, data["exit_code"])
+	assert.Equal(t, int64(100000), data["stdout_bytes"])
+	assert.Equal(t, true, data["stdout_truncated"])
+	assert.Equal(t, int64(1000), res.Files[0].Size)
+	assert.Len(t, readFile(t, res.Files[0]), 1000)
+	readFile(t, res.Files[1])
+}
+
+func TestProcessExec_Timeout(t *testing.T) {
+	res, err := runExec(t, context.Background(), `{"command": "hang"}`)
+	require.NoError(t, err)
+
+	data := res.Data.(map[string]any)
+	assert.Equal(t, true, data["timed_out"])
+	assert.Equal(t, "killed", data["signal"])
+	for _, f := range res.Files {
+		readFile(t, f)
+	}
+}
+
+func TestProcessExec_Aborted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Shutdown aborts the task instead of recording a result for it
+	_, err := runExec(t, ctx, `{"command": "hang"}`)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestProcessExec_Rejected(t *testing.T) {
+	for params, want := range map[string]error{
+		`{}`:                errInvalidExecParams,
+		`{"command": "rm"}`: sandbox.ErrUnknownCommand,
+		`{"command": "echo", "args": {"msg": "$(id)"}}`: sandbox.ErrInvalidArgs,
+	} {
+		_, err := runExec(t, context.Background(), params)
+		assert.ErrorIs(t, err, want, params)
+	}
+}
@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"task_handler/internal/outbound"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"time"
+	"unicode/utf8"
+)
+
+var errInvalidHTTPParams = errors.New("invalid http_request params")
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// headerName is an RFC 9110 token
+var headerName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// reservedHeaders are set by the client itself
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// httpRequestParams describe one call. Body is sent as is when it is a
+// JSON string and encoded as JSON otherwise.
+type httpRequestParams struct {
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Body           json.RawMessage   `json:"body"`
+	ExpectedStatus []int             `json:"expected_status"`
+	Timeout        string            `json:"timeout"`
+}
+
+func parseHTTPRequestParams(raw json.RawMessage) (*outbound.Request, error) {
+	var p httpRequestParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidHTTPParams, err)
+	}
+
+	req := &outbound.Request{
+		Method: strings.ToUpper(p.Method),
+		URL:    p.URL,
+		Header: make(http.Header, len(p.Headers)),
+		Expect: p.ExpectedStatus,
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if !slices.Contains(httpMethods, req.Method) {
+		return nil, fmt.Errorf("%w: unsupported method %q", errInvalidHTTPParams, p.Method)
+	}
+	if p.URL == "" {
+		return nil, fmt.Errorf("%w: url is required", errInvalidHTTPParams)
+	}
+
+	for name, value := range p.Headers {
+		if !headerName.MatchString(name) || strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("%w: invalid header %q", errInvalidHTTPParams, name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if reservedHeaders[name] {
+			return nil, fmt.Errorf("%w: header %s cannot be set", errInvalidHTTPParams, name)
+		}
+		req.Header.Set(name, value)
+	}
+
+	if len(p.Body) > 0 && string(p.Body) != "null" {
+		var text string
+		if err := json.Unmarshal(p.Body, &text); err == nil {
+			req.Body = []byte(text)
+		} else {
+			req.Body = p.Body
+			if req.Header.Get("Content-Type") == "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+		}
+	}
+
+	for _, status := range p.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return nil, fmt.Errorf("%w: invalid expected status %d", errInvalidHTTPParams, status)
+		}
+	}
+
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: invalid timeout %q", errInvalidHTTPParams, p.Timeout)
+		}
+		req.Timeout = d
+	}
+	return req, nil
+}
+
+// processHTTPRequest calls an allowlisted endpoint. Network errors,
+// timeouts and 5xx responses are retried with backoff; any other
+// unexpected status fails the task.
+func processHTTPRequest(ctx context.Context, payload *task.Envelope, client *outbound.Client, workerID int) (*Result, error) {
+	req, err := parseHTTPRequestParams(payload.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d calling %s %s for user=%d", workerID, req.Method, req.URL, payload.UserID)
+
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil || outbound.IsPermanent(err) {
+			return nil, err
+		}
+		return nil, retryable(err)
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d %s %s answered %d in %s for user=%d",
+		workerID, req.Method, req.URL, resp.Status, resp.Duration.Round(time.Millisecond), payload.UserID)
+
+	data := map[string]any{
+		"status":           resp.Status,
+		"url":              resp.URL,
+		"headers":          resp.Header,
+		"body_truncated":   resp.Truncated,
+		"duration_seconds": resp.Duration.Seconds(),
+	}
+	if body, ok := responseText(resp); ok {
+		data["body"] = body
+	} else {
+		data["body"] = base64.StdEncoding.EncodeToString(resp.Body)
+		data["body_encoding"] = "base64"
+	}
+	return &Result{Data: data}, nil
+}
+
+// responseText returns the body as text when it is UTF-8, ignoring a
+// character cut in half by truncation
+func responseText(resp *outbound.Response) (string, bool) {
+	body := resp.Body
+	if resp.Truncated {
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+	if !utf8.Valid(body) {
+		return "", false
+	}
+	return string(body), true
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/config"
+	"task_handler/internal/outbound"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPRequestParams(t *testing.T) {
+	req, err := parseHTTPRequestParams(json.RawMessage(`{"url": "https://api.internal/x", "body": {"a": 1}, "headers": {"x-token": "t"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "GET", req.Method)
+	assert.Equal(t, `{"a": 1}`, string(req.Body))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "t", req.Header.Get("X-Token"))
+
+	req, err = parseHTTPRequestParams(json.RawMessage(`{"method": "put", "url": "https://api.internal/x", "body": "a=1", "headers": {"Content-Type": "application/x-www-form-urlencoded"}, "expected_status": [204], "timeout": "5s"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "a=1", string(req.Body))
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+	assert.Equal(t, []int{204}, req.Expect)
+	assert.Equal(t, 5*time.Second, req.Timeout)
+
+	for _, raw := range []string{
+		`{}`,
+		`{"url": "https://a/", "method": "CONNECT"}`,
+		`{"url": "https://a/", "headers": {"Host": "b"}}`,
+		`{"url": "https://a/", "headers": {"X-A": "1\r\nX-B: 2"}}`,
+		`{"url": "https://a/", "headers": {"bad name": "1"}}`,
+		`{"url": "https://a/", "expected_status": [99]}`,
+		`{"url": "https://a/", "timeout": "-1s"}`,
+	} {
+		_, err := parseHTTPRequestParams(json.RawMessage(raw))
+		assert.ErrorIs(t, err, errInvalidHTTPParams, raw)
+	}
+}
+
+func TestProcessHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("done"))
+		case "/binary":
+			_, _ = w.Write([]byte{0xff, 0xfe})
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := outbound.NewClient(config.OutboundConfig{
+		AllowedHosts: []string{strings.TrimPrefix(srv.URL, "http://")},
+		Timeout:      5 * time.Second,
+		MaxRequest:   1024,
+		MaxResponse:  1024,
+	})
+	require.NoError(t, err)
+
+	call := func(path string) (*Result, error) {
+		payload := &task.Envelope{TaskID: 1, UserID: 1, TaskType: "http_request", Params: json.RawMessage(`{"url": "` + srv.URL + path + `"}`)}
+		return processHTTPRequest(context.Background(), payload, client, 1)
+	}
+
+	res, err := call("/ok")
+	require.NoError(t, err)
+	data := res.Data.(map[string]any)
+	assert.Equal(t, 200, data["status"])
+	assert.Equal(t, "done", data["body"])
+	assert.NotContains(t, data, "body_encoding")
+
+	res, err = call("/binary")
+	require.NoError(t, err)
+	data = res.Data.(map[string]any)
+	assert.Equal(t, "//4=", data["body"])
+	assert.Equal(t, "base64", data["body_encoding"])
+
+	_, err = call("/down")
+	assert.True(t, isRetryable(err))
+
+	_, err = call("/missing")
+	require.Error(t, err)
+	assert.False(t, isRetryable(err))
+
+	payload := &task.Envelope{TaskID: 1, UserID: 1, TaskType: "http_request", Params: json.RawMessage(`{"url": "http://169.254.169.254/"}`)}
+	_, err = processHTTPRequest(context.Background(), payload, client, 1)
+	assert.ErrorIs(t, err, outbound.ErrHostNotAllowed)
+	assert.False(t, isRetryable(err))
+}
+
+func TestResponseText(t *testing.T) {
+	// "é" cut after its first byte by truncation
+	text, ok := responseText(&outbound.Response{Body: []byte("caf\xc3"), Truncated: true})
+	assert.True(t, ok)
+	assert.Equal(t, "caf", text)
+
+	_, ok = responseText(&outbound.Response{Body: []byte("caf\xc3")})
+	assert.False(t, ok)
+}
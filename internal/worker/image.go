@@ -0,0 +1,262 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+	"task_handler/internal/config"
+
+	"golang.org/x/image/draw"
+)
+
+// maxImageSizes bounds how many outputs one resize_image task may ask for
+const maxImageSizes = 10
+
+// DefaultImageConfig is used until SetImageConfig is called
+var DefaultImageConfig = config.ImageConfig{
+	MaxPixels:    50_000_000,
+	MaxDimension: 4096,
+	JPEGQuality:  85,
+}
+
+var (
+	errInvalidImageParams = errors.New("invalid resize_image params")
+	errImageTooLarge      = errors.New("image exceeds the pixel limit")
+)
+
+// sizeName is an output name before its extension is added
+var sizeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// imageSize is one requested output. Mode "fit" (the default) scales the
+// image down to fit inside the box, keeping the aspect ratio; "fill" scales
+// it to cover the box and crops the overflow from the centre.
+type imageSize struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Mode   string `json:"mode"`
+}
+
+// resizeParams are the params of a resize_image task. Either the top-level
+// size or a list of sizes is given, not both.
+type resizeParams struct {
+	UploadID string `json:"upload_id"`
+	imageSize
+	Sizes   []imageSize `json:"sizes"`
+	Format  string      `json:"format"`  // jpeg, png or gif; defaults to the source format
+	Quality int         `json:"quality"` // JPEG quality, 1-100
+}
+
+func parseResizeParams(raw json.RawMessage, cfg config.ImageConfig) (*resizeParams, error) {
+	var p resizeParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidImageParams, err)
+		}
+	}
+
+	if p.UploadID == "" {
+		return nil, fmt.Errorf("%w: upload_id is required", errInvalidImageParams)
+	}
+
+	if len(p.Sizes) == 0 {
+		if p.imageSize.Name == "" {
+			p.imageSize.Name = "resized"
+		}
+		p.Sizes = []imageSize{p.imageSize}
+	} else if p.imageSize != (imageSize{}) {
+		return nil, fmt.Errorf("%w: give either sizes or a single width/height", errInvalidImageParams)
+	}
+	if len(p.Sizes) > maxImageSizes {
+		return nil, fmt.Errorf("%w: at most %d sizes", errInvalidImageParams, maxImageSizes)
+	}
+
+	seen := make(map[string]bool)
+	for i := range p.Sizes {
+		s := &p.Sizes[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("%dx%d", s.Width, s.Height)
+		}
+		if !sizeName.MatchString(s.Name) || seen[s.Name] {
+			return nil, fmt.Errorf("%w: bad or duplicate size name %q", errInvalidImageParams, s.Name)
+		}
+		seen[s.Name] = true
+
+		if s.Mode == "" {
+			s.Mode = "fit"
+		}
+		if s.Mode != "fit" && s.Mode != "fill" {
+			return nil, fmt.Errorf("%w: mode must be fit or fill", errInvalidImageParams)
+		}
+		if s.Width < 0 || s.Height < 0 || s.Width > cfg.MaxDimension || s.Height > cfg.MaxDimension {
+			return nil, fmt.Errorf("%w: width and height must be between 0 and %d", errInvalidImageParams, cfg.MaxDimension)
+		}
+		if s.Width == 0 && s.Height == 0 {
+			return nil, fmt.Errorf("%w: size %q needs a width or a height", errInvalidImageParams, s.Name)
+		}
+		if s.Mode == "fill" && (s.Width == 0 || s.Height == 0) {
+			return nil, fmt.Errorf("%w: fill needs both width and height", errInvalidImageParams)
+		}
+	}
+
+	switch p.Format {
+	case "", "jpeg", "png", "gif":
+	case "jpg":
+		p.Format = "jpeg"
+	default:
+		return nil, fmt.Errorf("%w: format must be jpeg, png or gif", errInvalidImageParams)
+	}
+
+	if p.Quality == 0 {
+		p.Quality = cfg.JPEGQuality
+	}
+	if p.Quality < 1 || p.Quality > 100 {
+		return nil, fmt.Errorf("%w: quality must be between 1 and 100", errInvalidImageParams)
+	}
+
+	return &p, nil
+}
+
+// resizeImage decodes the source, checking its pixel count before the
+// pixels are allocated, and encodes one output file per requested size
+func resizeImage(ctx context.Context, r io.Reader, p *resizeParams, cfg config.ImageConfig) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	header, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported or corrupt image: %w", err)
+	}
+	if header.Width <= 0 || header.Height <= 0 {
+		return nil, fmt.Errorf("unsupported or corrupt image: empty %s", format)
+	}
+	if int64(header.Width)*int64(header.Height) > int64(cfg.MaxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d is more than %d pixels", errImageTooLarge, header.Width, header.Height, cfg.MaxPixels)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported or corrupt image: %w", err)
+	}
+
+	outFormat := p.Format
+	if outFormat == "" {
+		outFormat = format
+	}
+
+	outputs := make([]map[string]any, 0, len(p.Sizes))
+	files := make([]File, 0, len(p.Sizes))
+	for _, size := range p.Sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		dst := scaleImage(src, size, outFormat == "jpeg")
+
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, dst, outFormat, p.Quality); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", size.Name, err)
+		}
+
+		name := size.Name + "." + imageExt(outFormat)
+		outputs = append(outputs, map[string]any{
+			"name":   name,
+			"width":  dst.Bounds().Dx(),
+			"height": dst.Bounds().Dy(),
+			"size":   buf.Len(),
+		})
+		files = append(files, File{
+			Name:        name,
+			ContentType: "image/" + outFormat,
+			Body:        bytes.NewReader(buf.Bytes()),
+			Size:        int64(buf.Len()),
+		})
+	}
+
+	return &Result{
+		Data: map[string]any{
+			"source": map[string]any{
+				"upload_id": p.UploadID,
+				"format":    format,
+				"width":     header.Width,
+				"height":    header.Height,
+			},
+			"format":  outFormat,
+			"outputs": outputs,
+		},
+		Files: files,
+	}, nil
+}
+
+// scaleImage resamples src into size with a Catmull-Rom filter. opaque
+// flattens transparency onto white, for formats without an alpha channel.
+func scaleImage(src image.Image, size imageSize, opaque bool) *image.RGBA {
+	bounds := src.Bounds()
+	sw, sh := float64(bounds.Dx()), float64(bounds.Dy())
+	crop := bounds
+
+	var dw, dh int
+	switch size.Mode {
+	case "fill":
+		scale := math.Max(float64(size.Width)/sw, float64(size.Height)/sh)
+		cw := int(math.Round(float64(size.Width) / scale))
+		ch := int(math.Round(float64(size.Height) / scale))
+		x := bounds.Min.X + (bounds.Dx()-cw)/2
+		y := bounds.Min.Y + (bounds.Dy()-ch)/2
+		crop = image.Rect(x, y, x+cw, y+ch).Intersect(bounds)
+		dw, dh = size.Width, size.Height
+	default:
+		scale := math.Inf(1)
+		if size.Width > 0 {
+			scale = float64(size.Width) / sw
+		}
+		if size.Height > 0 {
+			scale = math.Min(scale, float64(size.Height)/sh)
+		}
+		// Fitting never enlarges the image
+		scale = math.Min(scale, 1)
+		dw = max(1, int(math.Round(sw*scale)))
+		dh = max(1, int(math.Round(sh*scale)))
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	op := draw.Src
+	if opaque {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, op, nil)
+	return dst
+}
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func imageExt(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"task_handler/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testImageConfig = config.ImageConfig{MaxPixels: 1_000_000, MaxDimension: 2048, JPEGQuality: 85}
+
+// testPNG encodes a w x h gradient
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func mustResizeParams(t *testing.T, raw string) *resizeParams {
+	p, err := parseResizeParams(json.RawMessage(raw), testImageConfig)
+	require.NoError(t, err)
+	return p
+}
+
+func decodeFile(t *testing.T, f File) (image.Image, string) {
+	body, err := io.ReadAll(f.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), f.Size)
+	img, format, err := image.Decode(bytes.NewReader(body))
+	require.NoError(t, err)
+	return img, format
+}
+
+func TestResizeImage_Fit(t *testing.T) {
+	p := mustResizeParams(t, `{"upload_id": "u1", "width": 100, "height": 100}`)
+
+	res, err := resizeImage(context.Background(), bytes.NewReader(testPNG(t, 400, 200)), p, testImageConfig)
+	require.NoError(t, err)
+	require.Len(t, res.Files, 1)
+
+	f := res.Files[0]
+	assert.Equal(t, "resized.png", f.Name)
+	assert.Equal(t, "image/png", f.ContentType)
+
+	img, format := decodeFile(t, f)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds(), "aspect ratio kept")
+}
+
+func TestResizeImage_FitNeverEnlarges(t *testing.T) {
+	p := mustResizeParams(t, `{"upload_id": "u1", "width": 1000}`)
+
+	res, err := resizeImage(context.Background(), bytes.NewReader(testPNG(t, 40, 20)), p, testImageConfig)
+	require.NoError(t, err)
+
+	img, _ := decodeFile(t, res.Files[0])
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+}
+
+func TestResizeImage_MultipleSizesAndFormat(t *testing.T) {
+	p := mustResizeParams(t, `{
+		"upload_id": "u1",
+		"format": "jpg",
+		"quality": 70,
+		"sizes": [
+			{"name": "thumb", "width": 64, "height": 64, "mode": "fill"},
+			{"name": "medium", "height": 100}
+		]
+	}`)
+
+	res, err := resizeImage(context.Background(), bytes.NewReader(testPNG(t, 400, 200)), p, testImageConfig)
+	require.NoError(t, err)
+	require.Len(t, res.Files, 2)
+
+	thumb, format := decodeFile(t, res.Files[0])
+	assert.Equal(t, "thumb.jpg", res.Files[0].Name)
+	assert.Equal(t, "image/jpeg", res.Files[0].ContentType)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), thumb.Bounds(), "filled and cropped")
+
+	medium, _ := decodeFile(t, res.Files[1])
+	assert.Equal(t, "medium.jpg", res.Files[1].Name)
+	assert.Equal(t, image.Rect(0, 0, 200, 100), medium.Bounds())
+
+	data := res.Data.(map[string]any)
+	assert.Equal(t, "jpeg", data["format"])
+	assert.Equal(t, 400, data["source"].(map[string]any)["width"])
+}
+
+func TestResizeImage_Quality(t *testing.T) {
+	src := testPNG(t, 300, 300)
+	size := func(quality int) int64 {
+		p := mustResizeParams(t, `{"upload_id": "u1", "width": 300, "format": "jpeg"}`)
+		p.Quality = quality
+		res, err := resizeImage(context.Background(), bytes.NewReader(src), p, testImageConfig)
+		require.NoError(t, err)
+		return res.Files[0].Size
+	}
+
+	assert.Less(t, size(10), size(95))
+}
+
+func TestResizeImage_RejectsDecompressionBomb(t *testing.T) {
+	// A tiny file whose header claims a huge image
+	img := image.NewGray(image.Rect(0, 0, 2000, 1000))
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	p := mustResizeParams(t, `{"upload_id": "u1", "width": 10}`)
+	_, err := resizeImage(context.Background(), &buf, p, testImageConfig)
+	assert.ErrorIs(t, err, errImageTooLarge)
+}
+
+func TestResizeImage_NotAnImage(t *testing.T) {
+	p := mustResizeParams(t, `{"upload_id": "u1", "width": 10}`)
+	_, err := resizeImage(context.Background(), bytes.NewReader([]byte("hello")), p, testImageConfig)
+	assert.Error(t, err)
+}
+
+func TestParseResizeParams_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		`{}`,
+		`{"upload_id": "u1"}`,
+		`{"upload_id": "u1", "width": -1}`,
+		`{"upload_id": "u1", "width": 5000}`,
+		`{"upload_id": "u1", "width": 10, "mode": "stretch"}`,
+		`{"upload_id": "u1", "width": 10, "mode": "fill"}`,
+		`{"upload_id": "u1", "width": 10, "format": "bmp"}`,
+		`{"upload_id": "u1", "width": 10, "quality": 101}`,
+		`{"upload_id": "u1", "width": 10, "sizes": [{"width": 5}]}`,
+		`{"upload_id": "u1", "sizes": [{"name": "a", "width": 5}, {"name": "a", "width": 6}]}`,
+		`{"upload_id": "u1", "sizes": [{"name": "../x", "width": 5}]}`,
+	} {
+		_, err := parseResizeParams(json.RawMessage(raw), testImageConfig)
+		assert.ErrorIs(t, err, errInvalidImageParams, raw)
+	}
+}
+
+func TestParseResizeParams_Defaults(t *testing.T) {
+	p := mustResizeParams(t, `{"upload_id": "u1", "sizes": [{"width": 32, "height": 16}]}`)
+
+	assert.Equal(t, "32x16", p.Sizes[0].Name)
+	assert.Equal(t, "fit", p.Sizes[0].Mode)
+	assert.Equal(t, 85, p.Quality)
+}
@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"context"
+	"task_handler/internal/artifact"
+	"task_handler/internal/task"
+	"task_handler/internal/upload"
+)
+
+// Inputs gives a handler the files its task's params refer to. Uploads are
+// opened as the task's owner, so a task cannot read another user's files.
+type Inputs struct {
+	uploads upload.UploadServiceInterface
+	userID  int
+}
+
+func (p *Pool) inputsFor(envelope *task.Envelope) *Inputs {
+	return &Inputs{uploads: p.uploads, userID: envelope.UserID}
+}
+
+// Open returns the upload with its content. The caller must close the object.
+func (in *Inputs) Open(ctx context.Context, uploadID string) (*upload.Upload, *artifact.Object, error) {
+	return in.uploads.Open(ctx, in.userID, uploadID)
+}
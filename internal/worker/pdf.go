@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry of generated PDFs, in points. Text is set in Courier so
+// report columns line up.
+const (
+	pdfPageWidth    = 595 // A4
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+	pdfFontSize     = 8
+	pdfLeading      = 10
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	pdfLineChars    = 100 // What fits between the margins at pdfFontSize
+)
+
+// Objects written before the first page; the page tree is written last
+const (
+	pdfCatalogObj = 1
+	pdfPagesObj   = 2
+	pdfFontObj    = 3
+)
+
+// pdfWriter writes a text-only PDF in a single pass. Only the page being
+// filled is buffered, so documents of any length stream in constant memory.
+type pdfWriter struct {
+	w       io.Writer
+	n       int64   // Bytes written so far
+	offsets []int64 // offsets[i] is where object i starts
+	pages   []int   // Object numbers of the finished pages
+	page    bytes.Buffer
+	lines   int
+	err     error
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	pw := &pdfWriter{w: w, offsets: make([]int64, pdfFontObj+1)}
+	pw.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pw.object(pdfCatalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObj))
+	pw.object(pdfFontObj, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	return pw
+}
+
+// Line adds one line of text, starting a new page when the current one is
+// full. Lines longer than pdfLineChars are cut.
+func (pw *pdfWriter) Line(text string) error {
+	if pw.lines == pdfLinesPerPage {
+		pw.flushPage()
+	}
+	if pw.lines == 0 {
+		fmt.Fprintf(&pw.page, "BT /F1 %d Tf %d TL %d %d Td\n",
+			pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+	}
+
+	pw.page.WriteByte('(')
+	pw.page.WriteString(pdfEscape(text))
+	pw.page.WriteString(") Tj T*\n")
+	pw.lines++
+	return pw.err
+}
+
+// Close writes the last page, the page tree and the cross-reference table
+func (pw *pdfWriter) Close() error {
+	if pw.lines > 0 || len(pw.pages) == 0 {
+		pw.flushPage()
+	}
+
+	kids := make([]string, len(pw.pages))
+	for i, id := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	pw.object(pdfPagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(pw.pages)))
+
+	xref := pw.n
+	pw.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)))
+	for _, offset := range pw.offsets[1:] {
+		pw.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	pw.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets), pdfCatalogObj, xref))
+	return pw.err
+}
+
+func (pw *pdfWriter) flushPage() {
+	if pw.lines > 0 {
+		pw.page.WriteString("ET")
+	}
+	content := pw.newObject()
+	pw.object(content, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", pw.page.Len(), pw.page.String()))
+
+	page := pw.newObject()
+	pw.object(page, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObj, pdfPageWidth, pdfPageHeight, pdfFontObj, content))
+	pw.pages = append(pw.pages, page)
+
+	pw.page.Reset()
+	pw.lines = 0
+}
+
+// newObject reserves the next object number
+func (pw *pdfWriter) newObject() int {
+	pw.offsets = append(pw.offsets, 0)
+	return len(pw.offsets) - 1
+}
+
+func (pw *pdfWriter) object(id int, body string) {
+	pw.offsets[id] = pw.n
+	pw.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", id, body))
+}
+
+func (pw *pdfWriter) write(s string) {
+	if pw.err != nil {
+		return
+	}
+	n, err := io.WriteString(pw.w, s)
+	pw.n += int64(n)
+	pw.err = err
+}
+
+// pdfEscape makes text safe inside a PDF string. Latin-1 characters map
+// onto WinAnsi; anything else becomes '?'.
+func pdfEscape(text string) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range text {
+		if count == pdfLineChars {
+			break
+		}
+		count++
+
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
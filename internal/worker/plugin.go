@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"task_handler/internal/plugin"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+)
+
+// processPlugin hands the task's params to the plugin registered for its
+// type and stores the plugin's JSON output as the result. A plugin exiting
+// with plugin.TempFailCode is retried with backoff.
+func processPlugin(ctx context.Context, payload *task.Envelope, plugins *plugin.Registry, workerID int) (*Result, error) {
+	input := []byte(payload.Params)
+	if len(input) == 0 {
+		input = []byte("{}")
+	}
+	env := map[string]string{
+		"TASK_ID":   strconv.Itoa(payload.TaskID),
+		"TASK_TYPE": payload.TaskType,
+		"USER_ID":   strconv.Itoa(payload.UserID),
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d running plugin %s for user=%d", workerID, payload.TaskType, payload.UserID)
+
+	out, err := plugins.Run(ctx, payload.TaskType, input, env)
+	if err != nil {
+		var exitErr *plugin.ExitError
+		if errors.As(err, &exitErr) && exitErr.Temporary() {
+			return nil, retryable(err)
+		}
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d plugin %s done for user=%d", workerID, payload.TaskType, payload.UserID)
+	return &Result{Data: out}, nil
+}
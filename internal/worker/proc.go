@@ -1,60 +1,74 @@
 package worker
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"task_handler/internal/config"
 	"task_handler/internal/task"
-	"time"
-
-	"github.com/sirupsen/logrus"
+	"task_handler/internal/tasklog"
 )
 
-func handleTask(payload *task.TaskPayload, workerID int) error {
+// Result is what a handler produces for a successful task. Data is stored
+// on the task when its JSON fits the pool's inline limit, otherwise it is
+// stored as a result.json artifact. Files are always stored as artifacts.
+type Result struct {
+	Data  any
+	Files []File
+}
+
+// File is one output file of a task
+type File struct {
+	Name        string // Must satisfy artifact.ValidName
+	ContentType string
+	Body        io.Reader
+	Size        int64 // -1 when unknown
+}
+
+// TaskTypes are the built-in task types, which plugins cannot replace
+var TaskTypes = []string{"send_email", "generate_report", "resize_image", "cleanup_temp", "http_request", "exec"}
+
+func (p *Pool) handleTask(ctx context.Context, payload *task.Envelope, workerID int) (*Result, error) {
 	switch payload.TaskType {
 	case "send_email":
-		return processSendEmail(payload, workerID)
+		return processSendEmail(ctx, payload, p.mailer, workerID)
 	case "generate_report":
-		return processGenerateReport(payload, workerID)
+		return p.processGenerateReport(ctx, payload, workerID)
 	case "resize_image":
-		return processResizeImage(payload, workerID)
+		return processResizeImage(ctx, payload, p.inputsFor(payload), p.images, workerID)
 	case "cleanup_temp":
-		return processCleanupTemp(payload, workerID)
+		return p.processCleanupTemp(ctx, payload, workerID)
+	case "http_request":
+		return processHTTPRequest(ctx, payload, p.outbound, workerID)
+	case "exec":
+		return processExec(ctx, payload, p.commands, p.exec, workerID)
 	default:
-		return fmt.Errorf("unknown task type: %s", payload.TaskType)
+		if p.plugins != nil && p.plugins.Has(payload.TaskType) {
+			return processPlugin(ctx, payload, p.plugins, workerID)
+		}
+		return nil, fmt.Errorf("unknown task type: %s", payload.TaskType)
 	}
 }
 
-func processSendEmail(payload *task.TaskPayload, workerID int) error {
-	logrus.Infof("Worker %d sending email to user=%d", workerID, payload.UserID)
-
-	time.Sleep(500 * time.Millisecond) // simulasi kirim email
-
-	logrus.Infof("Worker %d email sent to user=%d", workerID, payload.UserID)
-	return nil
-}
-
-func processGenerateReport(payload *task.TaskPayload, workerID int) error {
-	logrus.Infof("Worker %d generating report for user=%d", workerID, payload.UserID)
-
-	time.Sleep(5 * time.Second) // simulasi query + processing berat
-
-	logrus.Infof("Worker %d report generated for user=%d", workerID, payload.UserID)
-	return nil
-}
-
-func processResizeImage(payload *task.TaskPayload, workerID int) error {
-	logrus.Infof("Worker %d resizing image for user=%d", workerID, payload.UserID)
-
-	time.Sleep(2 * time.Second) // simulasi CPU-bound task
+func processResizeImage(ctx context.Context, payload *task.Envelope, in *Inputs, cfg config.ImageConfig, workerID int) (*Result, error) {
+	params, err := parseResizeParams(payload.Params, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	logrus.Infof("Worker %d image resized for user=%d", workerID, payload.UserID)
-	return nil
-}
+	tasklog.FromContext(ctx).Infof("Worker %d resizing image %s for user=%d", workerID, params.UploadID, payload.UserID)
 
-func processCleanupTemp(payload *task.TaskPayload, workerID int) error {
-	logrus.Infof("Worker %d cleaning temp files for user=%d", workerID, payload.UserID)
+	_, obj, err := in.Open(ctx, params.UploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
 
-	time.Sleep(1 * time.Second) // simulasi IO cleanup
+	res, err := resizeImage(ctx, obj, params, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	logrus.Infof("Worker %d temp cleanup done for user=%d", workerID, payload.UserID)
-	return nil
+	tasklog.FromContext(ctx).Infof("Worker %d image resized into %d sizes for user=%d", workerID, len(res.Files), payload.UserID)
+	return res, nil
 }
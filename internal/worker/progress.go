@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"task_handler/internal/task"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval is the least time between two progress writes of a task
+const progressInterval = time.Second
+
+// Progress records how far a running task is. Updates are throttled, so a
+// handler may call Update for every item it processes.
+type Progress struct {
+	pool     *Pool
+	envelope *task.Envelope
+	last     time.Time
+	percent  int
+}
+
+func (p *Pool) progressFor(envelope *task.Envelope) *Progress {
+	return &Progress{pool: p, envelope: envelope, percent: -1}
+}
+
+// Update reports that done of total items are finished
+func (pr *Progress) Update(done, total int) {
+	if total <= 0 || pr.pool.repo == nil {
+		return
+	}
+
+	percent := min(100, done*100/total)
+	if percent == pr.percent || time.Since(pr.last) < progressInterval {
+		return
+	}
+	pr.percent, pr.last = percent, time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusUpdateTimeout)
+	defer cancel()
+
+	// Progress is informational, a failed write does not fail the task
+	if err := pr.pool.repo.SetProgress(ctx, pr.pool.db, pr.envelope.TaskID, percent); err != nil {
+		logrus.WithError(err).Warnf("Failed to record progress of task %d", pr.envelope.TaskID)
+		return
+	}
+	pr.pool.invalidateCache(pr.envelope)
+}
@@ -0,0 +1,380 @@
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"task_handler/internal/config"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"time"
+)
+
+// DefaultReportConfig is used until SetReportConfig is called
+var DefaultReportConfig = config.ReportConfig{
+	MaxRange:     366 * 24 * time.Hour,
+	DefaultRange: 30 * 24 * time.Hour,
+}
+
+var errInvalidReportParams = errors.New("invalid generate_report params")
+
+// reportContentTypes lists the supported formats
+var reportContentTypes = map[string]string{
+	"csv":  "text/csv",
+	"json": "application/json",
+	"pdf":  "application/pdf",
+}
+
+// reportParams are the params of a generate_report task. from and to are
+// RFC 3339 times or dates; a date as "to" includes that whole day.
+type reportParams struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Format string `json:"format"`
+}
+
+// reportSpec is a validated report request
+type reportSpec struct {
+	Format string
+	From   time.Time
+	To     time.Time
+}
+
+func parseReportParams(raw json.RawMessage, cfg config.ReportConfig, now time.Time) (*reportSpec, error) {
+	var p reportParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidReportParams, err)
+		}
+	}
+
+	spec := &reportSpec{Format: p.Format, To: now.UTC()}
+	if spec.Format == "" {
+		spec.Format = "csv"
+	}
+	if _, ok := reportContentTypes[spec.Format]; !ok {
+		return nil, fmt.Errorf("%w: format must be csv, json or pdf", errInvalidReportParams)
+	}
+
+	if p.To != "" {
+		to, dateOnly, err := parseReportTime(p.To)
+		if err != nil {
+			return nil, fmt.Errorf("%w: to: %v", errInvalidReportParams, err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		spec.To = to
+	}
+
+	spec.From = spec.To.Add(-cfg.DefaultRange)
+	if p.From != "" {
+		from, _, err := parseReportTime(p.From)
+		if err != nil {
+			return nil, fmt.Errorf("%w: from: %v", errInvalidReportParams, err)
+		}
+		spec.From = from
+	}
+
+	if !spec.From.Before(spec.To) {
+		return nil, fmt.Errorf("%w: from must be before to", errInvalidReportParams)
+	}
+	if spec.To.Sub(spec.From) > cfg.MaxRange {
+		return nil, fmt.Errorf("%w: range is longer than %s", errInvalidReportParams, cfg.MaxRange)
+	}
+
+	return spec, nil
+}
+
+// parseReportTime accepts RFC 3339 or YYYY-MM-DD, the latter in UTC
+func parseReportTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), false, err
+}
+
+func (p *Pool) processGenerateReport(ctx context.Context, payload *task.Envelope, workerID int) (*Result, error) {
+	spec, err := parseReportParams(payload.Params, p.reports, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tasklog.FromContext(ctx).Infof("Worker %d generating %s report for user=%d", workerID, spec.Format, payload.UserID)
+
+	q := task.ReportQuery{
+		UserID:    payload.UserID,
+		From:      spec.From,
+		To:        spec.To,
+		ExcludeID: payload.TaskID,
+	}
+	summary, err := p.repo.GetReportSummary(ctx, p.db, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize tasks: %w", err)
+	}
+
+	meta := reportMeta{
+		UserID:      payload.UserID,
+		From:        spec.From,
+		To:          spec.To,
+		GeneratedAt: time.Now().UTC(),
+	}
+	progress := p.progressFor(payload)
+
+	// The rows are streamed to the artifact store while they are read;
+	// saveResult closes the reader, which stops the writer if the upload fails
+	pr, pw := io.Pipe()
+	go func() {
+		err := writeReport(pw, spec.Format, meta, summary, func(fn func(*task.Task) error) error {
+			done := 0
+			return p.repo.EachReportTask(ctx, p.db, q, func(t *task.Task) error {
+				if err := fn(t); err != nil {
+					return err
+				}
+				done++
+				progress.Update(done, summary.Total)
+				return nil
+			})
+		})
+		if err == nil {
+			tasklog.FromContext(ctx).Infof("Worker %d report generated for user=%d", workerID, payload.UserID)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &Result{
+		Data: map[string]any{
+			"format":  spec.Format,
+			"from":    spec.From,
+			"to":      spec.To,
+			"rows":    summary.Total,
+			"summary": summary,
+		},
+		Files: []File{{
+			Name:        "report." + spec.Format,
+			ContentType: reportContentTypes[spec.Format],
+			Body:        pr,
+			Size:        -1,
+		}},
+	}, nil
+}
+
+// reportMeta describes a report in its header
+type reportMeta struct {
+	UserID      int       `json:"user_id"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// reportRow is one task as it appears in a report
+type reportRow struct {
+	ID              int         `json:"task_id"`
+	TaskType        string      `json:"task_type"`
+	Status          task.Status `json:"status"`
+	CreatedAt       time.Time   `json:"created_at"`
+	FinishedAt      *time.Time  `json:"finished_at"`
+	DurationSeconds *float64    `json:"duration_seconds"`
+	ErrorMessage    *string     `json:"error_message"`
+}
+
+func newReportRow(t *task.Task) reportRow {
+	row := reportRow{
+		ID:           t.ID,
+		TaskType:     t.TaskType,
+		Status:       t.Status,
+		CreatedAt:    t.CreatedAt.UTC(),
+		ErrorMessage: t.ErrorMessage,
+	}
+	if t.Status == task.StatusSuccess || t.Status == task.StatusFailed {
+		finished := t.UpdatedAt.UTC()
+		seconds := t.Duration().Seconds()
+		row.FinishedAt, row.DurationSeconds = &finished, &seconds
+	}
+	return row
+}
+
+// reportWriter renders a report in one format
+type reportWriter interface {
+	Begin(meta reportMeta, summary *task.ReportSummary) error
+	Row(row reportRow) error
+	End() error
+}
+
+// writeReport renders a report to w. each calls its argument once per
+// task, in report order.
+func writeReport(w io.Writer, format string, meta reportMeta, summary *task.ReportSummary, each func(func(*task.Task) error) error) error {
+	buf := bufio.NewWriter(w)
+
+	var rw reportWriter
+	switch format {
+	case "csv":
+		rw = &csvReportWriter{w: csv.NewWriter(buf)}
+	case "json":
+		rw = &jsonReportWriter{w: buf}
+	case "pdf":
+		rw = &pdfReportWriter{pdf: newPDFWriter(buf)}
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+
+	if err := rw.Begin(meta, summary); err != nil {
+		return err
+	}
+	if err := each(func(t *task.Task) error { return rw.Row(newReportRow(t)) }); err != nil {
+		return err
+	}
+	if err := rw.End(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// csvReportWriter writes one record per task; the summary is only part of
+// the task result
+type csvReportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvReportWriter) Begin(reportMeta, *task.ReportSummary) error {
+	return c.w.Write([]string{
+		"task_id", "task_type", "status", "created_at", "finished_at", "duration_seconds", "error_message",
+	})
+}
+
+func (c *csvReportWriter) Row(row reportRow) error {
+	var finished, duration, message string
+	if row.FinishedAt != nil {
+		finished = row.FinishedAt.Format(time.RFC3339)
+		duration = strconv.FormatFloat(*row.DurationSeconds, 'f', 3, 64)
+	}
+	if row.ErrorMessage != nil {
+		message = *row.ErrorMessage
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(row.ID), row.TaskType, string(row.Status),
+		row.CreatedAt.Format(time.RFC3339), finished, duration, message,
+	})
+}
+
+func (c *csvReportWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonReportWriter writes one document whose tasks array is streamed
+type jsonReportWriter struct {
+	w    io.Writer
+	rows int
+}
+
+func (j *jsonReportWriter) Begin(meta reportMeta, summary *task.ReportSummary) error {
+	head, err := json.Marshal(struct {
+		reportMeta
+		Summary *task.ReportSummary `json:"summary"`
+	}{meta, summary})
+	if err != nil {
+		return err
+	}
+
+	// Reopen the object to append the tasks array
+	head = append(head[:len(head)-1], `,"tasks":[`...)
+	_, err = j.w.Write(head)
+	return err
+}
+
+func (j *jsonReportWriter) Row(row reportRow) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if j.rows > 0 {
+		data = append([]byte{','}, data...)
+	}
+	j.rows++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonReportWriter) End() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+// pdfReportWriter prints the summary followed by a table of the tasks
+type pdfReportWriter struct {
+	pdf *pdfWriter
+}
+
+const pdfRowFormat = "%-8s %-16s %-10s %-20s %9s  %s"
+
+func (p *pdfReportWriter) Begin(meta reportMeta, summary *task.ReportSummary) error {
+	lines := []string{
+		fmt.Sprintf("Task report for user %d", meta.UserID),
+		fmt.Sprintf("%s to %s", meta.From.Format(time.RFC3339), meta.To.Format(time.RFC3339)),
+		fmt.Sprintf("Generated %s", meta.GeneratedAt.Format(time.RFC3339)),
+		"",
+		fmt.Sprintf("Total tasks: %d", summary.Total),
+		"",
+		"By type                    count   avg duration",
+	}
+	for _, taskType := range sortedKeys(summary.ByType) {
+		avg := "-"
+		if seconds, ok := summary.AvgDurationSeconds[taskType]; ok {
+			avg = fmt.Sprintf("%.1fs", seconds)
+		}
+		lines = append(lines, fmt.Sprintf("  %-24s %5d   %s", taskType, summary.ByType[taskType], avg))
+	}
+
+	lines = append(lines, "", "By status")
+	for _, status := range sortedKeys(summary.ByStatus) {
+		lines = append(lines, fmt.Sprintf("  %-24s %5d", status, summary.ByStatus[status]))
+	}
+
+	if len(summary.FailureReasons) > 0 {
+		lines = append(lines, "", "Most common failure reasons")
+		for _, reason := range summary.FailureReasons {
+			lines = append(lines, fmt.Sprintf("  %5d  %s", reason.Count, reason.Message))
+		}
+	}
+
+	lines = append(lines, "", fmt.Sprintf(pdfRowFormat, "ID", "TYPE", "STATUS", "CREATED", "DURATION", "ERROR"))
+	for _, line := range lines {
+		if err := p.pdf.Line(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *pdfReportWriter) Row(row reportRow) error {
+	duration, message := "-", ""
+	if row.DurationSeconds != nil {
+		duration = fmt.Sprintf("%.1fs", *row.DurationSeconds)
+	}
+	if row.ErrorMessage != nil {
+		message = *row.ErrorMessage
+	}
+	return p.pdf.Line(fmt.Sprintf(pdfRowFormat,
+		strconv.Itoa(row.ID), row.TaskType, row.Status, row.CreatedAt.Format(time.RFC3339), duration, message))
+}
+
+func (p *pdfReportWriter) End() error {
+	return p.pdf.Close()
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reportNow = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func TestParseReportParams(t *testing.T) {
+	spec, err := parseReportParams(nil, DefaultReportConfig, reportNow)
+	require.NoError(t, err)
+	assert.Equal(t, "csv", spec.Format)
+	assert.Equal(t, reportNow, spec.To)
+	assert.Equal(t, reportNow.Add(-DefaultReportConfig.DefaultRange), spec.From)
+
+	spec, err = parseReportParams(json.RawMessage(`{"from": "2025-01-01", "to": "2025-01-31", "format": "pdf"}`), DefaultReportConfig, reportNow)
+	require.NoError(t, err)
+	assert.Equal(t, "pdf", spec.Format)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), spec.From)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), spec.To, "a date includes the whole day")
+
+	spec, err = parseReportParams(json.RawMessage(`{"from": "2025-01-01T10:00:00+02:00", "to": "2025-01-01T12:00:00Z"}`), DefaultReportConfig, reportNow)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC), spec.From)
+}
+
+func TestParseReportParams_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		`{"format": "xlsx"}`,
+		`{"from": "yesterday"}`,
+		`{"from": "2025-02-01", "to": "2025-01-01"}`,
+		`{"from": "2020-01-01", "to": "2025-01-01"}`,
+		`[]`,
+	} {
+		_, err := parseReportParams(json.RawMessage(raw), DefaultReportConfig, reportNow)
+		assert.ErrorIs(t, err, errInvalidReportParams, raw)
+	}
+}
+
+func reportFixture() (reportMeta, *task.ReportSummary, []*task.Task) {
+	created := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	failure := "smtp: connection refused"
+
+	meta := reportMeta{UserID: 7, From: created.Add(-time.Hour), To: created.Add(time.Hour), GeneratedAt: reportNow}
+	summary := &task.ReportSummary{
+		Total:              3,
+		ByType:             map[string]int{"send_email": 2, "resize_image": 1},
+		ByStatus:           map[task.Status]int{task.StatusSuccess: 1, task.StatusFailed: 1, task.StatusPending: 1},
+		AvgDurationSeconds: map[string]float64{"send_email": 1.5},
+		FailureReasons:     []task.FailureReason{{Message: failure, Count: 1}},
+	}
+	tasks := []*task.Task{
+		{ID: 1, TaskType: "send_email", Status: task.StatusSuccess, CreatedAt: created, UpdatedAt: created.Add(time.Second)},
+		{ID: 2, TaskType: "send_email", Status: task.StatusFailed, CreatedAt: created, UpdatedAt: created.Add(2 * time.Second), ErrorMessage: &failure},
+		{ID: 3, TaskType: "resize_image", Status: task.StatusPending, CreatedAt: created, UpdatedAt: created},
+	}
+	return meta, summary, tasks
+}
+
+func renderReport(t *testing.T, format string, meta reportMeta, summary *task.ReportSummary, tasks []*task.Task) []byte {
+	var buf bytes.Buffer
+	err := writeReport(&buf, format, meta, summary, func(fn func(*task.Task) error) error {
+		for _, tk := range tasks {
+			if err := fn(tk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestWriteReport_CSV(t *testing.T) {
+	meta, summary, tasks := reportFixture()
+
+	records, err := csv.NewReader(bytes.NewReader(renderReport(t, "csv", meta, summary, tasks))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, "task_id", records[0][0])
+	assert.Equal(t, []string{"1", "send_email", "SUCCESS", "2025-01-02T09:00:00Z", "2025-01-02T09:00:01Z", "1.000", ""}, records[1])
+	assert.Equal(t, "smtp: connection refused", records[2][6])
+	assert.Equal(t, []string{"3", "resize_image", "PENDING", "2025-01-02T09:00:00Z", "", "", ""}, records[3])
+}
+
+func TestWriteReport_JSON(t *testing.T) {
+	meta, summary, tasks := reportFixture()
+
+	var doc struct {
+		UserID  int                `json:"user_id"`
+		Summary task.ReportSummary `json:"summary"`
+		Tasks   []map[string]any   `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(renderReport(t, "json", meta, summary, tasks), &doc))
+
+	assert.Equal(t, 7, doc.UserID)
+	assert.Equal(t, 3, doc.Summary.Total)
+	require.Len(t, doc.Tasks, 3)
+	assert.Equal(t, 2.0, doc.Tasks[1]["duration_seconds"])
+	assert.Nil(t, doc.Tasks[2]["finished_at"])
+
+	// An empty range still yields a valid document
+	var empty map[string]any
+	require.NoError(t, json.Unmarshal(renderReport(t, "json", meta, &task.ReportSummary{}, nil), &empty))
+	assert.Empty(t, empty["tasks"])
+}
+
+// checkPDF verifies the cross-reference table points at every object and
+// returns the number of pages
+func checkPDF(t *testing.T, doc []byte) int {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	require.NotNil(t, startxref)
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	require.True(t, bytes.HasPrefix(doc[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(doc[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(doc[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	count := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`).FindSubmatch(doc)
+	require.NotNil(t, count)
+	pages, _ := strconv.Atoi(string(count[1]))
+	return pages
+}
+
+func TestWriteReport_PDF(t *testing.T) {
+	meta, summary, tasks := reportFixture()
+
+	doc := renderReport(t, "pdf", meta, summary, tasks)
+	assert.Equal(t, 1, checkPDF(t, doc))
+	assert.Contains(t, string(doc), "(Task report for user 7) Tj")
+	assert.Contains(t, string(doc), "smtp: connection refused")
+}
+
+func TestWriteReport_PDFPages(t *testing.T) {
+	meta, summary, _ := reportFixture()
+
+	var tasks []*task.Task
+	for i := 0; i < 3*pdfLinesPerPage; i++ {
+		tasks = append(tasks, &task.Task{ID: i, TaskType: "send_email", Status: task.StatusPending})
+	}
+
+	assert.Equal(t, 4, checkPDF(t, renderReport(t, "pdf", meta, summary, tasks)))
+}
+
+func TestPDFEscape(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\c d`, pdfEscape("a(b)\\c\nd"))
+	assert.Equal(t, `caf\351 ?`, pdfEscape("café ☃"))
+	assert.Len(t, pdfEscape(strings.Repeat("x", 500)), pdfLineChars)
+}
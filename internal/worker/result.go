@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"task_handler/internal/artifact"
+	"task_handler/internal/task"
+	"time"
+)
+
+// DefaultInlineLimit is the largest result JSON, in bytes, stored on the
+// task row unless SetInlineLimit says otherwise
+const DefaultInlineLimit = 64 * 1024
+
+// resultArtifactName holds a result too large to be stored inline
+const resultArtifactName = "result.json"
+
+// resultUploadTimeout bounds uploading all files of one result
+const resultUploadTimeout = 2 * time.Minute
+
+// errInvalidResult marks a result the handler should never have produced.
+// Retrying cannot fix it, so the task fails.
+var errInvalidResult = errors.New("invalid task result")
+
+// savedResult is a handler result whose files are in the artifact store
+// and which is ready to be recorded on the task
+type savedResult struct {
+	inline     json.RawMessage
+	resultFile *string
+	artifacts  []*task.Artifact
+}
+
+// saveResult encodes res and uploads its files. The upload runs under
+// handlerCtx so shutdown can abort it like the handler itself.
+func (p *Pool) saveResult(envelope *task.Envelope, res *Result) (*savedResult, error) {
+	saved := &savedResult{}
+	if res == nil {
+		return saved, nil
+	}
+
+	// Closing a streamed body stops its producer, also when the file is
+	// rejected or its upload fails
+	defer func() {
+		for _, f := range res.Files {
+			if c, ok := f.Body.(io.Closer); ok {
+				c.Close()
+			}
+		}
+	}()
+
+	files := res.Files
+	if res.Data != nil {
+		data, err := json.Marshal(res.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encode data: %v", errInvalidResult, err)
+		}
+		if len(data) <= p.inlineLimit {
+			saved.inline = data
+		} else {
+			files = append(files, File{
+				Name:        resultArtifactName,
+				ContentType: "application/json",
+				Body:        bytes.NewReader(data),
+				Size:        int64(len(data)),
+			})
+		}
+	}
+
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !artifact.ValidName(f.Name) || seen[f.Name] {
+			return nil, fmt.Errorf("%w: bad or duplicate file name %q", errInvalidResult, f.Name)
+		}
+		seen[f.Name] = true
+	}
+
+	ctx, cancel := context.WithTimeout(p.handlerCtx, resultUploadTimeout)
+	defer cancel()
+
+	for _, f := range files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		key := artifact.TaskKey(envelope.TaskID, f.Name)
+		body := &countingReader{r: f.Body}
+		if err := p.store.Put(ctx, key, body, f.Size, contentType); err != nil {
+			return nil, fmt.Errorf("store artifact %s: %w", f.Name, err)
+		}
+
+		saved.artifacts = append(saved.artifacts, &task.Artifact{
+			TaskID:      envelope.TaskID,
+			Name:        f.Name,
+			StorageKey:  key,
+			ContentType: contentType,
+			Size:        body.n,
+		})
+	}
+
+	// The first file is the task's main output
+	if len(saved.artifacts) > 0 {
+		name := saved.artifacts[0].Name
+		saved.resultFile = &name
+	}
+
+	return saved, nil
+}
+
+// countingReader records how many bytes were read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
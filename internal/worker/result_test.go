@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResultTestPool(t *testing.T) (*Pool, artifact.Store) {
+	store, err := artifact.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	return NewPool(nil, nil, nil, store, 1), store
+}
+
+func readArtifact(t *testing.T, store artifact.Store, key string) string {
+	obj, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer obj.Close()
+
+	body, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestSaveResult_InlineAndFiles(t *testing.T) {
+	pool, store := newResultTestPool(t)
+	envelope := &task.Envelope{TaskID: 42}
+
+	saved, err := pool.saveResult(envelope, &Result{
+		Data: map[string]int{"rows": 2},
+		Files: []File{
+			{Name: "report.csv", ContentType: "text/csv", Body: strings.NewReader("a\n1\n2\n"), Size: -1},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"rows":2}`, string(saved.inline))
+	require.Len(t, saved.artifacts, 1)
+	assert.Equal(t, "tasks/42/report.csv", saved.artifacts[0].StorageKey)
+	assert.Equal(t, int64(6), saved.artifacts[0].Size)
+	require.NotNil(t, saved.resultFile)
+	assert.Equal(t, "report.csv", *saved.resultFile)
+
+	assert.Equal(t, "a\n1\n2\n", readArtifact(t, store, "tasks/42/report.csv"))
+}
+
+func TestSaveResult_LargeDataBecomesArtifact(t *testing.T) {
+	pool, store := newResultTestPool(t)
+	pool.SetInlineLimit(16)
+
+	saved, err := pool.saveResult(&task.Envelope{TaskID: 7}, &Result{
+		Data: map[string]string{"text": strings.Repeat("x", 32)},
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, saved.inline)
+	require.Len(t, saved.artifacts, 1)
+	assert.Equal(t, "result.json", saved.artifacts[0].Name)
+	assert.Equal(t, "application/json", saved.artifacts[0].ContentType)
+	assert.Contains(t, readArtifact(t, store, "tasks/7/result.json"), strings.Repeat("x", 32))
+}
+
+func TestSaveResult_Empty(t *testing.T) {
+	pool, _ := newResultTestPool(t)
+
+	for _, res := range []*Result{nil, {}} {
+		saved, err := pool.saveResult(&task.Envelope{TaskID: 1}, res)
+		require.NoError(t, err)
+		assert.Nil(t, saved.inline)
+		assert.Nil(t, saved.resultFile)
+		assert.Empty(t, saved.artifacts)
+	}
+}
+
+func TestSaveResult_InvalidFiles(t *testing.T) {
+	pool, _ := newResultTestPool(t)
+
+	cases := map[string][]File{
+		"BadName": {{Name: "../escape", Body: strings.NewReader("x"), Size: 1}},
+		"Duplicate": {
+			{Name: "a.txt", Body: strings.NewReader("x"), Size: 1},
+			{Name: "a.txt", Body: strings.NewReader("y"), Size: 1},
+		},
+	}
+	for name, files := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := pool.saveResult(&task.Envelope{TaskID: 1}, &Result{Files: files})
+			assert.ErrorIs(t, err, errInvalidResult)
+		})
+	}
+
+	_, err := pool.saveResult(&task.Envelope{TaskID: 1}, &Result{Data: make(chan int)})
+	assert.ErrorIs(t, err, errInvalidResult)
+}
+
+func TestSaveResult_ClosesStreamedBodies(t *testing.T) {
+	pool, store := newResultTestPool(t)
+
+	// A producer writing more than is read must not block forever
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := pw.Write([]byte("stored"))
+		if err == nil {
+			_, err = pw.Write([]byte("never read"))
+		}
+		done <- err
+	}()
+
+	_, err := pool.saveResult(&task.Envelope{TaskID: 5}, &Result{Files: []File{
+		{Name: "../bad", Body: pr, Size: -1},
+	}})
+	assert.ErrorIs(t, err, errInvalidResult)
+	assert.ErrorIs(t, <-done, io.ErrClosedPipe)
+
+	// A complete stream is stored whole
+	pr, pw = io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("a,b\n"))
+		pw.Close()
+	}()
+	_, err = pool.saveResult(&task.Envelope{TaskID: 5}, &Result{Files: []File{
+		{Name: "report.csv", Body: pr, Size: -1},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n", readArtifact(t, store, artifact.TaskKey(5, "report.csv")))
+}
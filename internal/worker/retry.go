@@ -0,0 +1,26 @@
+package worker
+
+import "errors"
+
+// retryableError marks a task error that may not happen again, such as a
+// mail server being briefly unavailable. The task is retried with backoff
+// instead of failing at once.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// retryable marks err as worth another attempt
+func retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+func isRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
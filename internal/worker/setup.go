@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"database/sql"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/mail"
+	"task_handler/internal/outbound"
+	"task_handler/internal/plugin"
+	"task_handler/internal/queue"
+	"task_handler/internal/sandbox"
+	"task_handler/internal/task"
+)
+
+// Setup builds the pool run by the worker and orchestrator binaries.
+// taskCache must be the cache the API reads task status from, so every
+// change the pool makes is visible at once. The plugin registry is
+// returned for the caller to start and close.
+func Setup(cfg *config.Config, broker queue.Broker, db *sql.DB, store artifact.Store, taskCache *cache.TaskCache) (*Pool, *plugin.Registry) {
+	pool := NewPool(broker, db, task.NewTaskRepository(), store, cfg.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.SetInlineLimit(cfg.Artifact.InlineLimit)
+	pool.SetImageConfig(cfg.Image)
+	pool.SetReportConfig(cfg.Report)
+	pool.SetRetentionConfig(cfg.Retention)
+	pool.SetMailer(mail.SetupMailer(&cfg.Mail))
+	pool.SetTaskLogConfig(cfg.TaskLog)
+	pool.SetExec(cfg.Exec, sandbox.Setup(&cfg.Exec))
+	pool.SetOutboundClient(outbound.Setup(&cfg.Outbound))
+
+	plugins := plugin.Setup(&cfg.Plugin, TaskTypes)
+	pool.SetPlugins(plugins)
+	return pool, plugins
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/queue"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetup_WiresCache checks that the pool of both binaries clears the
+// task status cached for the API, which progress updates, status changes
+// and retention sweeps rely on
+func TestSetup_WiresCache(t *testing.T) {
+	cfg := config.Load()
+	cfg.Plugin.Dir = ""
+	store, err := artifact.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+
+	pool, plugins := Setup(cfg, queue.NewMemoryBroker(), nil, store, taskCache)
+	defer plugins.Close(context.Background())
+	require.Same(t, taskCache, pool.cache)
+
+	ctx := context.Background()
+	require.NoError(t, taskCache.Set(ctx, cache.TaskKey(7), map[string]string{"status": "PENDING"}))
+	pool.invalidateCache(&task.Envelope{TaskID: 7, UserID: 1})
+
+	cached, _ := taskCache.Get(ctx, cache.TaskKey(7))
+	assert.Nil(t, cached)
+}
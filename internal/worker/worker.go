@@ -3,147 +3,573 @@ package worker
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"task_handler/internal/artifact"
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/mail"
+	"task_handler/internal/observability"
+	"task_handler/internal/outbound"
+	"task_handler/internal/plugin"
+	"task_handler/internal/queue"
+	"task_handler/internal/sandbox"
 	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"task_handler/internal/upload"
 	"task_handler/internal/utils"
 	"time"
 
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sirupsen/logrus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func republishWithRetry(ch *amqp.Channel, msg *amqp.Delivery, retryCount int32) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// abortGrace bounds how long Shutdown waits for handlers to return after
+// their context has been cancelled
+const abortGrace = 5 * time.Second
+
+// statusUpdateTimeout bounds each task status transaction
+const statusUpdateTimeout = 10 * time.Second
+
+// DefaultTaskLogConfig bounds task logs until SetTaskLogConfig is called
+var DefaultTaskLogConfig = config.TaskLogConfig{
+	MaxLines:      1000,
+	MaxLineBytes:  2048,
+	FlushInterval: time.Second,
+}
+
+// Pool runs a fixed number of task consumers and coordinates their
+// graceful shutdown
+type Pool struct {
+	broker queue.Broker
+	db     *sql.DB
+	repo   task.TaskRepositoryInterface
+	store  artifact.Store
+	size   int
+
+	// uploads resolves the input files tasks refer to
+	uploads upload.UploadServiceInterface
+
+	// inlineLimit is the largest result JSON stored on the task row
+	inlineLimit int
+
+	// images bounds the resize_image task
+	images config.ImageConfig
+
+	// reports bounds the generate_report task
+	reports config.ReportConfig
+
+	// retention is the policy cleanup_temp enforces
+	retention config.RetentionConfig
+
+	// mailer delivers send_email tasks
+	mailer *mail.Mailer
+
+	// exec bounds the exec task, which runs only commands from the allowlist
+	exec     config.ExecConfig
+	commands *sandbox.Allowlist
+
+	// outbound makes the calls of http_request tasks
+	outbound *outbound.Client
+
+	// plugins, when set, handle task types that are not built in
+	plugins *plugin.Registry
+
+	// taskLogs bounds the lines handlers log through tasklog.FromContext,
+	// which are stored per attempt in logRepo
+	taskLogs config.TaskLogConfig
+	logRepo  tasklog.TaskLogRepositoryInterface
+
+	// cache, when set, is invalidated on every status change so API reads
+	// served from it do not go stale
+	cache *cache.TaskCache
+
+	// consumeCtx is cancelled to stop taking new deliveries
+	consumeCtx  context.Context
+	stopConsume context.CancelFunc
+
+	// handlerCtx is cancelled to abort tasks still running after the drain timeout
+	handlerCtx context.Context
+	abort      context.CancelFunc
+
+	wg sync.WaitGroup
+}
+
+func NewPool(broker queue.Broker, db *sql.DB, repo task.TaskRepositoryInterface, store artifact.Store, size int) *Pool {
+	consumeCtx, stopConsume := context.WithCancel(context.Background())
+	handlerCtx, abort := context.WithCancel(context.Background())
+
+	// Workers only open uploads, which does not depend on the upload limits
+	uploads := upload.NewUploadService(upload.NewUploadRepository(), db, store, config.UploadConfig{})
+
+	return &Pool{
+		broker:      broker,
+		db:          db,
+		repo:        repo,
+		store:       store,
+		size:        size,
+		uploads:     uploads,
+		inlineLimit: DefaultInlineLimit,
+		images:      DefaultImageConfig,
+		reports:     DefaultReportConfig,
+		retention:   DefaultRetentionConfig,
+		mailer:      mail.NewLogMailer(),
+		exec:        DefaultExecConfig,
+		commands:    &sandbox.Allowlist{},
+		outbound:    outbound.NewDisabledClient(),
+		taskLogs:    DefaultTaskLogConfig,
+		logRepo:     tasklog.NewTaskLogRepository(),
+		consumeCtx:  consumeCtx,
+		stopConsume: stopConsume,
+		handlerCtx:  handlerCtx,
+		abort:       abort,
+	}
+}
+
+// SetImageConfig sets the limits and defaults of resize_image. It must be
+// called before Start.
+func (p *Pool) SetImageConfig(cfg config.ImageConfig) {
+	p.images = cfg
+}
+
+// SetReportConfig sets the date range limits of generate_report. It must
+// be called before Start.
+func (p *Pool) SetReportConfig(cfg config.ReportConfig) {
+	p.reports = cfg
+}
+
+// SetRetentionConfig sets the policy cleanup_temp enforces. It must be
+// called before Start.
+func (p *Pool) SetRetentionConfig(cfg config.RetentionConfig) {
+	p.retention = cfg
+}
+
+// SetMailer sets how send_email delivers mail. It must be called before
+// Start.
+func (p *Pool) SetMailer(m *mail.Mailer) {
+	p.mailer = m
+}
+
+// SetExec sets the limits of exec and the commands it may run. It must be
+// called before Start.
+func (p *Pool) SetExec(cfg config.ExecConfig, commands *sandbox.Allowlist) {
+	p.exec = cfg
+	p.commands = commands
+}
+
+// SetOutboundClient sets the client http_request calls through. It must
+// be called before Start.
+func (p *Pool) SetOutboundClient(c *outbound.Client) {
+	p.outbound = c
+}
+
+// SetPlugins lets the WebAssembly plugins in r handle task types that are
+// not built in. It must be called before Start.
+func (p *Pool) SetPlugins(r *plugin.Registry) {
+	p.plugins = r
+}
+
+// SetTaskLogConfig sets how many lines of each attempt are stored and how
+// often. It must be called before Start.
+func (p *Pool) SetTaskLogConfig(cfg config.TaskLogConfig) {
+	p.taskLogs = cfg
+}
+
+// SetCache makes the pool drop a task's cached entries whenever it changes
+// the task's status. It must be called before Start.
+func (p *Pool) SetCache(c *cache.TaskCache) {
+	p.cache = c
+}
+
+// SetInlineLimit changes the largest result JSON, in bytes, stored on the
+// task itself. It must be called before Start.
+func (p *Pool) SetInlineLimit(n int) {
+	p.inlineLimit = n
+}
+
+// invalidateCache is best effort: a stale entry expires with its TTL
+func (p *Pool) invalidateCache(envelope *task.Envelope) {
+	if p.cache == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
+	if err := p.cache.Invalidate(ctx, envelope.TaskID, envelope.UserID); err != nil {
+		logrus.WithError(err).Warn("Failed to invalidate task cache")
+	}
+}
 
-	// Create new headers with incremented retry count
-	headers := amqp.Table{}
-	if msg.Headers != nil {
-		headers = msg.Headers
-	}
-	headers["x-retry-count"] = retryCount
-
-	return ch.PublishWithContext(
-		ctx,
-		"",             // exchange
-		msg.RoutingKey, // routing key (queue name)
-		false,          // mandatory
-		false,          // immediate
-		amqp.Publishing{
-			ContentType: msg.ContentType,
-			Body:        msg.Body,
-			Headers:     headers,
-		},
-	)
+// updateStatus runs one status transaction for a task, traced under parent.
+// Cancelling parent does not cancel it on purpose: a task aborted by
+// shutdown must still be handed back as PENDING.
+func (p *Pool) updateStatus(parent context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), statusUpdateTimeout)
+	defer cancel()
+
+	return utils.WithTransaction(ctx, p.db, func(tx *sql.Tx) error {
+		return fn(ctx, tx)
+	})
+}
+
+// isSettled reports whether a status update failed because the task is
+// already final or no longer exists. Retrying cannot change either.
+func isSettled(err error) bool {
+	var transitionErr *task.TransitionError
+	if errors.As(err, &transitionErr) {
+		return transitionErr.From.IsTerminal()
+	}
+	return errors.Is(err, task.ErrTaskNotFound)
+}
+
+// Start launches the consumers in the background
+func (p *Pool) Start() {
+	for i := 1; i <= p.size; i++ {
+		p.wg.Add(1)
+		go func(id int) {
+			defer p.wg.Done()
+			p.startWorker(id)
+		}(i)
+	}
+}
+
+// Shutdown cancels every consumer so no new messages are delivered, then
+// waits for in-flight tasks to finish. If ctx expires first, the running
+// handlers are cancelled, their messages requeued, and ctx.Err() is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopConsume()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	logrus.Warn("Drain timeout reached, aborting running tasks")
+	p.abort()
+
+	select {
+	case <-done:
+	case <-time.After(abortGrace):
+		logrus.Error("Workers did not stop after abort, exiting anyway")
+	}
+
+	return ctx.Err()
+}
+
+// retryDelay is the backoff before the given retry attempt: 2s, 4s, 8s
+func retryDelay(retryCount int32) time.Duration {
+	return time.Duration(1<<retryCount) * time.Second
 }
 
-func StartWorker(conn *amqp.Connection, db *sql.DB, repo task.TaskRepositoryInterface, id int) {
-	ch, err := conn.Channel()
+// republishWithRetry puts the task back on the queue as its next attempt,
+// delayed by an exponential backoff. Its trace continues from parent, the
+// span of the attempt that failed. The x-retry-count header is kept for
+// workers that predate envelopes.
+func (p *Pool) republishWithRetry(parent context.Context, msg *queue.Message, envelope *task.Envelope, retryCount int32) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), 5*time.Second)
+	defer cancel()
+
+	next := *envelope
+	next.Attempt = int(retryCount)
+	if next.Version == 0 {
+		next.Version = task.EnvelopeVersion
+	}
+	traceContext := map[string]string{}
+	observability.InjectHeaders(ctx, traceContext)
+	if len(traceContext) > 0 {
+		next.TraceContext = traceContext
+	}
+	body, err := next.Marshal()
 	if err != nil {
-		logrus.Fatalf("Worker %d failed to open channel: %v", id, err)
+		return err
 	}
-	defer ch.Close()
 
-	if err := ch.Qos(1, 0, false); err != nil {
-		logrus.Fatalf("Worker %d failed to set QoS: %v", id, err)
+	retry := *msg
+	retry.Body = body
+	retry.Headers = map[string]string{}
+	for k, v := range msg.Headers {
+		retry.Headers[k] = v
 	}
+	retry.Headers["x-retry-count"] = strconv.Itoa(int(retryCount))
+	observability.InjectHeaders(ctx, retry.Headers)
 
-	msgs, err := ch.Consume(
-		"task_queue",
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	return p.broker.PublishDelayed(ctx, queue.TaskQueue, &retry, retryDelay(retryCount))
+}
+
+// attemptOutcome labels how an attempt ended in metrics
+func attemptOutcome(taskErr, saveErr error, aborted bool) string {
+	switch {
+	case (taskErr != nil || saveErr != nil) && aborted:
+		return "aborted"
+	case isRetryable(taskErr) || saveErr != nil:
+		return "retry"
+	case taskErr != nil:
+		return "failed"
+	default:
+		return "success"
+	}
+}
+
+// logOutcome ends the log of an attempt with how it went
+func logOutcome(ctx context.Context, taskErr, saveErr error, aborted bool) {
+	log := tasklog.FromContext(ctx)
+	switch {
+	case (taskErr != nil || saveErr != nil) && aborted:
+		log.Warn("Attempt interrupted by shutdown, the task will run again")
+	case isRetryable(taskErr):
+		log.WithError(taskErr).Warn("Attempt failed temporarily")
+	case taskErr != nil:
+		log.WithError(taskErr).Error("Task failed")
+	case saveErr != nil:
+		log.WithError(saveErr).Error("Failed to store the result")
+	default:
+		log.Info("Task finished")
+	}
+}
+
+// taskLogger returns the logger of one delivery, whose fields tie its lines
+// to the task and to the API request that created it
+func taskLogger(msg *queue.Message, payload *task.Envelope, attempt, workerID int) *logrus.Entry {
+	fields := logrus.Fields{
+		"task_id":   payload.TaskID,
+		"task_type": payload.TaskType,
+		"attempt":   attempt,
+		"worker_id": workerID,
+	}
+	if id := msg.Headers[queue.HeaderRequestID]; id != "" {
+		fields["request_id"] = id
+	}
+	return logrus.WithFields(fields)
+}
+
+// startWorker consumes until the pool is shut down. Reconnecting to the
+// broker is handled by the broker itself.
+func (p *Pool) startWorker(id int) {
+	consumerTag := fmt.Sprintf("worker-%d", id)
+
+	deliveries, err := p.broker.Consume(p.consumeCtx, queue.TaskQueue, consumerTag)
 	if err != nil {
 		logrus.Fatalf("Worker %d failed to start consuming messages: %v", id, err)
-		return
 	}
 
 	logrus.Infof("Worker %d started", id)
 
-	for msg := range msgs {
-		var payload task.TaskPayload
-		if err := json.Unmarshal(msg.Body, &payload); err != nil {
-			logrus.Error("invalid payload")
-			if err := msg.Nack(false, false); err != nil {
-				logrus.WithError(err).Warn("Failed to nack message")
-			}
-			continue
+	for d := range deliveries {
+		p.processMessage(d, id)
+	}
+
+	logrus.Infof("Worker %d stopped", id)
+}
+
+func (p *Pool) processMessage(d queue.Delivery, id int) {
+	msg := d.Message()
+
+	payload, err := task.DecodeEnvelope(msg.Body)
+	if err != nil {
+		logrus.WithError(err).WithField("worker_id", id).Error("invalid payload")
+		if err := d.Nack(false); err != nil {
+			logrus.WithError(err).Warn("Failed to nack message")
 		}
+		return
+	}
+
+	// Messages republished by older workers only carry the header
+	retryCount := int32(payload.Attempt)
+	if count, err := strconv.Atoi(msg.Headers["x-retry-count"]); err == nil && int32(count) > retryCount {
+		retryCount = int32(count)
+	}
 
-		retryCount := int32(0)
-		if msg.Headers != nil {
-			if count, ok := msg.Headers["x-retry-count"].(int32); ok {
-				retryCount = count
+	// The attempt continues the trace of whoever published it. The span
+	// lives outside the handler's context so shutdown does not cut off the
+	// status updates recorded in it.
+	traceCtx := observability.ExtractHeaders(context.Background(), msg.Headers)
+	if !trace.SpanContextFromContext(traceCtx).IsValid() {
+		traceCtx = observability.ExtractHeaders(context.Background(), payload.TraceContext)
+	}
+	traceCtx, span := observability.Tracer().Start(traceCtx, "process "+queue.TaskQueue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationName(queue.TaskQueue),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingMessageID(payload.MessageID),
+			observability.TaskIDKey.Int(payload.TaskID),
+			observability.TaskTypeKey.String(payload.TaskType),
+			observability.AttemptKey.Int(int(retryCount)+1),
+		),
+	)
+	defer span.End()
+
+	log := taskLogger(msg, payload, int(retryCount)+1, id)
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		log = log.WithField("trace_id", sc.TraceID().String())
+	}
+	taskType := observability.TaskType(payload.TaskType)
+	log.WithFields(logrus.Fields{
+		"user_id":          payload.UserID,
+		"message_id":       payload.MessageID,
+		"envelope_version": payload.Version,
+	}).Info("Processing task")
+
+	// Transaction 1: Mark as PROCESSING (commit immediately)
+	if err := p.updateStatus(traceCtx, func(ctx context.Context, tx *sql.Tx) error {
+		log.Infof("Worker %d: Marking task %d as PROCESSING", id, payload.TaskID)
+		return p.repo.MarkProcessing(ctx, tx, payload.TaskID)
+	}); err != nil {
+		// Redelivery of a task that already finished, or whose row is gone:
+		// there is nothing left to do, so settle the message
+		if isSettled(err) {
+			log.WithError(err).Warnf("Worker %d: Skipping task %d", id, payload.TaskID)
+			if err := d.Ack(); err != nil {
+				log.WithError(err).Warn("Failed to ack skipped message")
 			}
+			return
+		}
+		log.WithError(err).Error("Failed to mark task as processing")
+		observability.RecordError(span, err)
+		if err := d.Nack(true); err != nil {
+			log.WithError(err).Warn("Failed to nack message for requeue")
 		}
+		return
+	}
+	p.invalidateCache(payload)
 
-		logrus.Infof(
-			"Worker %d processing task=%s for user=%d (retry: %d)",
-			id,
-			payload.TaskType,
-			payload.UserID,
-			retryCount,
-		)
-
-		// Transaction 1: Mark as PROCESSING (commit immediately)
-		if err := utils.WithTransaction(db, func(tx *sql.Tx) error {
-			logrus.Infof("Worker %d: Marking task %d as PROCESSING", id, payload.ID)
-			return repo.MarkProcessing(tx, payload.ID)
-		}); err != nil {
-			logrus.WithError(err).Error("Failed to mark task as processing")
-			if err := msg.Nack(false, true); err != nil {
-				logrus.WithError(err).Warn("Failed to nack message for requeue")
-			}
-			continue
+	// Later attempts waited for their retry delay, not for a worker
+	if retryCount == 0 && !payload.CreatedAt.IsZero() {
+		observability.TaskQueueWait.WithLabelValues(taskType).Observe(time.Since(payload.CreatedAt).Seconds())
+	}
+
+	// Everything the handler logs through its context is kept as the log of
+	// this attempt; it is written before the status changes, so a finished
+	// task's log is complete
+	rec := tasklog.NewRecorder(p.logRepo, p.db, p.taskLogs, payload.TaskID, int(retryCount)+1)
+	ctx := rec.Context(trace.ContextWithSpan(p.handlerCtx, span), log)
+
+	started := time.Now()
+	res, taskErr := p.handleTask(ctx, payload, id)
+
+	// Files are stored before the status changes, so a SUCCESS task always
+	// has its artifacts in the store. A broken result fails the task.
+	var saved *savedResult
+	var saveErr error
+	if taskErr == nil {
+		saved, saveErr = p.saveResult(payload, res)
+		if errors.Is(saveErr, errInvalidResult) {
+			taskErr, saveErr = saveErr, nil
 		}
+	}
+	aborted := p.handlerCtx.Err() != nil
+	observability.TaskDuration.WithLabelValues(taskType, attemptOutcome(taskErr, saveErr, aborted)).Observe(time.Since(started).Seconds())
+	logOutcome(ctx, taskErr, saveErr, aborted)
+	rec.Close()
+	if taskErr != nil {
+		observability.RecordError(span, taskErr)
+	} else if saveErr != nil {
+		observability.RecordError(span, saveErr)
+	}
 
-		taskErr := handleTask(&payload, id)
+	// Aborted by shutdown: hand the task back to the broker untouched so
+	// another worker picks it up after the restart.
+	if (taskErr != nil || saveErr != nil) && aborted {
+		log.Warnf("Worker %d: Task %d interrupted by shutdown, requeuing", id, payload.TaskID)
+		if err := p.updateStatus(traceCtx, func(ctx context.Context, tx *sql.Tx) error {
+			return p.repo.MarkPending(ctx, tx, payload.TaskID)
+		}); err != nil {
+			log.WithError(err).Error("Failed to reset interrupted task to PENDING")
+		}
+		p.invalidateCache(payload)
+		if err := d.Nack(true); err != nil {
+			log.WithError(err).Warn("Failed to nack interrupted message for requeue")
+		}
+		return
+	}
 
-		// Transaction 2: Mark as SUCCESS or FAILED
-		if err := utils.WithTransaction(db, func(tx *sql.Tx) error {
+	// Transaction 2: Mark as SUCCESS (with the result) or FAILED. A
+	// temporary failure takes the retry path instead of failing the task.
+	err = saveErr
+	if isRetryable(taskErr) {
+		err = taskErr
+	} else if err == nil {
+		err = p.updateStatus(traceCtx, func(ctx context.Context, tx *sql.Tx) error {
 			if taskErr != nil {
-				logrus.WithError(taskErr).Error("task failed")
-				return repo.MarkFailed(tx, payload.ID, taskErr.Error())
+				return p.repo.MarkFailed(ctx, tx, payload.TaskID, taskErr.Error())
 			}
-			return repo.MarkSuccess(tx, payload.ID, "result.txt")
-		}); err != nil {
-			logrus.WithError(err).Error("Failed to update task status")
-
-			// Check retry logic
-			if retryCount >= 3 {
-				if err := utils.WithTransaction(db, func(tx *sql.Tx) error {
-					return repo.MarkFailed(tx, payload.ID, "max retries reached")
-				}); err != nil {
-					logrus.WithError(err).Error("Failed to mark task as failed after max retries")
-				}
-				if err := msg.Nack(false, false); err != nil {
-					logrus.WithError(err).Warn("Failed to nack message after max retries")
+			for _, a := range saved.artifacts {
+				if err := p.repo.AddArtifact(ctx, tx, a); err != nil {
+					return err
 				}
-				continue
 			}
+			return p.repo.MarkSuccess(ctx, tx, payload.TaskID, saved.inline, saved.resultFile)
+		})
+	}
+	if err != nil {
+		// Another delivery of the same task recorded its outcome first
+		if isSettled(err) {
+			log.WithError(err).Warnf("Worker %d: Discarding outcome of task %d", id, payload.TaskID)
+			p.invalidateCache(payload)
+			if err := d.Ack(); err != nil {
+				log.WithError(err).Warn("Failed to ack message")
+			}
+			return
+		}
+		reason := "max retries reached"
+		if isRetryable(taskErr) {
+			log.WithError(err).Warnf("Worker %d: Task %d failed temporarily", id, payload.TaskID)
+			reason += ": " + taskErr.Error()
+		} else {
+			log.WithError(err).Error("Failed to record task outcome")
+		}
 
-			logrus.Infof("Worker %d: Task failed, requeuing (retry %d/3)", id, retryCount+1)
-
-			if err := republishWithRetry(ch, &msg, retryCount+1); err != nil {
-				logrus.WithError(err).Error("Failed to republish message")
-				if err := msg.Nack(false, false); err != nil {
-					logrus.WithError(err).Warn("Failed to nack message after republish error")
-				}
-				continue
+		// Check retry logic
+		if retryCount >= 3 {
+			if err := p.updateStatus(traceCtx, func(ctx context.Context, tx *sql.Tx) error {
+				return p.repo.MarkFailed(ctx, tx, payload.TaskID, reason)
+			}); err != nil {
+				log.WithError(err).Error("Failed to mark task as failed after max retries")
+			} else {
+				observability.TasksFinished.WithLabelValues(taskType, "failed").Inc()
 			}
+			p.invalidateCache(payload)
+			if err := d.Nack(false); err != nil {
+				log.WithError(err).Warn("Failed to nack message after max retries")
+			}
+			return
+		}
 
-			if err := msg.Ack(false); err != nil {
-				logrus.WithError(err).Warn("Failed to ack message after republish")
+		log.Infof("Worker %d: Task failed, requeuing (retry %d/3)", id, retryCount+1)
+
+		if err := p.republishWithRetry(traceCtx, msg, payload, retryCount+1); err != nil {
+			log.WithError(err).Error("Failed to republish message")
+			if err := d.Nack(false); err != nil {
+				log.WithError(err).Warn("Failed to nack message after republish error")
 			}
-			continue
+			return
 		}
+		observability.TaskRetries.WithLabelValues(taskType).Inc()
 
-		if err := msg.Ack(false); err != nil {
-			logrus.WithError(err).Warn("Failed to ack message")
+		if err := d.Ack(); err != nil {
+			log.WithError(err).Warn("Failed to ack message after republish")
 		}
+		return
+	}
+
+	status := "success"
+	if taskErr != nil {
+		status = "failed"
+	}
+	observability.TasksFinished.WithLabelValues(taskType, status).Inc()
+	p.invalidateCache(payload)
+
+	if err := d.Ack(); err != nil {
+		log.WithError(err).Warn("Failed to ack message")
 	}
 }
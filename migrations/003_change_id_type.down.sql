@@ -1,5 +1,3 @@
-BEGIN;
-
 -- 1. Lepas primary key integer
 ALTER TABLE tasks
 DROP CONSTRAINT tasks_pkey;
@@ -19,5 +17,3 @@ DROP COLUMN id;
 -- 5. Rename kembali ke id
 ALTER TABLE tasks
 RENAME COLUMN id_old TO id;
-
-COMMIT;
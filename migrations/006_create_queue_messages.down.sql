@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_queue_messages_ready;
+DROP TABLE IF EXISTS queue_messages;
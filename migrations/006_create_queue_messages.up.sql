@@ -0,0 +1,17 @@
+-- Backing table for QUEUE_BACKEND=postgres
+CREATE TABLE queue_messages (
+    id BIGSERIAL PRIMARY KEY,
+    queue VARCHAR(100) NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    message_type TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA NOT NULL,
+    available_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by TEXT,
+    locked_until TIMESTAMP,
+    delivery_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_queue_messages_ready ON queue_messages(queue, available_at, id);
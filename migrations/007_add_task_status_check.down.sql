@@ -0,0 +1,2 @@
+ALTER TABLE tasks
+DROP CONSTRAINT IF EXISTS tasks_status_check;
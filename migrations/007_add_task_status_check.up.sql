@@ -0,0 +1,4 @@
+-- Status values are owned by task.Status; transitions are enforced by the repository
+ALTER TABLE tasks
+ADD CONSTRAINT tasks_status_check
+CHECK (status IN ('PENDING', 'PROCESSING', 'SUCCESS', 'FAILED'));
@@ -0,0 +1,15 @@
+-- Small JSON results are stored on the task itself
+ALTER TABLE tasks
+ADD COLUMN result JSONB;
+
+-- Output files live in the artifact store; this table maps task + name to the stored object
+CREATE TABLE task_artifacts (
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (task_id, name)
+);
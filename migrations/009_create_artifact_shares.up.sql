@@ -0,0 +1,16 @@
+-- Signed public download links for task artifacts. The URL carries an
+-- HMAC over id and expiry; this row makes links revocable and single-use.
+CREATE TABLE artifact_shares (
+    id TEXT PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    artifact_name TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    single_use BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    used_at TIMESTAMPTZ,
+    download_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_artifact_shares_task ON artifact_shares(task_id);
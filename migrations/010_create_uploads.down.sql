@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS task_uploads;
+DROP TABLE IF EXISTS uploads;
@@ -0,0 +1,22 @@
+-- Input files uploaded for tasks; the bytes live in the artifact store
+CREATE TABLE uploads (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_uploads_created_at ON uploads(created_at);
+
+-- Uploads referenced from a task's params. An upload no unfinished task
+-- refers to is garbage collected once it is old enough.
+CREATE TABLE task_uploads (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    upload_id TEXT NOT NULL REFERENCES uploads(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, upload_id)
+);
+
+CREATE INDEX idx_task_uploads_upload ON task_uploads(upload_id);
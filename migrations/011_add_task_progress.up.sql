@@ -0,0 +1,3 @@
+-- Percentage reported by long running handlers, NULL when a task reports none
+ALTER TABLE tasks ADD COLUMN progress SMALLINT
+    CONSTRAINT tasks_progress_check CHECK (progress BETWEEN 0 AND 100);
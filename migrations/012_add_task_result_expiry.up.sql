@@ -0,0 +1,2 @@
+-- Set when cleanup_temp removed the result and artifacts of a finished task
+ALTER TABLE tasks ADD COLUMN result_expired_at TIMESTAMPTZ;
@@ -0,0 +1,12 @@
+-- Lines logged by task handlers, kept per attempt and removed with their task
+CREATE TABLE task_logs (
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    level TEXT NOT NULL,
+    message TEXT NOT NULL,
+    fields JSONB,
+    logged_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_task_logs_task ON task_logs(task_id, id);
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations into every binary.
+// Files are named NNN_description.up.sql / NNN_description.down.sql; each
+// version number must be unique. The runner wraps every file in a
+// transaction, so files must not contain BEGIN/COMMIT themselves.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
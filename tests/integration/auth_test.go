@@ -27,7 +27,7 @@ func TestAuth_RegisterLoginFlow(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 	username := fmt.Sprintf("testuser_%d", time.Now().UnixNano())
 	password := "SecurePass123!"
@@ -143,7 +143,7 @@ func TestAuth_ValidationErrors(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 	t.Run("Register_ShortUsername", func(t *testing.T) {
 		payload := map[string]string{"username": "ab", "password": "password123"}
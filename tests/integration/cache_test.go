@@ -22,7 +22,7 @@ func TestCache_TaskCaching(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 	username := fmt.Sprintf("cacheuser_%d", time.Now().UnixNano())
 	password := "CachePass123!"
@@ -94,7 +94,7 @@ func TestCache_UserTasksCaching(t *testing.T) {
 env := SetupTestEnv(t)
 defer env.Cleanup(t)
 
-router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 username := fmt.Sprintf("listcache_%d", time.Now().UnixNano())
 password := "ListCache123!"
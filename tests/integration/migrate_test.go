@@ -0,0 +1,136 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"task_handler/internal/migrate"
+	"task_handler/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrate_Runner exercises the embedded runner against the test database
+func TestMigrate_Runner(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	runner, err := migrate.New(env.DB)
+	require.NoError(t, err)
+
+	status, err := runner.Status(ctx)
+	require.NoError(t, err)
+	latest := status[len(status)-1].Version
+
+	t.Run("UpIsIdempotent", func(t *testing.T) {
+		n, err := runner.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		version, err := runner.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+	})
+
+	t.Run("DownThenUp", func(t *testing.T) {
+		n, err := runner.Down(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		version, err := runner.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest-1, version)
+
+		n, err = runner.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("ConcurrentUp", func(t *testing.T) {
+		_, err := runner.Down(ctx, 1)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- migrate.Apply(ctx, env.DB)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		version, err := runner.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+	})
+
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		// Same files, but the first applied migration edited after the fact
+		edited := fstest.MapFS{}
+		entries, err := fs.ReadDir(migrations.FS, ".")
+		require.NoError(t, err)
+		for _, entry := range entries {
+			data, err := fs.ReadFile(migrations.FS, entry.Name())
+			require.NoError(t, err)
+			edited[entry.Name()] = &fstest.MapFile{Data: data}
+		}
+		first := status[0]
+		name := fmt.Sprintf("%03d_%s.up.sql", first.Version, first.Name)
+		edited[name].Data = append(edited[name].Data, []byte("\n-- edited\n")...)
+
+		list, err := migrate.Load(edited)
+		require.NoError(t, err)
+
+		_, err = migrate.NewRunner(env.DB, list).Up(ctx)
+		assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+
+		status, err := migrate.NewRunner(env.DB, list).Status(ctx)
+		require.NoError(t, err)
+		assert.True(t, status[0].Modified)
+	})
+	t.Run("AdoptsGolangMigrate", func(t *testing.T) {
+		// A database the migrate/migrate container left at version 3,
+		// 003_create_users included
+		_, err := runner.Down(ctx, latest-4)
+		require.NoError(t, err)
+		_, err = env.DB.ExecContext(ctx, `
+			DROP TABLE schema_migration_history;
+			CREATE TABLE schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL);
+			INSERT INTO schema_migrations (version, dirty) VALUES (3, true);
+		`)
+		require.NoError(t, err)
+		defer env.DB.Exec(`DROP TABLE IF EXISTS schema_migrations`)
+
+		_, err = runner.Up(ctx)
+		assert.ErrorIs(t, err, migrate.ErrLegacyState)
+
+		_, err = env.DB.ExecContext(ctx, `UPDATE schema_migrations SET dirty = false`)
+		require.NoError(t, err)
+
+		n, err := runner.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest-4, n)
+
+		version, err := runner.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, latest, version)
+	})
+}
@@ -0,0 +1,91 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task_handler/internal/cache"
+	"task_handler/internal/handler"
+	"task_handler/internal/middleware"
+	"task_handler/internal/task"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOrchestrator_CreateProcessStatus runs the API and an embedded worker
+// pool the way cmd/orchestrator does, with Postgres as the only service
+func TestOrchestrator_CreateProcessStatus(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Limiter:   middleware.NewMemoryLimiter(),
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	pool := worker.NewPool(env.Broker, env.DB, task.NewTaskRepository(), env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	token, _ := createUserAndLogin(t, router)
+
+	payload := map[string]any{
+		"task_type": "send_email",
+		"params": map[string]any{
+			"to":       "user@example.com",
+			"template": "welcome",
+			"data":     map[string]any{"name": "User"},
+		},
+	}
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var createResp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+	taskID := int(createResp["task_id"].(float64))
+
+	// Reads in between populate the cache; the worker must invalidate it
+	status := ""
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		status = resp["status"].(string)
+		if status == "SUCCESS" || status == "FAILED" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	assert.Equal(t, "SUCCESS", status)
+}
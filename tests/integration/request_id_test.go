@@ -0,0 +1,57 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task_handler/internal/handler"
+	"task_handler/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequestID_ReachesTaskMessage checks that the X-Request-ID of the
+// request creating a task travels with its queue message
+func TestRequestID_ReachesTaskMessage(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	token, _ := createUserAndLogin(t, router)
+
+	body, _ := json.Marshal(map[string]string{"task_type": "generate_report"})
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Request-ID", "integration-req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "integration-req-1", w.Header().Get("X-Request-ID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	deliveries, err := env.Broker.Consume(ctx, queue.TaskQueue, "request-id-test")
+	require.NoError(t, err)
+
+	select {
+	case d := <-deliveries:
+		assert.Equal(t, "integration-req-1", d.Message().Headers[queue.HeaderRequestID])
+		require.NoError(t, d.Ack())
+	case <-ctx.Done():
+		t.Fatal("Task message was not published")
+	}
+}
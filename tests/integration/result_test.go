@@ -0,0 +1,243 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/cache"
+	"task_handler/internal/handler"
+	"task_handler/internal/task"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForTaskStatus polls the task until it reaches a final status
+func waitForTaskStatus(t *testing.T, router http.Handler, token string, taskID int, timeout time.Duration) string {
+	t.Helper()
+
+	status := ""
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		status = resp["status"].(string)
+		if status == "SUCCESS" || status == "FAILED" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return status
+}
+
+// TestResult_ReportArtifact runs a generate_report task through an
+// embedded worker pool and downloads its result and artifact
+func TestResult_ReportArtifact(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	pool := worker.NewPool(env.Broker, env.DB, task.NewTaskRepository(), env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	token, _ := createUserAndLogin(t, router)
+	otherToken, _ := createUserAndLogin(t, router)
+
+	body, _ := json.Marshal(map[string]string{"task_type": "generate_report"})
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var createResp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &createResp))
+	taskID := int(createResp["task_id"].(float64))
+
+	t.Run("ResultBeforeCompletion", func(t *testing.T) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/result", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, token, taskID, 15*time.Second))
+
+	t.Run("Result", func(t *testing.T) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/result", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Result    map[string]interface{}   `json:"result"`
+			Artifacts []map[string]interface{} `json:"artifacts"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		// The report task itself is not part of the report
+		assert.Equal(t, float64(0), resp.Result["rows"])
+		assert.Equal(t, "csv", resp.Result["format"])
+		require.Len(t, resp.Artifacts, 1)
+		assert.Equal(t, "report.csv", resp.Artifacts[0]["name"])
+		assert.Equal(t, "text/csv", resp.Artifacts[0]["content_type"])
+	})
+
+	t.Run("DownloadArtifact", func(t *testing.T) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/artifacts/report.csv", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "task_id,task_type,status,created_at,"))
+	})
+
+	t.Run("MissingArtifact", func(t *testing.T) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/artifacts/other.csv", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("OtherUserForbidden", func(t *testing.T) {
+		for _, path := range []string{"result", "artifacts/report.csv"} {
+			req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/%s", taskID, path), nil)
+			req.Header.Set("Authorization", "Bearer "+otherToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code, path)
+		}
+	})
+}
+
+// TestResult_ReportContents reports on a user's task history as JSON and PDF
+func TestResult_ReportContents(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	token, userID := createUserAndLogin(t, router)
+	otherToken, _ := createUserAndLogin(t, router)
+
+	// History: one finished and one failed task, plus another user's task
+	repo := task.NewTaskRepository()
+	ctx := context.Background()
+	createSucceededTask(t, env, userID, "report.csv", "a\n")
+	createTaskWithParams(t, router, token, "send_email", nil)
+	createTaskWithParams(t, router, otherToken, "send_email", nil)
+
+	_, err := env.DB.Exec(`UPDATE tasks SET status = 'FAILED', error_message = 'boom'
+		WHERE user_id = $1 AND status = 'PENDING'`, userID)
+	require.NoError(t, err)
+
+	// The failed task's message is discarded by the pool, its status is final
+	pool := worker.NewPool(env.Broker, env.DB, repo, env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	t.Run("JSON", func(t *testing.T) {
+		code, taskID := createTaskWithParams(t, router, token, "generate_report", map[string]interface{}{"format": "json"})
+		require.Equal(t, http.StatusCreated, code)
+		require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, token, taskID, 15*time.Second))
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/artifacts/report.json", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var doc struct {
+			UserID  int                `json:"user_id"`
+			Summary task.ReportSummary `json:"summary"`
+			Tasks   []struct {
+				TaskID int    `json:"task_id"`
+				Status string `json:"status"`
+			} `json:"tasks"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, userID, doc.UserID)
+		assert.Equal(t, 2, doc.Summary.Total)
+		assert.Equal(t, 1, doc.Summary.ByStatus[task.StatusFailed])
+		require.Len(t, doc.Summary.FailureReasons, 1)
+		assert.Equal(t, "boom", doc.Summary.FailureReasons[0].Message)
+		assert.Len(t, doc.Tasks, 2)
+
+		stored, err := repo.GetByID(ctx, env.DB, taskID)
+		require.NoError(t, err)
+		if stored.Progress != nil {
+			assert.Equal(t, 100, *stored.Progress)
+		}
+	})
+
+	t.Run("PDF", func(t *testing.T) {
+		code, taskID := createTaskWithParams(t, router, token, "generate_report", map[string]interface{}{"format": "pdf"})
+		require.Equal(t, http.StatusCreated, code)
+		require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, token, taskID, 15*time.Second))
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/artifacts/report.pdf", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasPrefix(w.Body.String(), "%PDF-"))
+		assert.True(t, strings.HasSuffix(w.Body.String(), "%%EOF\n"))
+	})
+
+	t.Run("InvalidRange", func(t *testing.T) {
+		code, taskID := createTaskWithParams(t, router, token, "generate_report", map[string]interface{}{
+			"from": "2025-02-01", "to": "2025-01-01",
+		})
+		require.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, "FAILED", waitForTaskStatus(t, router, token, taskID, 15*time.Second))
+	})
+}
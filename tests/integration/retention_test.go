@@ -0,0 +1,155 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/handler"
+	"task_handler/internal/retention"
+	"task_handler/internal/task"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetention_Cleanup expires old report results, keeps those of a user
+// with a longer rule and removes an orphaned object, after a dry run that
+// changes nothing
+func TestRetention_Cleanup(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	pool := worker.NewPool(env.Broker, env.DB, task.NewTaskRepository(), env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	token, user := createUserAndLogin(t, router)
+	keptToken, keptUser := createUserAndLogin(t, router)
+
+	_, expiredID := createTaskWithParams(t, router, token, "generate_report", nil)
+	_, keptID := createTaskWithParams(t, router, keptToken, "generate_report", nil)
+	require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, token, expiredID, 15*time.Second))
+	require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, keptToken, keptID, 15*time.Second))
+
+	_, err := env.DB.Exec(`UPDATE tasks SET updated_at = NOW() - INTERVAL '3 days' WHERE id = ANY($1)`, []int64{int64(expiredID), int64(keptID)})
+	require.NoError(t, err)
+
+	// An object whose task row is gone, old enough to be past the grace
+	orphan := "tasks/987654/stale.csv"
+	require.NoError(t, env.Artifacts.Put(context.Background(), orphan, strings.NewReader("x"), 1, "text/csv"))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(env.Config.Artifact.Dir, filepath.FromSlash(orphan)), old, old))
+
+	retentionCfg := config.RetentionConfig{
+		Results:       30 * 24 * time.Hour,
+		ResultsByType: map[string]time.Duration{"generate_report": 24 * time.Hour},
+		ByUser:        map[int]time.Duration{keptUser: 0},
+		Uploads:       time.Hour,
+		OrphanGrace:   time.Hour,
+		BatchSize:     1,
+		MaxPerRun:     100,
+	}
+	cleaner := retention.NewCleaner(retention.NewRetentionRepository(), env.DB, env.Artifacts, retentionCfg)
+	cleaner.SetCache(taskCache)
+
+	getResult := func(token string, id int) int {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/result", id), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("ScopedToUser", func(t *testing.T) {
+		scoped := retention.NewCleaner(retention.NewRetentionRepository(), env.DB, env.Artifacts, retentionCfg)
+
+		scoped.SetUser(keptUser)
+		report, err := scoped.Run(context.Background(), true)
+		require.NoError(t, err)
+		assert.Empty(t, report.Results.Items)
+		assert.Empty(t, report.Orphans.Items)
+
+		scoped.SetUser(user)
+		report, err = scoped.Run(context.Background(), true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{fmt.Sprint(expiredID)}, report.Results.Items)
+		assert.Empty(t, report.Orphans.Items)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := cleaner.Run(context.Background(), true)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{fmt.Sprint(expiredID)}, report.Results.Items)
+		assert.Equal(t, []string{orphan}, report.Orphans.Items)
+		assert.Equal(t, http.StatusOK, getResult(token, expiredID))
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		report, err := cleaner.Run(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{fmt.Sprint(expiredID)}, report.Results.Items)
+		assert.Greater(t, report.Results.Bytes, int64(0))
+
+		assert.Equal(t, http.StatusGone, getResult(token, expiredID))
+		assert.Equal(t, http.StatusOK, getResult(keptToken, keptID))
+
+		_, err = env.Artifacts.Get(context.Background(), fmt.Sprintf("tasks/%d/report.csv", expiredID))
+		assert.Error(t, err)
+		_, err = env.Artifacts.Get(context.Background(), orphan)
+		assert.Error(t, err)
+
+		// Nothing is left to do
+		report, err = cleaner.Run(context.Background(), false)
+		require.NoError(t, err)
+		assert.Zero(t, report.Results.Count+report.Uploads.Count+report.Orphans.Count)
+	})
+}
+
+// TestRetention_Sweep runs the cleaner the worker binaries start, which
+// removes orphaned objects that no cleanup_temp task can reach
+func TestRetention_Sweep(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	orphan := "uploads/collected-but-left-behind"
+	require.NoError(t, env.Artifacts.Put(context.Background(), orphan, strings.NewReader("x"), 1, "text/plain"))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(env.Config.Artifact.Dir, filepath.FromSlash(orphan)), old, old))
+
+	cfg := env.Config.Retention
+	cfg.OrphanGrace = time.Hour
+	cfg.Interval = time.Hour
+	stop := retention.Setup(&cfg, env.DB, env.Artifacts, cache.NewTaskCache(cache.NewMemoryStore())).Start()
+	defer stop()
+
+	assert.Eventually(t, func() bool {
+		_, err := env.Artifacts.Get(context.Background(), orphan)
+		return err != nil
+	}, 10*time.Second, 50*time.Millisecond)
+}
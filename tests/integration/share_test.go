@@ -0,0 +1,169 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task_handler/internal/artifact"
+	"task_handler/internal/handler"
+	"task_handler/internal/task"
+	"task_handler/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createSucceededTask stores a SUCCESS task for userID with one artifact,
+// as a worker would
+func createSucceededTask(t *testing.T, env *TestEnv, userID int, name, body string) int {
+	t.Helper()
+	ctx := context.Background()
+	repo := task.NewTaskRepository()
+
+	tk := &task.Task{UserID: userID, TaskType: "generate_report", Status: task.StatusPending}
+	require.NoError(t, utils.WithTransaction(ctx, env.DB, func(tx *sql.Tx) error {
+		id, err := repo.Create(ctx, tx, tk)
+		tk.ID = id
+		return err
+	}))
+
+	key := artifact.TaskKey(tk.ID, name)
+	require.NoError(t, env.Artifacts.Put(ctx, key, strings.NewReader(body), int64(len(body)), "text/csv"))
+
+	require.NoError(t, utils.WithTransaction(ctx, env.DB, func(tx *sql.Tx) error {
+		if err := repo.MarkProcessing(ctx, tx, tk.ID); err != nil {
+			return err
+		}
+		if err := repo.AddArtifact(ctx, tx, &task.Artifact{
+			TaskID: tk.ID, Name: name, StorageKey: key, ContentType: "text/csv", Size: int64(len(body)),
+		}); err != nil {
+			return err
+		}
+		return repo.MarkSuccess(ctx, tx, tk.ID, nil, &name)
+	}))
+
+	return tk.ID
+}
+
+// createShare calls the share endpoint and returns the decoded response
+func createShare(t *testing.T, router http.Handler, token string, taskID int, payload map[string]interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/tasks/%d/artifacts/report.csv/share", taskID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func download(router http.Handler, link string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", link, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestShare_Links covers creating, using and revoking share links
+func TestShare_Links(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	router := handler.SetupHandler(env.DB, env.Broker, nil, env.Config)
+	token, userID := createUserAndLogin(t, router)
+	otherToken, _ := createUserAndLogin(t, router)
+
+	taskID := createSucceededTask(t, env, userID, "report.csv", "a,b\n1,2\n")
+
+	t.Run("MultiUse", func(t *testing.T) {
+		code, resp := createShare(t, router, token, taskID, map[string]interface{}{"expires_in": 600})
+		require.Equal(t, http.StatusCreated, code)
+		link := resp["url"].(string)
+
+		for i := 0; i < 2; i++ {
+			w := download(router, link)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "a,b\n1,2\n", w.Body.String())
+		}
+	})
+
+	t.Run("SingleUse", func(t *testing.T) {
+		code, resp := createShare(t, router, token, taskID, map[string]interface{}{"single_use": true})
+		require.Equal(t, http.StatusCreated, code)
+		link := resp["url"].(string)
+
+		assert.Equal(t, http.StatusOK, download(router, link).Code)
+		assert.Equal(t, http.StatusGone, download(router, link).Code)
+	})
+
+	t.Run("TamperedLink", func(t *testing.T) {
+		code, resp := createShare(t, router, token, taskID, nil)
+		require.Equal(t, http.StatusCreated, code)
+		link := resp["url"].(string)
+
+		// Pushing the expiry out invalidates the signature
+		tampered := strings.Replace(link, "expires=", "expires=9", 1)
+		assert.Equal(t, http.StatusForbidden, download(router, tampered).Code)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		code, resp := createShare(t, router, token, taskID, nil)
+		require.Equal(t, http.StatusCreated, code)
+		link := resp["url"].(string)
+		shareID := resp["share_id"].(string)
+
+		// Only the owner may revoke
+		req := httptest.NewRequest("DELETE", "/api/v1/shares/"+shareID, nil)
+		req.Header.Set("Authorization", "Bearer "+otherToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest("DELETE", "/api/v1/shares/"+shareID, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.Equal(t, http.StatusGone, download(router, link).Code)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/shares", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, float64(4), resp["count"])
+	})
+
+	t.Run("OtherUserCannotShare", func(t *testing.T) {
+		code, _ := createShare(t, router, otherToken, taskID, nil)
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("UnknownArtifact", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{})
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/tasks/%d/artifacts/missing.csv/share", taskID), bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,126 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"task_handler/internal/queue"
+	"task_handler/internal/task"
+	"task_handler/internal/utils"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestTask inserts a PENDING task owned by a fresh user
+func createTestTask(t *testing.T, database *sql.DB, repo task.TaskRepositoryInterface) *task.Task {
+	t.Helper()
+	ctx := context.Background()
+
+	var userID int
+	err := database.QueryRowContext(ctx,
+		`INSERT INTO users (username, password) VALUES ($1, 'x') RETURNING id`,
+		"state_"+time.Now().Format("150405.000000000"),
+	).Scan(&userID)
+	require.NoError(t, err)
+
+	tk := &task.Task{UserID: userID, TaskType: "send_email", Status: task.StatusPending}
+	require.NoError(t, utils.WithTransaction(ctx, database, func(tx *sql.Tx) error {
+		id, err := repo.Create(ctx, tx, tk)
+		tk.ID = id
+		return err
+	}))
+	return tk
+}
+
+// TestTaskState_ConditionalTransitions checks the repository against the transition table
+func TestTaskState_ConditionalTransitions(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	repo := task.NewTaskRepository()
+	ctx := context.Background()
+	tk := createTestTask(t, env.DB, repo)
+
+	step := func(fn func(tx *sql.Tx) error) error {
+		return utils.WithTransaction(ctx, env.DB, fn)
+	}
+
+	t.Run("PendingToSuccessRejected", func(t *testing.T) {
+		err := step(func(tx *sql.Tx) error { return repo.MarkSuccess(ctx, tx, tk.ID, nil, nil) })
+		require.ErrorIs(t, err, task.ErrInvalidTransition)
+
+		var transitionErr *task.TransitionError
+		require.True(t, errors.As(err, &transitionErr))
+		assert.Equal(t, task.StatusPending, transitionErr.From)
+	})
+
+	t.Run("HappyPath", func(t *testing.T) {
+		require.NoError(t, step(func(tx *sql.Tx) error { return repo.MarkProcessing(ctx, tx, tk.ID) }))
+		require.NoError(t, step(func(tx *sql.Tx) error { return repo.MarkSuccess(ctx, tx, tk.ID, nil, nil) }))
+	})
+
+	t.Run("TerminalCannotRestart", func(t *testing.T) {
+		err := step(func(tx *sql.Tx) error { return repo.MarkProcessing(ctx, tx, tk.ID) })
+		require.ErrorIs(t, err, task.ErrInvalidTransition)
+
+		got, err := repo.GetByID(ctx, env.DB, tk.ID)
+		require.NoError(t, err)
+		assert.Equal(t, task.StatusSuccess, got.Status)
+	})
+
+	t.Run("MissingTask", func(t *testing.T) {
+		err := step(func(tx *sql.Tx) error { return repo.MarkProcessing(ctx, tx, 987654321) })
+		assert.ErrorIs(t, err, task.ErrTaskNotFound)
+	})
+}
+
+// TestTaskState_RedeliveredTerminalTaskIsAcked checks that a worker settles
+// a redelivered message without touching a finished task
+func TestTaskState_RedeliveredTerminalTaskIsAcked(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	repo := task.NewTaskRepository()
+	ctx := context.Background()
+	tk := createTestTask(t, env.DB, repo)
+
+	require.NoError(t, utils.WithTransaction(ctx, env.DB, func(tx *sql.Tx) error {
+		if err := repo.MarkProcessing(ctx, tx, tk.ID); err != nil {
+			return err
+		}
+		return repo.MarkSuccess(ctx, tx, tk.ID, nil, nil)
+	}))
+
+	envelope := task.NewEnvelope(tk)
+	body, err := envelope.Marshal()
+	require.NoError(t, err)
+
+	broker := env.Broker.(*queue.MemoryBroker)
+	require.NoError(t, broker.Publish(ctx, queue.TaskQueue, &queue.Message{ID: envelope.MessageID, Body: body}))
+
+	pool := worker.NewPool(broker, env.DB, repo, env.Artifacts, 1)
+	pool.Start()
+
+	// Wait until the message is taken; Shutdown then waits for it to be settled
+	require.Eventually(t, func() bool {
+		return broker.Len(queue.TaskQueue) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, pool.Shutdown(shutdownCtx))
+
+	assert.Equal(t, 0, broker.Len(queue.TaskQueue), "message should be acked, not requeued")
+	assert.Equal(t, 0, broker.Len(queue.DeadLetterQueue(queue.TaskQueue)), "message should be acked, not dead-lettered")
+
+	got, err := repo.GetByID(ctx, env.DB, tk.ID)
+	require.NoError(t, err)
+	assert.Equal(t, task.StatusSuccess, got.Status)
+}
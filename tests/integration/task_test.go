@@ -56,7 +56,7 @@ func TestTask_CRUD(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 	token, _ := createUserAndLogin(t, router)
 
 	var taskID int
@@ -141,7 +141,7 @@ func TestTask_Ownership(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 	// Create two users
 	token1, _ := createUserAndLogin(t, router)
@@ -201,7 +201,7 @@ func TestTask_Unauthorized(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 
 	t.Run("NoToken", func(t *testing.T) {
 req := httptest.NewRequest("GET", "/api/v1/users/tasks", nil)
@@ -235,7 +235,7 @@ func TestTask_AllTaskTypes(t *testing.T) {
 	env := SetupTestEnv(t)
 	defer env.Cleanup(t)
 
-	router := handler.SetupHandler(env.DB, env.RabbitConn, env.RedisClient, env.Config)
+	router := handler.SetupHandler(env.DB, env.Broker, env.RedisClient, env.Config)
 	token, _ := createUserAndLogin(t, router)
 
 	taskTypes := []string{"send_email", "generate_report", "resize_image", "cleanup_temp"}
@@ -0,0 +1,146 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task_handler/internal/cache"
+	"task_handler/internal/handler"
+	"task_handler/internal/task"
+	"task_handler/internal/tasklog"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTaskLog_Repository checks that lines keep their order and that the
+// cursor and attempt filters apply
+func TestTaskLog_Repository(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	ctx := context.Background()
+	_, userID := createUserAndLogin(t, handler.NewRouter(&handler.Services{DB: env.DB, Broker: env.Broker, Artifacts: env.Artifacts}, env.Config))
+	var taskID int
+	require.NoError(t, env.DB.QueryRowContext(ctx,
+		`INSERT INTO tasks (user_id, task_type, status) VALUES ($1, 'generate_report', 'PENDING') RETURNING id`,
+		userID).Scan(&taskID))
+
+	repo := tasklog.NewTaskLogRepository()
+	now := time.Now()
+	require.NoError(t, repo.Append(ctx, env.DB, taskID, 1, []*tasklog.Line{
+		{Level: "info", Message: "one", LoggedAt: now},
+		{Level: "warning", Message: "two", Fields: json.RawMessage(`{"n": 2}`), LoggedAt: now},
+	}))
+	require.NoError(t, repo.Append(ctx, env.DB, taskID, 2, []*tasklog.Line{
+		{Level: "info", Message: "three", LoggedAt: now},
+	}))
+
+	all, err := repo.List(ctx, env.DB, taskID, 0, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "one", all[0].Message)
+	assert.Equal(t, "two", all[1].Message)
+	assert.JSONEq(t, `{"n": 2}`, string(all[1].Fields))
+	assert.Equal(t, "three", all[2].Message)
+
+	after, err := repo.List(ctx, env.DB, taskID, 0, all[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.Equal(t, "two", after[0].Message)
+
+	second, err := repo.List(ctx, env.DB, taskID, 2, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, 2, second[0].Attempt)
+}
+
+// TestTaskLog_Endpoint runs a task through an embedded worker pool and
+// reads its log back, paged and followed
+func TestTaskLog_Endpoint(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	pool := worker.NewPool(env.Broker, env.DB, task.NewTaskRepository(), env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.SetTaskLogConfig(env.Config.TaskLog)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	token, _ := createUserAndLogin(t, router)
+	otherToken, _ := createUserAndLogin(t, router)
+
+	body, _ := json.Marshal(map[string]string{"task_type": "generate_report"})
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	taskID := int(created["task_id"].(float64))
+
+	get := func(token, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/logs%s", taskID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Follow", func(t *testing.T) {
+		w := get(token, "?follow=true")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var last tasklog.Line
+		rows := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.NoError(t, json.Unmarshal([]byte(rows[len(rows)-1]), &last))
+		assert.Equal(t, "Task finished", last.Message)
+	})
+
+	t.Run("Page", func(t *testing.T) {
+		w := get(token, "?limit=1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Status    string          `json:"status"`
+			Logs      []*tasklog.Line `json:"logs"`
+			NextAfter int64           `json:"next_after"`
+			HasMore   bool            `json:"has_more"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "SUCCESS", resp.Status)
+		require.Len(t, resp.Logs, 1)
+		assert.Equal(t, 1, resp.Logs[0].Attempt)
+		assert.Equal(t, resp.Logs[0].ID, resp.NextAfter)
+		assert.True(t, resp.HasMore)
+	})
+
+	t.Run("OtherUser", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get(otherToken, "").Code)
+	})
+}
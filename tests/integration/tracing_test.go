@@ -0,0 +1,90 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task_handler/internal/handler"
+	"task_handler/internal/observability"
+	"task_handler/internal/queue"
+	"task_handler/internal/task"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestTracing_ReachesTaskMessage checks that creating a task is traced from
+// the request to the database and the queue, and that the published message
+// carries the trace on to the worker
+func TestTracing_ReachesTaskMessage(t *testing.T) {
+	savedProvider, savedPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(savedProvider)
+		otel.SetTextMapPropagator(savedPropagator)
+	}()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	token, _ := createUserAndLogin(t, router)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body, _ := json.Marshal(map[string]string{"task_type": "generate_report"})
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == traceID {
+			names[span.Name()] = true
+		}
+	}
+	assert.True(t, names["POST /api/v1/tasks"])
+	assert.True(t, names["publish "+queue.TaskQueue])
+	assert.True(t, names["postgres INSERT"])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	deliveries, err := env.Broker.Consume(ctx, queue.TaskQueue, "tracing-test")
+	require.NoError(t, err)
+
+	select {
+	case d := <-deliveries:
+		msg := d.Message()
+		consumer := trace.SpanContextFromContext(observability.ExtractHeaders(context.Background(), msg.Headers))
+		assert.Equal(t, traceID, consumer.TraceID().String())
+
+		envelope, err := task.DecodeEnvelope(msg.Body)
+		require.NoError(t, err)
+		assert.Equal(t, msg.Headers["traceparent"], envelope.TraceContext["traceparent"])
+		require.NoError(t, d.Ack())
+	case <-ctx.Done():
+		t.Fatal("Task message was not published")
+	}
+}
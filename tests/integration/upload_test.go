@@ -0,0 +1,226 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task_handler/internal/cache"
+	"task_handler/internal/config"
+	"task_handler/internal/handler"
+	"task_handler/internal/queue"
+	"task_handler/internal/task"
+	"task_handler/internal/upload"
+	"task_handler/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG encodes a solid w x h image
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{200, 50, 50, 255}), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// uploadFile posts content as the multipart field "file"
+func uploadFile(t *testing.T, router http.Handler, token, filename string, content []byte) (int, map[string]interface{}) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", "/api/v1/uploads", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// createTaskWithParams posts a task and returns the status code and task ID
+func createTaskWithParams(t *testing.T, router http.Handler, token, taskType string, params map[string]interface{}) (int, int) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{"task_type": taskType, "params": params})
+	req := httptest.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	id, _ := resp["task_id"].(float64)
+	return w.Code, int(id)
+}
+
+// TestUpload_TaskInput uploads an image, resizes it with a resize_image
+// task and downloads the output
+func TestUpload_TaskInput(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	taskCache := cache.NewTaskCache(cache.NewMemoryStore())
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     taskCache,
+		Artifacts: env.Artifacts,
+	}, env.Config)
+
+	pool := worker.NewPool(env.Broker, env.DB, task.NewTaskRepository(), env.Artifacts, env.Config.Worker.Concurrency)
+	pool.SetCache(taskCache)
+	pool.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.NoError(t, pool.Shutdown(ctx))
+	}()
+
+	token, _ := createUserAndLogin(t, router)
+	otherToken, _ := createUserAndLogin(t, router)
+
+	code, resp := uploadFile(t, router, token, "cat.png", testPNG(t, 300, 200))
+	require.Equal(t, http.StatusCreated, code)
+	uploadID := resp["upload_id"].(string)
+	assert.Equal(t, "image/png", resp["content_type"])
+
+	t.Run("Resolved", func(t *testing.T) {
+		code, taskID := createTaskWithParams(t, router, token, "resize_image", map[string]interface{}{
+			"upload_id": uploadID,
+			"sizes": []map[string]interface{}{
+				{"name": "thumb", "width": 50, "height": 50, "mode": "fill"},
+			},
+		})
+		require.Equal(t, http.StatusCreated, code)
+		require.Equal(t, "SUCCESS", waitForTaskStatus(t, router, token, taskID, 15*time.Second))
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/tasks/%d/artifacts/thumb.png", taskID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		img, format, err := image.Decode(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "png", format)
+		assert.Equal(t, image.Rect(0, 0, 50, 50), img.Bounds())
+	})
+
+	t.Run("OtherUsersUpload", func(t *testing.T) {
+		code, _ := createTaskWithParams(t, router, otherToken, "resize_image", map[string]interface{}{"upload_id": uploadID})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("UnknownUpload", func(t *testing.T) {
+		code, _ := createTaskWithParams(t, router, token, "resize_image", map[string]interface{}{"upload_id": "missing"})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("NotAllowedType", func(t *testing.T) {
+		code, _ := uploadFile(t, router, token, "doc.png", []byte("%PDF-1.7"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	})
+}
+
+// TestUpload_GarbageCollection checks that only uploads no unfinished task
+// refers to are collected
+func TestUpload_GarbageCollection(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    env.Broker,
+		Cache:     cache.NewTaskCache(cache.NewMemoryStore()),
+		Artifacts: env.Artifacts,
+	}, env.Config)
+	token, _ := createUserAndLogin(t, router)
+
+	_, resp := uploadFile(t, router, token, "kept.png", testPNG(t, 10, 10))
+	kept := resp["upload_id"].(string)
+	_, resp = uploadFile(t, router, token, "unused.png", testPNG(t, 10, 10))
+	unused := resp["upload_id"].(string)
+
+	// No worker runs, so the task stays PENDING and keeps its upload alive
+	code, _ := createTaskWithParams(t, router, token, "resize_image", map[string]interface{}{"upload_id": kept})
+	require.Equal(t, http.StatusCreated, code)
+
+	repo := upload.NewUploadRepository()
+	collector := upload.NewCollector(repo, env.DB, env.Artifacts, config.UploadConfig{GCInterval: time.Minute})
+
+	n, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = repo.GetByID(context.Background(), env.DB, unused)
+	assert.ErrorIs(t, err, upload.ErrUploadNotFound)
+	_, err = env.Artifacts.Get(context.Background(), upload.StorageKey(unused))
+	assert.Error(t, err)
+
+	_, err = repo.GetByID(context.Background(), env.DB, kept)
+	assert.NoError(t, err)
+}
+
+// fullBroker refuses every message, as a stream at REDIS_STREAM_MAX_LEN does
+type fullBroker struct {
+	queue.Broker
+}
+
+func (b fullBroker) Publish(ctx context.Context, queueName string, msg *queue.Message) error {
+	return queue.ErrQueueFull
+}
+
+// TestUpload_QueueFullLeavesNoTask checks that a task the queue refuses is
+// not kept, so it neither pins its upload nor piles up on retries
+func TestUpload_QueueFullLeavesNoTask(t *testing.T) {
+	env := SetupStandaloneEnv(t)
+	defer env.Cleanup(t)
+
+	router := handler.NewRouter(&handler.Services{
+		DB:        env.DB,
+		Broker:    fullBroker{env.Broker},
+		Cache:     cache.NewTaskCache(cache.NewMemoryStore()),
+		Artifacts: env.Artifacts,
+	}, env.Config)
+	token, userID := createUserAndLogin(t, router)
+
+	_, resp := uploadFile(t, router, token, "cat.png", testPNG(t, 10, 10))
+	uploadID := resp["upload_id"].(string)
+
+	for range 2 {
+		code, _ := createTaskWithParams(t, router, token, "resize_image", map[string]interface{}{"upload_id": uploadID})
+		require.Equal(t, http.StatusServiceUnavailable, code)
+	}
+
+	var tasks, refs int
+	require.NoError(t, env.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE user_id = $1`, userID).Scan(&tasks))
+	require.NoError(t, env.DB.QueryRow(`SELECT COUNT(*) FROM task_uploads WHERE upload_id = $1`, uploadID).Scan(&refs))
+	assert.Zero(t, tasks)
+	assert.Zero(t, refs)
+}